@@ -0,0 +1,150 @@
+// Package apperror holds the errors handlers return. The error handler of
+// the app writes them as a models.WebResponse with a stable error code,
+// other errors are mapped by From first.
+package apperror
+
+import (
+	"echo-blog/models"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	CodeValidation       = "validation_failed"
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeTooLarge         = "too_large"
+	CodeUnsupportedMedia = "unsupported_media_type"
+	CodeInternal         = "internal_error"
+)
+
+// Error is a failure with the status and code to answer it with. Message
+// is shown to the client, Err is the cause and is only logged.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+	Fields  models.ValidationErrors
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a copy of e caused by err.
+func (e *Error) Wrap(err error) *Error {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+func New(status int, code string, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func Validation(message string) *Error {
+	return New(http.StatusBadRequest, CodeValidation, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+// Internal hides err from the client, it is only logged.
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "internal server error").Wrap(err)
+}
+
+// Lookup maps the error of loading a record: a missing record is a
+// NotFound with message, anything else is Internal.
+func Lookup(err error, message string) *Error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return NotFound(message).Wrap(err)
+	}
+	return Internal(err)
+}
+
+// From maps any error to an Error. Validation errors keep their fields,
+// echo errors their status, missing records become NotFound and failed
+// password or token checks Unauthorized. Anything else is Internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var fields models.ValidationErrors
+	if errors.As(err, &fields) {
+		validation := Validation(fields.Error())
+		validation.Fields = fields
+		return validation
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		if httpErr.Code >= http.StatusInternalServerError {
+			return Internal(err)
+		}
+		return New(httpErr.Code, codeFor(httpErr.Code), fmt.Sprint(httpErr.Message)).Wrap(httpErr.Internal)
+	}
+
+	var jwtErr *jwt.ValidationError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NotFound("record not found").Wrap(err)
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return Unauthorized("wrong email or password").Wrap(err)
+	case errors.As(err, &jwtErr):
+		return Unauthorized("You are not Authorized!").Wrap(err)
+	}
+	return Internal(err)
+}
+
+func codeFor(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeValidation
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMedia
+	}
+	return CodeBadRequest
+}
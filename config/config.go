@@ -1,41 +1,179 @@
 package config
 
 import (
+	"echo-blog/lib/migration"
 	"echo-blog/models"
+	"errors"
 	"fmt"
 	"log"
-	"os"
 
+	"github.com/glebarez/sqlite"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
 var DB *gorm.DB
 
-func InitDB() {
-
-	config := map[string]string{
-		"DB_Username": os.Getenv("DB_USERNAME"),
-		"DB_Password": os.Getenv("DB_PASSWORD"),
-		"DB_Port":     os.Getenv("DB_PORT"),
-		"DB_Host":     os.Getenv("DB_HOST"),
-		"DB_Name":     os.Getenv("DB_NAME"),
-	}
-	connectionString :=
-		fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True&loc=Local",
-			config["DB_Username"],
-			config["DB_Password"],
-			config["DB_Host"],
-			config["DB_Port"],
-			config["DB_Name"])
+// InitDB connects to the database of cfg, brings its schema up to date and
+// makes sure there is an admin.
+func InitDB(cfg *Config) {
+	Connect(cfg.Database)
+	InitMigrate(cfg.Database.Migrate)
+	bootstrapAdmin(cfg.Admin)
+}
+
+// Connect opens the database without touching its schema.
+func Connect(db DatabaseConfig) {
+	dialector, err := Dialector(db)
+	if err != nil {
+		log.Fatalf("Error initializing the database! %v", err)
+	}
+
 	var e error
-	DB, e = gorm.Open(mysql.Open(connectionString), &gorm.Config{})
+	// duplicate keys come back as gorm.ErrDuplicatedKey on every driver
+	DB, e = gorm.Open(dialector, &gorm.Config{TranslateError: true})
 	if e != nil {
 		// Handle database connection error
 		log.Fatalf("Error initializing the database!")
 	}
-	InitMigrate()
+
+	sqlDB, err := DB.DB()
+	if err != nil {
+		log.Fatalf("Error initializing the database! %v", err)
+	}
+	sqlDB.SetMaxOpenConns(db.MaxOpenConns)
+	if inMemory(db) {
+		// the database is gone once its last connection closes
+		if db.MaxIdleConns < 1 {
+			db.MaxIdleConns = 1
+		}
+		sqlDB.SetMaxIdleConns(db.MaxIdleConns)
+		return
+	}
+	sqlDB.SetMaxIdleConns(db.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(db.ConnMaxLifetime)
+}
+
+// Dialector opens the driver of db: "mysql" (the default), "postgres" or
+// "sqlite". SQLite keeps the database in the file named by db.Name, or in
+// memory when the name is empty or ":memory:".
+func Dialector(db DatabaseConfig) (gorm.Dialector, error) {
+	switch db.Driver {
+	case "", "mysql":
+		connectionString :=
+			fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8&parseTime=True&loc=Local",
+				db.Username,
+				db.Password,
+				db.Host,
+				portOr(db.Port, 3306),
+				db.Name)
+		return mysql.Open(connectionString), nil
+	case "postgres":
+		sslMode := db.SSLMode
+		if sslMode == "" {
+			sslMode = "disable"
+		}
+		connectionString :=
+			fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+				db.Host,
+				portOr(db.Port, 5432),
+				db.Username,
+				db.Password,
+				db.Name,
+				sslMode)
+		return postgres.Open(connectionString), nil
+	case "sqlite":
+		name := db.Name
+		if inMemory(db) {
+			// every connection of the pool has to see the same database
+			name = "file:echo-blog?mode=memory&cache=shared"
+		}
+		return sqlite.Open(name), nil
+	}
+	return nil, fmt.Errorf("unknown database driver %q", db.Driver)
+}
+
+func inMemory(db DatabaseConfig) bool {
+	return db.Driver == "sqlite" && (db.Name == "" || db.Name == ":memory:")
+}
+
+func portOr(port int, fallback int) int {
+	if port == 0 {
+		return fallback
+	}
+	return port
 }
-func InitMigrate() {
-	DB.AutoMigrate(&models.User{}, &models.Blog{})
+
+// InitMigrate brings the schema up to date, see Migrations. With mode
+// "check" it only checks the schema and refuses to start when migrations
+// are pending, they are then applied with the migrate command.
+func InitMigrate(mode string) {
+	migrator := migration.New(DB, Migrations)
+	switch mode {
+	case "", "auto":
+		if err := migrator.Up(); err != nil {
+			log.Fatalf("Error migrating the database! %v", err)
+		}
+	case "check":
+		pending, err := migrator.Pending()
+		if err != nil {
+			log.Fatalf("Error checking the database schema! %v", err)
+		}
+		if len(pending) > 0 {
+			log.Fatalf("The database schema is behind, %d migrations are pending. Run: go run main.go migrate up", len(pending))
+		}
+	default:
+		log.Fatalf("Unknown DB_MIGRATE %q, use auto or check", mode)
+	}
+}
+
+// bootstrapAdmin makes sure there is at least one admin. When no admin exists
+// and admin.Email is set, the user with that email is promoted, or created
+// with admin.Username and admin.Password when it does not exist yet.
+func bootstrapAdmin(admin AdminConfig) {
+	email := admin.Email
+	if email == "" {
+		return
+	}
+
+	var admins int64
+	if err := DB.Model(&models.User{}).Where("role = ?", models.RoleAdmin).Count(&admins).Error; err != nil || admins > 0 {
+		return
+	}
+
+	var user models.User
+	err := DB.Where("email = ?", email).First(&user).Error
+	if err == nil {
+		if err := DB.Model(&user).Update("role", models.RoleAdmin).Error; err != nil {
+			log.Printf("cannot promote %s to admin, error : %v\n", email, err)
+		}
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("cannot bootstrap admin, error : %v\n", err)
+		return
+	}
+
+	password := admin.Password
+	if password == "" {
+		log.Printf("cannot bootstrap admin, user %s does not exist and ADMIN_PASSWORD is empty\n", email)
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		log.Printf("cannot bootstrap admin, error : %v\n", err)
+		return
+	}
+	username := admin.Username
+	if username == "" {
+		username = "admin"
+	}
+	user = models.User{Username: username, Email: email, Password: string(hashedPassword), Role: models.RoleAdmin}
+	if err := DB.Create(&user).Error; err != nil {
+		log.Printf("cannot bootstrap admin, error : %v\n", err)
+		return
+	}
+	log.Printf("admin %s created\n", email)
 }
@@ -0,0 +1,414 @@
+package config
+
+import (
+	"echo-blog/helper"
+	"echo-blog/lib/imaging"
+	"echo-blog/models"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// MinJWTSecretLength is the shortest JWT_SECRET the app starts with, the
+// HS256 key should be at least as long as the hash.
+const MinJWTSecretLength = 32
+
+// Config is the configuration of the app. Load fills it from, each source
+// overriding the ones before it: the defaults, a YAML or TOML file, the
+// environment and command line flags. The tags of a setting name its key in
+// the file, its environment variable and its flag, secret settings are
+// redacted when the config is printed.
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	JWTSecret string          `yaml:"jwt_secret" toml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	Admin     AdminConfig     `yaml:"admin" toml:"admin"`
+	Search    SearchConfig    `yaml:"search" toml:"search"`
+	Spam      SpamConfig      `yaml:"spam" toml:"spam"`
+	Storage   StorageConfig   `yaml:"storage" toml:"storage"`
+	Media     MediaConfig     `yaml:"media" toml:"media"`
+	Scheduler SchedulerConfig `yaml:"scheduler" toml:"scheduler"`
+	Site      SiteConfig      `yaml:"site" toml:"site"`
+	Feed      FeedConfig      `yaml:"feed" toml:"feed"`
+	Robots    RobotsConfig    `yaml:"robots" toml:"robots"`
+}
+
+type ServerConfig struct {
+	Port         int           `yaml:"port" toml:"port" env:"PORT" flag:"port"`
+	ReadTimeout  time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"READ_TIMEOUT"`
+	WriteTimeout time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"WRITE_TIMEOUT"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"IDLE_TIMEOUT"`
+}
+
+// DatabaseConfig is the database to connect to, see Dialector. Port 0 is
+// the default port of the driver.
+type DatabaseConfig struct {
+	Driver          string        `yaml:"driver" toml:"driver" env:"DB_DRIVER" flag:"db-driver"`
+	Host            string        `yaml:"host" toml:"host" env:"DB_HOST" flag:"db-host"`
+	Port            int           `yaml:"port" toml:"port" env:"DB_PORT" flag:"db-port"`
+	Username        string        `yaml:"username" toml:"username" env:"DB_USERNAME"`
+	Password        string        `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string        `yaml:"name" toml:"name" env:"DB_NAME" flag:"db-name"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE"`
+	Migrate         string        `yaml:"migrate" toml:"migrate" env:"DB_MIGRATE" flag:"db-migrate"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+}
+
+// AdminConfig is the admin created on startup when there is none, see
+// bootstrapAdmin.
+type AdminConfig struct {
+	Email    string `yaml:"email" toml:"email" env:"ADMIN_EMAIL"`
+	Username string `yaml:"username" toml:"username" env:"ADMIN_USERNAME"`
+	Password string `yaml:"password" toml:"password" env:"ADMIN_PASSWORD" secret:"true"`
+}
+
+// SearchConfig picks the search engine, empty picks the best one for the
+// database.
+type SearchConfig struct {
+	Driver string `yaml:"driver" toml:"driver" env:"SEARCH_DRIVER" flag:"search-driver"`
+}
+
+type SpamConfig struct {
+	MaxLinks    int      `yaml:"max_links" toml:"max_links" env:"SPAM_MAX_LINKS"`
+	BannedWords []string `yaml:"banned_words" toml:"banned_words" env:"SPAM_BANNED_WORDS"`
+}
+
+type StorageConfig struct {
+	Driver   string   `yaml:"driver" toml:"driver" env:"STORAGE_DRIVER" flag:"storage-driver"`
+	MediaDir string   `yaml:"media_dir" toml:"media_dir" env:"MEDIA_DIR"`
+	S3       S3Config `yaml:"s3" toml:"s3"`
+}
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint" env:"S3_ENDPOINT"`
+	Region    string `yaml:"region" toml:"region" env:"S3_REGION"`
+	Bucket    string `yaml:"bucket" toml:"bucket" env:"S3_BUCKET"`
+	AccessKey string `yaml:"access_key" toml:"access_key" env:"S3_ACCESS_KEY"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key" env:"S3_SECRET_KEY" secret:"true"`
+}
+
+// MediaConfig limits uploads, see controllers.SetupMedia. MaxSize is in
+// bytes, no AllowedTypes accepts the types the app knows an extension for.
+type MediaConfig struct {
+	MaxSize      int64    `yaml:"max_size" toml:"max_size" env:"MEDIA_MAX_SIZE"`
+	AllowedTypes []string `yaml:"allowed_types" toml:"allowed_types" env:"MEDIA_ALLOWED_TYPES"`
+	ImageWidths  []int    `yaml:"image_widths" toml:"image_widths" env:"MEDIA_IMAGE_WIDTHS"`
+}
+
+// SchedulerConfig sets how often scheduled blogs are published and how many
+// days trashed blogs and users are kept, 0 keeps them until purged by hand.
+type SchedulerConfig struct {
+	Interval           time.Duration `yaml:"interval" toml:"interval" env:"SCHEDULER_INTERVAL"`
+	TrashRetentionDays int           `yaml:"trash_retention_days" toml:"trash_retention_days" env:"TRASH_RETENTION_DAYS"`
+}
+
+// SiteConfig is how the blog is linked to from feeds and sitemaps. No URL
+// uses the scheme and host of each request. BlogPath lets a frontend serve
+// blogs under its own path.
+type SiteConfig struct {
+	URL      string `yaml:"url" toml:"url" env:"SITE_URL"`
+	Title    string `yaml:"title" toml:"title" env:"SITE_TITLE"`
+	BlogPath string `yaml:"blog_path" toml:"blog_path" env:"BLOG_URL_PATH"`
+}
+
+// FeedConfig is the feed a request gets without limit and content query
+// parameters.
+type FeedConfig struct {
+	Items       int  `yaml:"items" toml:"items" env:"FEED_ITEMS"`
+	FullContent bool `yaml:"full_content" toml:"full_content" env:"FEED_FULL_CONTENT"`
+}
+
+// RobotsConfig sets robots.txt, File replaces the generated one.
+type RobotsConfig struct {
+	File     string   `yaml:"file" toml:"file" env:"ROBOTS_TXT_FILE"`
+	Disallow []string `yaml:"disallow" toml:"disallow" env:"ROBOTS_DISALLOW"`
+}
+
+// Default is the configuration before any source is read.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:         3000,
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: time.Minute,
+			IdleTimeout:  2 * time.Minute,
+		},
+		Database: DatabaseConfig{
+			Driver:          "mysql",
+			Host:            "127.0.0.1",
+			SSLMode:         "disable",
+			Migrate:         "auto",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Admin:     AdminConfig{Username: "admin"},
+		Spam:      SpamConfig{MaxLinks: 2},
+		Storage:   StorageConfig{Driver: "local", MediaDir: "uploads"},
+		Media:     MediaConfig{MaxSize: 10 << 20, ImageWidths: imaging.DefaultWidths},
+		Scheduler: SchedulerConfig{Interval: time.Minute, TrashRetentionDays: 30},
+		Site:      SiteConfig{Title: helper.DefaultSiteTitle, BlogPath: helper.DefaultBlogURLPath},
+		Feed:      FeedConfig{Items: 20},
+	}
+}
+
+// Load reads the configuration from the YAML or TOML file given by the
+// -config flag or CONFIG_FILE, the environment and the flags in args, and
+// validates it. The arguments left after the flags are returned.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("echo-blog", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML file with the settings, overrides CONFIG_FILE")
+	var flags []func() error
+	err := eachSetting(cfg, func(field reflect.StructField, value reflect.Value) error {
+		name := field.Tag.Get("flag")
+		if name == "" {
+			return nil
+		}
+		fs.Func(name, "overrides "+field.Tag.Get("env"), func(s string) error {
+			flags = append(flags, func() error { return setSetting(value, "-"+name, s) })
+			return nil
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *file != "" {
+		if err := loadFile(cfg, *file); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = eachSetting(cfg, func(field reflect.StructField, value reflect.Value) error {
+		name := field.Tag.Get("env")
+		// an empty variable counts as unset, like the empty ones of .env.example
+		if s := os.Getenv(name); name != "" && s != "" {
+			return setSetting(value, name, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, set := range flags {
+		if err := set(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile reads the settings in the YAML or TOML file name into cfg, the
+// extension tells which. Unknown keys are an error, they are most likely
+// typos.
+func loadFile(cfg *Config, name string) error {
+	switch filepath.Ext(name) {
+	case ".yaml", ".yml", ".json":
+		return loadYAML(cfg, name)
+	case ".toml":
+		return loadTOML(cfg, name)
+	default:
+		return fmt.Errorf("config file %s: only YAML and TOML files are supported", name)
+	}
+}
+
+func loadYAML(cfg *Config, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %w", name, err)
+	}
+	return nil
+}
+
+func loadTOML(cfg *Config, name string) error {
+	meta, err := toml.DecodeFile(name, cfg)
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", name, err)
+	}
+	if unknown := meta.Undecoded(); len(unknown) > 0 {
+		keys := make([]string, len(unknown))
+		for i, key := range unknown {
+			keys[i] = key.String()
+		}
+		return fmt.Errorf("config file %s: unknown keys %s", name, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+// Validate reports every setting the app cannot start with.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.JWTSecret != "", "JWT_SECRET is required")
+	check(c.JWTSecret == "" || len(c.JWTSecret) >= MinJWTSecretLength, "JWT_SECRET must be at least %d characters", MinJWTSecretLength)
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "PORT %d is not a port", c.Server.Port)
+	check(c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0, "server timeouts cannot be negative")
+
+	switch c.Database.Driver {
+	case "mysql", "postgres":
+		check(c.Database.Name != "", "DB_NAME is required for %s", c.Database.Driver)
+	case "sqlite":
+	default:
+		check(false, "unknown DB_DRIVER %q, use mysql, postgres or sqlite", c.Database.Driver)
+	}
+	check(c.Database.Port >= 0 && c.Database.Port <= 65535, "DB_PORT %d is not a port", c.Database.Port)
+	check(c.Database.Migrate == "auto" || c.Database.Migrate == "check", "unknown DB_MIGRATE %q, use auto or check", c.Database.Migrate)
+	check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0 && c.Database.ConnMaxLifetime >= 0, "database pool sizes cannot be negative")
+
+	check(c.Spam.MaxLinks >= 0, "SPAM_MAX_LINKS cannot be negative")
+	check(c.Scheduler.Interval > 0, "SCHEDULER_INTERVAL must be positive")
+	check(c.Scheduler.TrashRetentionDays >= 0, "TRASH_RETENTION_DAYS cannot be negative")
+
+	check(c.Media.MaxSize > 0, "MEDIA_MAX_SIZE must be positive")
+	for _, t := range c.Media.AllowedTypes {
+		check(strings.Count(t, "/") == 1, "MEDIA_ALLOWED_TYPES: %q is not a type like image/png", t)
+	}
+	for _, width := range c.Media.ImageWidths {
+		check(width > 0, "MEDIA_IMAGE_WIDTHS must be positive")
+	}
+
+	if c.Site.URL != "" {
+		site, err := url.Parse(c.Site.URL)
+		check(err == nil && (site.Scheme == "http" || site.Scheme == "https") && site.Host != "", "SITE_URL %q is not an http or https URL", c.Site.URL)
+	}
+	check(c.Site.Title != "", "SITE_TITLE is required")
+	check(strings.HasPrefix(c.Site.BlogPath, "/"), "BLOG_URL_PATH %q must start with /", c.Site.BlogPath)
+	check(c.Feed.Items > 0 && c.Feed.Items <= models.MaxPageLimit, "FEED_ITEMS must be between 1 and %d", models.MaxPageLimit)
+	for _, path := range c.Robots.Disallow {
+		check(strings.HasPrefix(path, "/"), "ROBOTS_DISALLOW: %q must start with /", path)
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the config with the secrets masked.
+func (c Config) Redacted() Config {
+	eachSetting(&c, func(field reflect.StructField, value reflect.Value) error {
+		if field.Tag.Get("secret") == "true" && value.String() != "" {
+			value.SetString("[redacted]")
+		}
+		return nil
+	})
+	return c
+}
+
+// String prints the config as YAML without its secrets.
+func (c Config) String() string {
+	out, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return err.Error()
+	}
+	return string(out)
+}
+
+// eachSetting calls fn with every setting of cfg, stopping at the first
+// error.
+func eachSetting(cfg *Config, fn func(field reflect.StructField, value reflect.Value) error) error {
+	var walk func(v reflect.Value) error
+	walk = func(v reflect.Value) error {
+		for i := 0; i < v.NumField(); i++ {
+			field, value := v.Type().Field(i), v.Field(i)
+			var err error
+			if value.Kind() == reflect.Struct {
+				err = walk(value)
+			} else {
+				err = fn(field, value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(reflect.ValueOf(cfg).Elem())
+}
+
+// setSetting parses s into the setting value, name is where s comes from.
+// Lists are comma separated.
+func setSetting(value reflect.Value, name string, s string) error {
+	var err error
+	switch {
+	case value.Type() == reflect.TypeOf(time.Duration(0)):
+		var d time.Duration
+		if d, err = time.ParseDuration(s); err == nil {
+			value.SetInt(int64(d))
+		}
+	case value.Kind() == reflect.Int, value.Kind() == reflect.Int64:
+		var n int64
+		if n, err = strconv.ParseInt(s, 10, 64); err == nil {
+			value.SetInt(n)
+		}
+	case value.Kind() == reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(s); err == nil {
+			value.SetBool(b)
+		}
+	case value.Kind() == reflect.String:
+		value.SetString(s)
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		value.Set(reflect.ValueOf(splitList(s)))
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Int:
+		var numbers []int
+		for _, item := range splitList(s) {
+			var n int
+			if n, err = strconv.Atoi(item); err != nil {
+				break
+			}
+			numbers = append(numbers, n)
+		}
+		value.Set(reflect.ValueOf(numbers))
+	default:
+		err = fmt.Errorf("unsupported setting type %s", value.Type())
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, s, err)
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
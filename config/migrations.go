@@ -0,0 +1,477 @@
+package config
+
+import (
+	"echo-blog/helper"
+	"echo-blog/lib/migration"
+	"echo-blog/lib/render"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migrations are the schema changes of the app, applied by InitMigrate or
+// the migrate command. Add new ones at the end with the next version.
+//
+// A migration describes its tables with private structs frozen at the
+// version it was released with, never with the structs of models, which
+// keep changing. Databases created before migrations existed may already
+// have the columns of a later migration, so every Up only adds what is
+// missing.
+var Migrations = []migration.Migration{
+	{Version: 1, Name: "create_tables", Up: createTables, Down: dropTables},
+	{Version: 2, Name: "blog_fulltext_index", Up: createBlogFulltextIndex, Down: dropBlogFulltextIndex},
+	{Version: 3, Name: "blog_content_format", Up: addBlogContentFormat, Down: dropBlogContentFormat},
+	{Version: 4, Name: "create_media", Up: createMedia, Down: dropMedia},
+	{Version: 5, Name: "media_variants", Up: addMediaVariants, Down: dropMediaVariants},
+	{Version: 6, Name: "free_trashed_slugs", Up: migrateTrashedSlugs, Down: keepData},
+	{Version: 7, Name: "drop_user_token", Up: dropUserToken, Down: addUserToken},
+	{Version: 8, Name: "unique_user_email", Up: createUserEmailIndex, Down: dropUserEmailIndex},
+}
+
+// v1model is gorm.Model as of version 1.
+type v1model struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+type v1User struct {
+	Model    v1model `gorm:"embedded"`
+	Username string
+	Email    string
+	Password string
+	Token    string
+	Role     string `gorm:"size:20;default:author"`
+}
+
+type v1Category struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Name        string `gorm:"size:100"`
+	Slug        string `gorm:"size:191;uniqueIndex"`
+	Description string
+	ParentID    *uint `gorm:"index"`
+}
+
+type v1Tag struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string `gorm:"size:100"`
+	Slug      string `gorm:"size:191;uniqueIndex"`
+}
+
+type v1Blog struct {
+	Model               v1model `gorm:"embedded"`
+	Title               string
+	Body                string
+	Slug                string `gorm:"size:191;uniqueIndex"`
+	Status              string `gorm:"size:20;default:draft;index"`
+	PublishedAt         *time.Time
+	ScheduledAt         *time.Time `gorm:"index"`
+	UserID              uint
+	CategoryID          *uint `gorm:"index"`
+	AutoApproveComments *bool `gorm:"default:false"`
+}
+
+type v1BlogTag struct {
+	BlogID uint `gorm:"primaryKey"`
+	TagID  uint `gorm:"primaryKey"`
+}
+
+type v1Comment struct {
+	Model       v1model `gorm:"embedded"`
+	BlogID      uint    `gorm:"index"`
+	ParentID    *uint   `gorm:"index"`
+	Body        string
+	UserID      uint
+	Status      string `gorm:"size:20;default:pending;index"`
+	SpamReason  string `gorm:"size:255"`
+	ModeratedBy *uint
+	ModeratedAt *time.Time
+}
+
+type v1BlogSlugRedirect struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Slug      string `gorm:"size:191;uniqueIndex"`
+	BlogID    uint   `gorm:"index"`
+}
+
+type v1RefreshToken struct {
+	Model     v1model `gorm:"embedded"`
+	UserID    uint
+	SessionID string `gorm:"size:32;index"`
+	TokenHash string `gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+type v1BlogRevision struct {
+	ID           uint `gorm:"primarykey"`
+	CreatedAt    time.Time
+	BlogID       uint `gorm:"uniqueIndex:idx_blog_revision_number"`
+	Number       int  `gorm:"uniqueIndex:idx_blog_revision_number"`
+	Title        string
+	Body         string
+	Slug         string
+	RestoredFrom *int
+	UserID       uint
+}
+
+func (v1User) TableName() string             { return "users" }
+func (v1Category) TableName() string         { return "categories" }
+func (v1Tag) TableName() string              { return "tags" }
+func (v1Blog) TableName() string             { return "blogs" }
+func (v1BlogTag) TableName() string          { return "blog_tags" }
+func (v1Comment) TableName() string          { return "comments" }
+func (v1BlogSlugRedirect) TableName() string { return "blog_slug_redirects" }
+func (v1RefreshToken) TableName() string     { return "refresh_tokens" }
+func (v1BlogRevision) TableName() string     { return "blog_revisions" }
+
+// v1Tables are the tables of the first migration, in the order they are
+// created.
+var v1Tables = []interface{}{&v1User{}, &v1Category{}, &v1Tag{}, &v1Blog{}, &v1BlogTag{}, &v1Comment{}, &v1BlogSlugRedirect{}, &v1RefreshToken{}, &v1BlogRevision{}}
+
+// createTables creates the tables, or brings the tables of a database
+// created before migrations existed up to date and fixes their rows.
+func createTables(tx *gorm.DB) error {
+	hadBlogStatus := tx.Migrator().HasColumn("blogs", "status")
+	hadCommentStatus := tx.Migrator().HasColumn("comments", "status")
+	if tx.Migrator().HasTable("blogs") && !tx.Migrator().HasIndex("blogs", "idx_blogs_slug") {
+		if err := migrateBlogSlugs(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.AutoMigrate(v1Tables...); err != nil {
+		return err
+	}
+	if err := migrateBlogAuthors(tx); err != nil {
+		return err
+	}
+	if !hadBlogStatus {
+		if err := migrateBlogStatus(tx); err != nil {
+			return err
+		}
+	}
+	if !hadCommentStatus {
+		return migrateCommentStatus(tx)
+	}
+	return nil
+}
+
+func dropTables(tx *gorm.DB) error {
+	for i := len(v1Tables) - 1; i >= 0; i-- {
+		if err := tx.Migrator().DropTable(v1Tables[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createBlogFulltextIndex adds the index the mysql search engine uses, see
+// lib/search. Other databases search in memory and need none.
+func createBlogFulltextIndex(tx *gorm.DB) error {
+	if tx.Dialector.Name() != "mysql" || tx.Migrator().HasIndex("blogs", "idx_blogs_fulltext") {
+		return nil
+	}
+	return tx.Exec("CREATE FULLTEXT INDEX idx_blogs_fulltext ON blogs (title, body)").Error
+}
+
+func dropBlogFulltextIndex(tx *gorm.DB) error {
+	if !tx.Migrator().HasIndex("blogs", "idx_blogs_fulltext") {
+		return nil
+	}
+	return tx.Migrator().DropIndex("blogs", "idx_blogs_fulltext")
+}
+
+// v3Blog holds the columns version 3 adds to blogs.
+type v3Blog struct {
+	ContentFormat string `gorm:"size:20;default:markdown"`
+	BodyHTML      string
+	TOC           string `gorm:"type:text"`
+}
+
+func (v3Blog) TableName() string { return "blogs" }
+
+// addBlogContentFormat stores how a blog is written and its rendered HTML.
+// The blogs written before are rendered as markdown, the default format.
+func addBlogContentFormat(tx *gorm.DB) error {
+	hadBodyHTML := tx.Migrator().HasColumn("blogs", "body_html")
+	if err := tx.AutoMigrate(&v3Blog{}); err != nil {
+		return err
+	}
+	if !hadBodyHTML {
+		return migrateBlogHTML(tx)
+	}
+	return nil
+}
+
+func dropBlogContentFormat(tx *gorm.DB) error {
+	return dropColumns(tx, "blogs", "content_format", "body_html", "toc")
+}
+
+type v4Media struct {
+	ID          uint `gorm:"primarykey"`
+	CreatedAt   time.Time
+	UserID      uint `gorm:"index"`
+	FileName    string
+	Key         string `gorm:"size:191;uniqueIndex"`
+	ContentType string `gorm:"size:100"`
+	Size        int64
+}
+
+// v4Blog holds the column version 4 adds to blogs.
+type v4Blog struct {
+	FeaturedMediaID *uint `gorm:"index"`
+}
+
+func (v4Media) TableName() string { return "media" }
+func (v4Blog) TableName() string  { return "blogs" }
+
+// createMedia adds uploads and the featured image of blogs.
+func createMedia(tx *gorm.DB) error {
+	return tx.AutoMigrate(&v4Media{}, &v4Blog{})
+}
+
+func dropMedia(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex("blogs", "idx_blogs_featured_media_id") {
+		if err := tx.Migrator().DropIndex("blogs", "idx_blogs_featured_media_id"); err != nil {
+			return err
+		}
+	}
+	if err := dropColumns(tx, "blogs", "featured_media_id"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(&v4Media{})
+}
+
+// v5Media holds the columns version 5 adds to media.
+type v5Media struct {
+	Width  int
+	Height int
+}
+
+type v5MediaVariant struct {
+	ID          uint   `gorm:"primarykey"`
+	MediaID     uint   `gorm:"index"`
+	Name        string `gorm:"size:20"`
+	Key         string `gorm:"size:191;uniqueIndex"`
+	ContentType string `gorm:"size:100"`
+	Width       int
+	Height      int
+	Size        int64
+}
+
+func (v5Media) TableName() string        { return "media" }
+func (v5MediaVariant) TableName() string { return "media_variants" }
+
+// addMediaVariants adds the size of images and their resized variants.
+func addMediaVariants(tx *gorm.DB) error {
+	return tx.AutoMigrate(&v5Media{}, &v5MediaVariant{})
+}
+
+func dropMediaVariants(tx *gorm.DB) error {
+	if err := tx.Migrator().DropTable(&v5MediaVariant{}); err != nil {
+		return err
+	}
+	return dropColumns(tx, "media", "width", "height")
+}
+
+// v7User holds the column version 7 drops from users.
+type v7User struct {
+	Token string
+}
+
+func (v7User) TableName() string { return "users" }
+
+// dropUserToken drops the access token stored at login, nothing checked it
+// and sessions live in refresh_tokens.
+func dropUserToken(tx *gorm.DB) error {
+	return dropColumns(tx, "users", "token")
+}
+
+func addUserToken(tx *gorm.DB) error {
+	return tx.AutoMigrate(&v7User{})
+}
+
+// createUserEmailIndex makes the email of users unique among the users not
+// in the trash, so a trashed user keeps their email until restored. MySQL
+// has no partial indexes, it indexes an expression that is NULL for trashed
+// users instead, on an email column shortened to fit an index.
+func createUserEmailIndex(tx *gorm.DB) error {
+	if tx.Migrator().HasIndex("users", "idx_users_email") {
+		return nil
+	}
+
+	var duplicates []string
+	if err := tx.Table("users").Where("deleted_at IS NULL").Group("email").Having("COUNT(*) > 1").Pluck("email", &duplicates).Error; err != nil {
+		return err
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("users share the emails %v, change them before migrating", duplicates)
+	}
+
+	if tx.Dialector.Name() == "mysql" {
+		if err := tx.Exec("ALTER TABLE users MODIFY email varchar(191)").Error; err != nil {
+			return err
+		}
+		return tx.Exec("CREATE UNIQUE INDEX idx_users_email ON users ((CASE WHEN deleted_at IS NULL THEN email END))").Error
+	}
+	return tx.Exec("CREATE UNIQUE INDEX idx_users_email ON users (email) WHERE deleted_at IS NULL").Error
+}
+
+func dropUserEmailIndex(tx *gorm.DB) error {
+	if !tx.Migrator().HasIndex("users", "idx_users_email") {
+		return nil
+	}
+	if err := tx.Migrator().DropIndex("users", "idx_users_email"); err != nil {
+		return err
+	}
+	if tx.Dialector.Name() == "mysql" {
+		return tx.Exec("ALTER TABLE users MODIFY email longtext").Error
+	}
+	return nil
+}
+
+// keepData is the Down of migrations that only fix rows, the fixed rows stay
+// valid on the older schema.
+func keepData(tx *gorm.DB) error {
+	return nil
+}
+
+// dropColumns drops the columns of table that exist. It uses plain DDL,
+// which every supported database understands, rather than the SQLite
+// migrator, which rebuilds the table and loses its indexes.
+func dropColumns(tx *gorm.DB, table string, columns ...string) error {
+	for _, column := range columns {
+		if !tx.Migrator().HasColumn(table, column) {
+			continue
+		}
+		if err := tx.Exec("ALTER TABLE ? DROP COLUMN ?", clause.Table{Name: table}, clause.Column{Name: column}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// The helpers below fix the rows of databases created before migrations
+// existed. Like the migrations they work on the tables, not on models.
+
+// migrateBlogAuthors assigns blogs created before authorship existed to the
+// oldest user, so every row ends up with an owner.
+func migrateBlogAuthors(tx *gorm.DB) error {
+	var owners []uint
+	if err := tx.Table("users").Where("deleted_at IS NULL").Order("id").Limit(1).Pluck("id", &owners).Error; err != nil || len(owners) == 0 {
+		return err
+	}
+	return tx.Table("blogs").Where("user_id IS NULL OR user_id = 0").UpdateColumn("user_id", owners[0]).Error
+}
+
+// migrateBlogStatus publishes the blogs that existed before the status
+// column was added, they were all publicly visible back then.
+func migrateBlogStatus(tx *gorm.DB) error {
+	return tx.Table("blogs").Where("1 = 1").UpdateColumns(map[string]interface{}{
+		"status":       "published",
+		"published_at": gorm.Expr("created_at"),
+	}).Error
+}
+
+// migrateCommentStatus approves the comments written before moderation
+// existed, they were all public back then.
+func migrateCommentStatus(tx *gorm.DB) error {
+	return tx.Table("comments").Where("1 = 1").UpdateColumn("status", "approved").Error
+}
+
+// migrateBlogHTML renders the blogs written before the API returned
+// rendered HTML. A blog that cannot be rendered is logged and left without
+// HTML.
+func migrateBlogHTML(tx *gorm.DB) error {
+	var blogs []struct {
+		ID            uint
+		Body          string
+		ContentFormat string
+	}
+	if err := tx.Table("blogs").Select("id", "body", "content_format").Find(&blogs).Error; err != nil {
+		return err
+	}
+
+	for _, blog := range blogs {
+		bodyHTML, toc, err := render.Render(blog.ContentFormat, blog.Body)
+		if err != nil {
+			log.Printf("cannot render blog %d, error : %v\n", blog.ID, err)
+			continue
+		}
+		tocJSON, err := json.Marshal(toc)
+		if err != nil {
+			return err
+		}
+		if err := tx.Table("blogs").Where("id = ?", blog.ID).UpdateColumns(map[string]interface{}{"body_html": bodyHTML, "toc": string(tocJSON)}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateBlogSlugs normalizes the slugs clients used to pick freely and
+// removes duplicates, so the unique slug index can be created.
+func migrateBlogSlugs(tx *gorm.DB) error {
+	var blogs []struct {
+		ID    uint
+		Title string
+		Slug  string
+	}
+	if err := tx.Table("blogs").Select("id", "title", "slug").Order("id").Find(&blogs).Error; err != nil {
+		return err
+	}
+
+	used := make(map[string]bool, len(blogs))
+	for _, blog := range blogs {
+		base := helper.MakeSlug(blog.Slug)
+		if base == "" {
+			base = helper.MakeSlug(blog.Title)
+		}
+		if base == "" {
+			base = "blog"
+		}
+		slug := base
+		for n := 2; used[slug]; n++ {
+			slug = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[slug] = true
+
+		if slug != blog.Slug {
+			if err := tx.Table("blogs").Where("id = ?", blog.ID).UpdateColumn("slug", slug).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateTrashedSlugs frees the slugs still held by blogs trashed before
+// trashing moved them aside, see database.GormBlogRepository.Delete.
+func migrateTrashedSlugs(tx *gorm.DB) error {
+	var blogs []struct {
+		ID   uint
+		Slug string
+	}
+	if err := tx.Table("blogs").Select("id", "slug").Where("deleted_at IS NOT NULL AND slug NOT LIKE ?", "%~%").Find(&blogs).Error; err != nil {
+		return err
+	}
+
+	for _, blog := range blogs {
+		if err := tx.Table("blogs").Where("id = ?", blog.ID).UpdateColumn("slug", fmt.Sprintf("%s~%d", blog.Slug, blog.ID)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/validation"
+	"echo-blog/models"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func (h *UserHandler) RefreshToken(c echo.Context) error {
+	request := models.TokenPair{}
+	if err := validation.Bind(c, &request); err != nil {
+		return err
+	}
+
+	if request.RefreshToken == "" {
+		return apperror.Validation("refresh token is required")
+	}
+
+	pair, e := h.users.Refresh(request.RefreshToken)
+	if e != nil {
+		if errors.Is(e, database.ErrRefreshTokenInvalid) || errors.Is(e, database.ErrRefreshTokenReused) {
+			return apperror.Unauthorized("refresh token is invalid or expired")
+		}
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "token refreshed successfully", pair).WriteToResponseBody(c.Response())
+}
+
+func (h *UserHandler) LogoutUser(c echo.Context) error {
+	sessionId, _ := c.Get("sessionId").(string)
+
+	if e := h.users.Logout(sessionId); e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "logout successfully", nil).WriteToResponseBody(c.Response())
+}
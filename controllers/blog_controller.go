@@ -1,72 +1,301 @@
 package controllers
 
 import (
-	"echo-blog/config"
+	"echo-blog/apperror"
+	"echo-blog/dto"
 	"echo-blog/helper"
 	"echo-blog/lib/database"
+	"echo-blog/lib/render"
+	"echo-blog/lib/search"
+	"echo-blog/lib/validation"
 	"echo-blog/models"
+	"echo-blog/service"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 )
 
-func GetAllBlogs(c echo.Context) error {
-	blogs, e := database.GetAllBlogs()
+var blogSortFields = map[string]string{
+	"created_at":   "created_at",
+	"updated_at":   "updated_at",
+	"published_at": "published_at",
+	"title":        "title",
+}
+
+// BlogHandler serves the blog routes, see routes.New.
+type BlogHandler struct {
+	blogs *service.BlogService
+}
+
+func NewBlogHandler(blogs *service.BlogService) *BlogHandler {
+	return &BlogHandler{blogs: blogs}
+}
+
+func (h *BlogHandler) GetAllBlogs(c echo.Context) error {
+	page, e := helper.ParsePageRequest(c, blogSortFields, "-created_at")
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	from, to, e := helper.ParseDateRange(c)
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	filter := models.BlogFilter{
+		Author:   c.QueryParam("author"),
+		Status:   c.QueryParam("status"),
+		Tag:      c.QueryParam("tag"),
+		Category: c.QueryParam("category"),
+		From:     from,
+		To:       to,
+	}
+	if filter.Status != "" && !models.IsValidBlogStatus(filter.Status) {
+		return apperror.Validation("status must be one of draft, scheduled, published or archived")
+	}
+
+	blogs, meta, e := h.blogs.List(filter, page, currentViewer(c))
 	if e != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, e.Error())
+		return apperror.Internal(e)
 	}
 
-	return helper.WrapResponse(http.StatusOK, "success get all blog", &blogs).WriteToResponseBody(c.Response())
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success get all blog", dto.NewBlogs(blogs, currentViewer(c)), &meta).WriteToResponseBody(c.Response())
 
 }
 
-func GetBlogByID(c echo.Context) error {
-	id := c.Param("id")
+func (h *BlogHandler) GetBlogByID(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
 
-	blog, e := database.GetBlogByID(id)
+	blog, e := h.blogs.Get(id, currentViewer(c))
 
 	if e != nil {
-		return helper.WrapResponse(http.StatusBadRequest, "blog not found", e.Error()).WriteToResponseBody(c.Response())
+		return apperror.Lookup(e, "blog not found")
 	}
 
-	return helper.WrapResponse(http.StatusOK, "success get blog by id", &blog).WriteToResponseBody(c.Response())
+	return helper.WrapResponse(http.StatusOK, "success get blog by id", dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
 }
 
-func AddNewBlog(c echo.Context) error {
-	blog := models.Blog{}
-	c.Bind(&blog)
+// GetBlogBySlug answers old slugs of a blog with a permanent redirect to
+// its current one.
+func (h *BlogHandler) GetBlogBySlug(c echo.Context) error {
+	slug := c.Param("slug")
 
-	if err := blog.ValidatorSanitizer(); err != nil {
-		return helper.WrapResponse(http.StatusBadRequest, err.Error(), &models.Blog{}).WriteToResponseBody(c.Response())
+	blog, redirect, e := h.blogs.GetBySlug(slug, currentViewer(c))
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+	if redirect != "" {
+		return c.Redirect(http.StatusMovedPermanently, "/api/v1/blogs/slug/"+url.PathEscape(redirect))
 	}
 
-	if err := config.DB.Save(&blog).Error; err != nil {
-		return helper.WrapResponse(http.StatusBadRequest, "failed to add new blog", err.Error()).WriteToResponseBody(c.Response())
+	return helper.WrapResponse(http.StatusOK, "success get blog by slug", dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+func (h *BlogHandler) AddNewBlog(c echo.Context) error {
+	blog := models.Blog{}
+	if err := validation.Bind(c, &blog); err != nil {
+		return err
 	}
-	return helper.WrapResponse(http.StatusOK, "new blog added successfully", &blog).WriteToResponseBody(c.Response())
+
+	blog, err := h.blogs.Create(blog, currentViewer(c))
+	if err != nil {
+		if errors.Is(err, service.ErrMediaNotOwned) {
+			return apperror.Forbidden("you are not allowed to use this media")
+		}
+		if isBlogReferenceError(err) {
+			return apperror.Validation(err.Error())
+		}
+		return apperror.Internal(err)
+	}
+	return helper.WrapResponse(http.StatusOK, "new blog added successfully", dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
 }
 
-func UpdateBlog(c echo.Context) error {
+func (h *BlogHandler) UpdateBlog(c echo.Context) error {
+
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
 
-	id := c.Param("id")
+	if e := h.blogs.Authorize(id, currentViewer(c)); e != nil {
+		return updateLookupError(e, "update failed, blog id not found")
+	}
 
 	blog := models.Blog{}
-	c.Bind(&blog)
+	if err := validation.BindChanges(c, &blog, id); err != nil {
+		return err
+	}
 
-	if rowsAff := config.DB.Model(&blog).Where("id = ?", id).Updates(blog).RowsAffected; rowsAff == 0 {
-		return helper.WrapResponse(http.StatusBadRequest, "update failed, blog id not found", &models.Blog{}).WriteToResponseBody(c.Response())
+	updatedBlog, e := h.blogs.Update(id, blog, currentViewer(c))
+	if errors.Is(e, service.ErrForbidden) {
+		return apperror.Forbidden("you are not allowed to update this blog")
+	}
+	if errors.Is(e, service.ErrMediaNotOwned) {
+		return apperror.Forbidden("you are not allowed to use this media")
+	}
+	if isBlogReferenceError(e) {
+		return apperror.Validation(e.Error())
+	}
+	if e != nil {
+		return apperror.Lookup(e, "update failed, blog id not found")
 	}
 
-	return helper.WrapResponse(http.StatusOK, "blog updated successfully", &blog).WriteToResponseBody(c.Response())
+	return helper.WrapResponse(http.StatusOK, "blog updated successfully", dto.NewBlog(updatedBlog, currentViewer(c))).WriteToResponseBody(c.Response())
 }
 
-func DeleteBlog(c echo.Context) error {
-	id := c.Param("id")
-
-	_, e := database.DeleteBlogByID(id)
+func (h *BlogHandler) DeleteBlog(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
 
+	e := h.blogs.Delete(id, currentViewer(c))
+	if errors.Is(e, service.ErrForbidden) {
+		return apperror.Forbidden("you are not allowed to delete this blog")
+	}
 	if e != nil {
-		return helper.WrapResponse(http.StatusBadRequest, "delete failed, blog id not found", e.Error()).WriteToResponseBody(c.Response())
+		return apperror.Lookup(e, "delete failed, blog id not found")
 	}
 	return helper.WrapResponse(http.StatusOK, "blog deleted successfully", &models.Blog{}).WriteToResponseBody(c.Response())
 }
+
+func (h *BlogHandler) PublishBlog(c echo.Context) error {
+	return h.changeBlogStatus(c, models.BlogStatusPublished, "blog published successfully")
+}
+
+func (h *BlogHandler) UnpublishBlog(c echo.Context) error {
+	return h.changeBlogStatus(c, models.BlogStatusDraft, "blog unpublished successfully")
+}
+
+func (h *BlogHandler) ArchiveBlog(c echo.Context) error {
+	return h.changeBlogStatus(c, models.BlogStatusArchived, "blog archived successfully")
+}
+
+func (h *BlogHandler) ScheduleBlog(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
+
+	if e := h.blogs.Authorize(id, currentViewer(c)); e != nil {
+		return updateLookupError(e, "blog not found")
+	}
+
+	schedule := models.BlogSchedule{}
+	if err := validation.Bind(c, &schedule); err != nil {
+		return err
+	}
+
+	blog, e := h.blogs.Schedule(id, schedule.PublishAt, currentViewer(c))
+	switch {
+	case errors.Is(e, service.ErrForbidden):
+		return apperror.Forbidden("you are not allowed to update this blog")
+	case errors.Is(e, service.ErrScheduleInPast):
+		return apperror.Validation(e.Error())
+	case errors.Is(e, database.ErrBlogAlreadyPublished):
+		return apperror.Conflict(e.Error())
+	case e != nil:
+		return apperror.Lookup(e, "blog not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, "blog scheduled successfully", dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+func (h *BlogHandler) GetScheduledBlogs(c echo.Context) error {
+	blogs, e := h.blogs.Scheduled(currentViewer(c))
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get scheduled blogs", dto.NewBlogs(blogs, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+func (h *BlogHandler) changeBlogStatus(c echo.Context, status string, message string) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
+
+	blog, e := h.blogs.ChangeStatus(id, status, currentViewer(c))
+	if errors.Is(e, service.ErrForbidden) {
+		return apperror.Forbidden("you are not allowed to update this blog")
+	}
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, message, dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+// updateLookupError maps the error of BlogService.Authorize: a viewer who
+// may not change the blog is Forbidden, a missing blog NotFound with
+// message.
+func updateLookupError(err error, message string) error {
+	if errors.Is(err, service.ErrForbidden) {
+		return apperror.Forbidden("you are not allowed to update this blog")
+	}
+	return apperror.Lookup(err, message)
+}
+
+// isBlogReferenceError reports whether err is about a category, tag or
+// media a blog refers to that does not exist.
+func isBlogReferenceError(err error) bool {
+	return errors.Is(err, database.ErrCategoryNotFound) || errors.Is(err, database.ErrTagNotFound) || errors.Is(err, database.ErrMediaNotFound) || errors.Is(err, service.ErrUnknownTag)
+}
+
+// reindexBlog updates the search index after the blog with the id from the
+// route changed.
+func reindexBlog(id uint) {
+	search.Reindex(id)
+}
+
+// paramID reads the id route parameter name. Ids are positive numbers, any
+// other value is answered with NotFound and message, it never reaches the
+// database.
+func paramID(c echo.Context, name string, message string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		return 0, apperror.NotFound(message)
+	}
+	return uint(id), nil
+}
+
+// currentUserID returns the id of the user authenticated by UserAuthMiddlewares.
+func currentUserID(c echo.Context) uint {
+	userId, _ := c.Get("userId").(int)
+	return uint(userId)
+}
+
+func currentUserRole(c echo.Context) string {
+	role, _ := c.Get("role").(string)
+	return role
+}
+
+// currentViewer is who the response of the request is built for.
+func currentViewer(c echo.Context) dto.Viewer {
+	return dto.Viewer{ID: currentUserID(c), Role: currentUserRole(c)}
+}
+
+// canModifyBlog reports whether the current user may change a blog written
+// by authorId, see service.CanModifyBlog.
+func canModifyBlog(c echo.Context, authorId uint) bool {
+	return service.CanModifyBlog(currentViewer(c), authorId)
+}
+
+// GetHighlightCSS serves the stylesheet for the highlighted code blocks of
+// bodyHtml.
+func GetHighlightCSS(c echo.Context) error {
+	css, e := render.HighlightCSS()
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	c.Response().Header().Set("Cache-Control", "public, max-age=86400")
+	return c.Blob(http.StatusOK, "text/css; charset=utf-8", []byte(css))
+}
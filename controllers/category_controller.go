@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/validation"
+	"echo-blog/models"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetAllCategories returns the categories as a tree.
+func GetAllCategories(c echo.Context) error {
+	categories, e := database.GetCategoryTree()
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get all category", &categories).WriteToResponseBody(c.Response())
+}
+
+func GetCategoryByID(c echo.Context) error {
+	id, err := paramID(c, "id", "category not found")
+	if err != nil {
+		return err
+	}
+
+	category, e := database.GetCategoryByID(id)
+	if e != nil {
+		return apperror.Lookup(e, "category not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get category by id", &category).WriteToResponseBody(c.Response())
+}
+
+func AddNewCategory(c echo.Context) error {
+	category := models.Category{}
+	if err := validation.Bind(c, &category); err != nil {
+		return err
+	}
+
+	if err := database.CreateCategory(&category); err != nil {
+		return categoryError(err, "failed to add new category")
+	}
+	return helper.WrapResponse(http.StatusOK, "new category added successfully", &category).WriteToResponseBody(c.Response())
+}
+
+func UpdateCategory(c echo.Context) error {
+	id, err := paramID(c, "id", "category not found")
+	if err != nil {
+		return err
+	}
+
+	category := models.Category{}
+	if err := validation.BindChanges(c, &category, id); err != nil {
+		return err
+	}
+
+	updatedCategory, e := database.UpdateCategory(id, category)
+	if e != nil {
+		return categoryError(e, "update failed, category id not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, "category updated successfully", &updatedCategory).WriteToResponseBody(c.Response())
+}
+
+func DeleteCategory(c echo.Context) error {
+	id, err := paramID(c, "id", "category not found")
+	if err != nil {
+		return err
+	}
+
+	if _, e := database.DeleteCategoryByID(id); e != nil {
+		return apperror.Lookup(e, "delete failed, category id not found")
+	}
+	return helper.WrapResponse(http.StatusOK, "category deleted successfully", &models.Category{}).WriteToResponseBody(c.Response())
+}
+
+// categoryError maps the errors of saving a category, notFound is the
+// message for a missing category. A missing parent is a validation error.
+func categoryError(err error, notFound string) error {
+	switch {
+	case errors.Is(err, database.ErrCategoryExists):
+		return apperror.Conflict(err.Error())
+	case errors.Is(err, database.ErrCategoryNotFound), errors.Is(err, database.ErrCategoryCycle), errors.Is(err, database.ErrNameWithoutSlug):
+		return apperror.Validation(err.Error())
+	}
+	return apperror.Lookup(err, notFound)
+}
@@ -0,0 +1,249 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/spam"
+	"echo-blog/lib/validation"
+	"echo-blog/models"
+	"echo-blog/service"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var commentSortFields = map[string]string{
+	"created_at": "created_at",
+}
+
+// CommentHandler serves the comments of blogs and their moderation, see
+// routes.New.
+type CommentHandler struct {
+	blogs *service.BlogService
+}
+
+func NewCommentHandler(blogs *service.BlogService) *CommentHandler {
+	return &CommentHandler{blogs: blogs}
+}
+
+// GetBlogComments lists the comments of a blog, format=tree (default) nests
+// replies, format=flat returns them in reading order with their depth.
+func (h *CommentHandler) GetBlogComments(c echo.Context) error {
+	blog, e := h.visibleBlog(c)
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+
+	format := c.QueryParam("format")
+	if format == "" {
+		format = models.CommentFormatTree
+	}
+	if format != models.CommentFormatTree && format != models.CommentFormatFlat {
+		return apperror.Validation("format must be tree or flat")
+	}
+
+	comments, e := database.GetBlogComments(blog.ID, format, currentUserID(c), isModerator(c))
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get all comment", &comments).WriteToResponseBody(c.Response())
+}
+
+func (h *CommentHandler) AddNewComment(c echo.Context) error {
+	blog, e := h.visibleBlog(c)
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+
+	comment := models.Comment{}
+	if err := validation.Bind(c, &comment); err != nil {
+		return err
+	}
+
+	comment.ID = 0
+	comment.BlogID = blog.ID
+	comment.UserID = currentUserID(c)
+	comment.Author = nil
+	comment.Status, comment.SpamReason = commentStatus(c, blog, comment.Body)
+	comment.ModeratedBy = nil
+	comment.ModeratedAt = nil
+
+	if err := database.CreateComment(&comment); err != nil {
+		if errors.Is(err, database.ErrCommentParentNotFound) {
+			return apperror.Validation(err.Error())
+		}
+		return apperror.Internal(err)
+	}
+
+	if comment.Status != models.CommentStatusApproved {
+		comment.SpamReason = ""
+		return helper.WrapResponse(http.StatusOK, "new comment is waiting for moderation", &comment).WriteToResponseBody(c.Response())
+	}
+	return helper.WrapResponse(http.StatusOK, "new comment added successfully", &comment).WriteToResponseBody(c.Response())
+}
+
+// UpdateComment changes the body of a comment, only its author may do so.
+// The new body goes through the same checks as a new comment.
+func (h *CommentHandler) UpdateComment(c echo.Context) error {
+	blog, e := h.visibleBlog(c)
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+
+	commentId, e := paramID(c, "comment", "update failed, comment id not found")
+	if e != nil {
+		return e
+	}
+	comment, e := database.GetCommentByID(blog.ID, commentId)
+	if e != nil {
+		return apperror.Lookup(e, "update failed, comment id not found")
+	}
+	if comment.UserID != currentUserID(c) {
+		return apperror.Forbidden("you are not allowed to update this comment")
+	}
+
+	changes := models.Comment{}
+	if err := validation.Bind(c, &changes); err != nil {
+		return err
+	}
+
+	if comment.ModeratedBy != nil {
+		unlearnDecision(comment)
+	}
+	status, spamReason := commentStatus(c, blog, changes.Body)
+	if e := database.UpdateComment(&comment, changes.Body, status, spamReason); e != nil {
+		return apperror.Internal(e)
+	}
+	comment.SpamReason = ""
+	return helper.WrapResponse(http.StatusOK, "comment updated successfully", &comment).WriteToResponseBody(c.Response())
+}
+
+// DeleteComment may be used by the comment's author, the blog's author and
+// moderators (editors and admins).
+func (h *CommentHandler) DeleteComment(c echo.Context) error {
+	blog, e := h.visibleBlog(c)
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+
+	commentId, e := paramID(c, "comment", "delete failed, comment id not found")
+	if e != nil {
+		return e
+	}
+	comment, e := database.GetCommentByID(blog.ID, commentId)
+	if e != nil {
+		return apperror.Lookup(e, "delete failed, comment id not found")
+	}
+	if comment.UserID != currentUserID(c) && !canModifyBlog(c, blog.UserID) {
+		return apperror.Forbidden("you are not allowed to delete this comment")
+	}
+
+	if e := database.DeleteComment(&comment); e != nil {
+		return apperror.Internal(e)
+	}
+	return helper.WrapResponse(http.StatusOK, "comment deleted successfully", &models.Comment{}).WriteToResponseBody(c.Response())
+}
+
+// GetModerationQueue lists the comments with the status from the query
+// string, pending by default, oldest first.
+func (h *CommentHandler) GetModerationQueue(c echo.Context) error {
+	page, e := helper.ParsePageRequest(c, commentSortFields, "created_at")
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	status := c.QueryParam("status")
+	if status == "" {
+		status = models.CommentStatusPending
+	}
+	if status != models.CommentStatusPending && !models.IsModerationStatus(status) {
+		return apperror.Validation("status must be one of pending, approved, rejected or spam")
+	}
+
+	comments, meta, e := database.GetModerationQueue(status, page)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success get moderation queue", &comments, &meta).WriteToResponseBody(c.Response())
+}
+
+// ModerateComments sets the status of several comments at once. Comments
+// marked as spam or approved train the spam checker.
+func (h *CommentHandler) ModerateComments(c echo.Context) error {
+	moderation := models.CommentModeration{}
+	if err := validation.Bind(c, &moderation); err != nil {
+		return err
+	}
+	if len(moderation.IDs) == 0 || len(moderation.IDs) > models.MaxPageLimit {
+		return apperror.Validation(fmt.Sprintf("ids must list between 1 and %d comments", models.MaxPageLimit))
+	}
+
+	before, after, e := database.ModerateComments(moderation.IDs, moderation.Status, currentUserID(c))
+	if e != nil {
+		if errors.Is(e, database.ErrCommentNotFound) {
+			return apperror.Validation(e.Error())
+		}
+		return apperror.Internal(e)
+	}
+
+	for _, comment := range before {
+		if comment.ModeratedBy != nil {
+			unlearnDecision(comment)
+		}
+		if moderation.Status != models.CommentStatusRejected {
+			spam.Learn(comment.Body, moderation.Status == models.CommentStatusSpam)
+		}
+	}
+
+	return helper.WrapResponse(http.StatusOK, "comments moderated successfully", &after).WriteToResponseBody(c.Response())
+}
+
+// commentStatus decides the status of a new or edited comment. Comments of
+// moderators and of the blog's author are approved right away, spam is
+// held back, the rest is approved when the blog auto approves comments and
+// waits for a moderator otherwise.
+func commentStatus(c echo.Context, blog models.Blog, body string) (string, string) {
+	if isModerator(c) || blog.UserID == currentUserID(c) {
+		return models.CommentStatusApproved, ""
+	}
+	if verdict := spam.Check(body); verdict.Spam {
+		return models.CommentStatusSpam, strings.Join(verdict.Reasons, ", ")
+	}
+	if blog.AutoApproveComments != nil && *blog.AutoApproveComments {
+		return models.CommentStatusApproved, ""
+	}
+	return models.CommentStatusPending, ""
+}
+
+// unlearnDecision takes back what the spam checker learned from the
+// moderator's decision on comment.
+func unlearnDecision(comment models.Comment) {
+	switch comment.Status {
+	case models.CommentStatusSpam, models.CommentStatusApproved:
+		spam.Unlearn(comment.Body, comment.Status == models.CommentStatusSpam)
+	}
+}
+
+func isModerator(c echo.Context) bool {
+	role := currentUserRole(c)
+	return role == models.RoleAdmin || role == models.RoleEditor
+}
+
+// visibleBlog loads the blog of the route if the current user may read it.
+// A blog id that is not a number is a missing record.
+func (h *CommentHandler) visibleBlog(c echo.Context) (models.Blog, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil {
+		return models.Blog{}, gorm.ErrRecordNotFound
+	}
+	return h.blogs.Get(uint(id), currentViewer(c))
+}
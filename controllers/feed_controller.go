@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/config"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/feed"
+	"echo-blog/lib/render"
+	"echo-blog/models"
+	"fmt"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+const feedExcerptLength = 300
+
+// feedConfig is the feed served without query parameters, see SetupFeed.
+var feedConfig = config.Default().Feed
+
+// SetupFeed sets how many blogs the feeds include and whether they carry
+// their whole body.
+func SetupFeed(feed config.FeedConfig) {
+	feedConfig = feed
+}
+
+func GetRSSFeed(c echo.Context) error {
+	return serveFeed(c, feed.Feed.RSS, feed.RSSContentType)
+}
+
+func GetAtomFeed(c echo.Context) error {
+	return serveFeed(c, feed.Feed.Atom, feed.AtomContentType)
+}
+
+func GetJSONFeed(c echo.Context) error {
+	return serveFeed(c, feed.Feed.JSON, feed.JSONContentType)
+}
+
+// serveFeed answers with the latest published blogs, of the author or with
+// the tag from the route when given. feedConfig sets how many blogs are
+// included and whether they carry their whole body or an excerpt, the
+// limit and content query parameters override both. The
+// response has an ETag and Last-Modified, so readers polling an unchanged
+// feed get a 304.
+func serveFeed(c echo.Context, write func(feed.Feed) ([]byte, error), contentType string) error {
+	limit, full, e := feedSettings(c)
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	filter := models.BlogFilter{Author: c.Param("author"), Tag: c.Param("tag")}
+	blogs, e := database.GetFeedBlogs(filter, limit)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	site := helper.SiteURL(c)
+	f := feed.Feed{
+		Title:   helper.SiteTitle(),
+		Link:    site,
+		FeedURL: site + c.Request().URL.RequestURI(),
+	}
+	switch {
+	case filter.Author != "":
+		f.Title += " - posts by " + filter.Author
+	case filter.Tag != "":
+		f.Title += " - posts tagged " + filter.Tag
+	}
+	f.Description = f.Title
+
+	for _, blog := range blogs {
+		item := feed.Item{
+			ID:        fmt.Sprintf("%s/api/v1/blogs/%d", site, blog.ID),
+			Title:     blog.Title,
+			Link:      helper.BlogURL(site, blog.Slug),
+			Summary:   helper.Excerpt(blog.Body, feedExcerptLength),
+			Published: blog.CreatedAt,
+			Updated:   blog.UpdatedAt,
+		}
+		if blog.PublishedAt != nil {
+			item.Published = *blog.PublishedAt
+		}
+		if blog.Author != nil {
+			item.Author = blog.Author.Username
+		}
+		for _, tag := range blog.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+		if blog.BodyHTML != "" {
+			item.Summary = helper.Excerpt(render.Text(blog.BodyHTML), feedExcerptLength)
+		}
+		if full && blog.BodyHTML != "" {
+			item.Content, item.ContentHTML = blog.BodyHTML, true
+		} else if full {
+			item.Content = blog.Body
+		}
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+		f.Items = append(f.Items, item)
+	}
+
+	body, e := write(f)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.ServeCacheable(c, body, contentType, f.Updated)
+}
+
+func feedSettings(c echo.Context) (int, bool, error) {
+	limit, full := feedConfig.Items, feedConfig.FullContent
+
+	if value := c.QueryParam("limit"); value != "" {
+		items, err := strconv.Atoi(value)
+		if err != nil || items < 1 || items > models.MaxPageLimit {
+			return 0, false, fmt.Errorf("limit must be between 1 and %d", models.MaxPageLimit)
+		}
+		limit = items
+	}
+	switch c.QueryParam("content") {
+	case "":
+	case "full":
+		full = true
+	case "excerpt":
+		full = false
+	default:
+		return 0, false, fmt.Errorf("content must be full or excerpt")
+	}
+	return limit, full, nil
+}
@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"echo-blog/apperror"
+	"echo-blog/config"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/imaging"
+	"echo-blog/lib/storage"
+	"echo-blog/models"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// mediaConfig limits the uploads, see SetupMedia.
+var mediaConfig = config.Default().Media
+
+// SetupMedia sets the size and types of the uploads and the widths of the
+// variants made of images.
+func SetupMedia(media config.MediaConfig) {
+	mediaConfig = media
+}
+
+// mediaExtensions are the file types accepted by default, with the
+// extension their files are stored with.
+var mediaExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
+// multipartOverhead is what an upload may send on top of the file: the
+// boundaries and part headers of the form.
+const multipartOverhead = 64 << 10
+
+var mediaSortFields = map[string]string{
+	"created_at": "created_at",
+}
+
+// UploadMedia stores the multipart "file" of the request. The type is
+// sniffed from the content, the file name sent by the client is only kept
+// for display. Images are stored without their metadata and together with
+// a thumbnail and smaller variants, see imaging.Process.
+func UploadMedia(c echo.Context) error {
+	maxSize := mediaConfig.MaxSize
+	tooLarge := apperror.New(http.StatusRequestEntityTooLarge, apperror.CodeTooLarge, fmt.Sprintf("file must not be larger than %d bytes", maxSize))
+
+	// the body is cut off before a file that is too large has been read
+	req := c.Request()
+	req.Body = http.MaxBytesReader(c.Response(), req.Body, maxSize+multipartOverhead)
+	file, e := c.FormFile("file")
+	if e != nil {
+		var maxBytes *http.MaxBytesError
+		if errors.As(e, &maxBytes) {
+			return tooLarge
+		}
+		return apperror.Validation("file is required")
+	}
+	if file.Size > maxSize {
+		return tooLarge
+	}
+
+	src, e := file.Open()
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	defer src.Close()
+
+	data, e := io.ReadAll(src)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !mediaTypeAllowed(contentType) {
+		return apperror.New(http.StatusUnsupportedMediaType, apperror.CodeUnsupportedMedia, fmt.Sprintf("file type %s is not allowed", contentType))
+	}
+
+	media := models.Media{
+		UserID:      currentUserID(c),
+		FileName:    filepath.Base(file.Filename),
+		ContentType: contentType,
+	}
+	if media.Key, e = mediaKey(contentType); e != nil {
+		return apperror.Internal(e)
+	}
+	var variants []imaging.Variant
+	if imaging.IsImage(contentType) {
+		processed, e := imaging.Process(contentType, data, mediaConfig.ImageWidths)
+		if e != nil {
+			return apperror.Validation("file is not a valid image: " + e.Error())
+		}
+		data, variants = processed.Data, processed.Variants
+		media.Width, media.Height = processed.Width, processed.Height
+	}
+	media.Size = int64(len(data))
+
+	ctx := c.Request().Context()
+	stored := []string{}
+	removeStored := func() {
+		for _, key := range stored {
+			storage.Delete(ctx, key)
+		}
+	}
+	if e := storage.Put(ctx, media.Key, bytes.NewReader(data), media.Size, contentType); e != nil {
+		return apperror.Internal(e)
+	}
+	stored = append(stored, media.Key)
+
+	// variants are stored next to the original, "a1b2.jpg" has "a1b2-640w.jpg"
+	base := strings.TrimSuffix(media.Key, filepath.Ext(media.Key))
+	for _, variant := range variants {
+		key := base + "-" + variant.Name + mediaExtension(variant.ContentType)
+		if e := storage.Put(ctx, key, bytes.NewReader(variant.Data), int64(len(variant.Data)), variant.ContentType); e != nil {
+			removeStored()
+			return apperror.Internal(e)
+		}
+		stored = append(stored, key)
+		media.Variants = append(media.Variants, models.MediaVariant{
+			Name:        variant.Name,
+			Key:         key,
+			ContentType: variant.ContentType,
+			Width:       variant.Width,
+			Height:      variant.Height,
+			Size:        int64(len(variant.Data)),
+		})
+	}
+
+	if e := database.CreateMedia(&media); e != nil {
+		removeStored()
+		return apperror.Internal(e)
+	}
+	return helper.WrapResponse(http.StatusOK, "file uploaded successfully", &media).WriteToResponseBody(c.Response())
+}
+
+// GetAllMedia lists the uploads of the current user, editors and admins see
+// every upload.
+func GetAllMedia(c echo.Context) error {
+	page, e := helper.ParsePageRequest(c, mediaSortFields, "created_at")
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	userId := currentUserID(c)
+	if isModerator(c) {
+		userId = 0
+	}
+	media, meta, e := database.GetAllMedia(userId, page)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success get all media", &media, &meta).WriteToResponseBody(c.Response())
+}
+
+func DeleteMedia(c echo.Context) error {
+	id, e := paramID(c, "id", "delete failed, media id not found")
+	if e != nil {
+		return e
+	}
+	media, e := database.GetMediaByID(id)
+	if errors.Is(e, database.ErrMediaNotFound) {
+		return apperror.NotFound("delete failed, media id not found")
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	if media.UserID != currentUserID(c) && !isModerator(c) {
+		return apperror.Forbidden("you are not allowed to delete this file")
+	}
+
+	if e := database.DeleteMedia(media); e != nil {
+		return apperror.Internal(e)
+	}
+	keys := []string{media.Key}
+	for _, variant := range media.Variants {
+		keys = append(keys, variant.Key)
+	}
+	for _, key := range keys {
+		if e := storage.Delete(c.Request().Context(), key); e != nil {
+			log.Printf("cannot delete file %s, error : %v\n", key, e)
+		}
+	}
+	return helper.WrapResponse(http.StatusOK, "file deleted successfully", &models.Media{}).WriteToResponseBody(c.Response())
+}
+
+// ServeMedia serves an uploaded file or one of its variants. Keys are never
+// reused, so the file may be cached for good.
+func ServeMedia(c echo.Context) error {
+	media, e := database.GetMediaFile(c.Param("*"))
+	if errors.Is(e, database.ErrMediaNotFound) {
+		return apperror.NotFound("file not found")
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	file, e := storage.Open(c.Request().Context(), media.Key)
+	if errors.Is(e, storage.ErrNotFound) {
+		return apperror.NotFound("file not found")
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	defer file.Close()
+
+	sum := sha256.Sum256([]byte(media.Key))
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, media.ContentType)
+	header.Set("ETag", etag)
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	header.Set("X-Content-Type-Options", "nosniff")
+	header.Set(echo.HeaderContentDisposition, mime.FormatMediaType("inline", map[string]string{"filename": media.FileName}))
+
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		http.ServeContent(c.Response(), c.Request(), "", media.CreatedAt, seeker)
+		return nil
+	}
+	if c.Request().Header.Get("If-None-Match") == etag {
+		return c.NoContent(http.StatusNotModified)
+	}
+	header.Set(echo.HeaderLastModified, media.CreatedAt.UTC().Format(http.TimeFormat))
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(media.Size, 10))
+	return c.Stream(http.StatusOK, media.ContentType, file)
+}
+
+// mediaTypeAllowed checks contentType against the allowed types of
+// mediaConfig, or the types of mediaExtensions when there are none.
+func mediaTypeAllowed(contentType string) bool {
+	if len(mediaConfig.AllowedTypes) == 0 {
+		_, ok := mediaExtensions[contentType]
+		return ok
+	}
+	for _, t := range mediaConfig.AllowedTypes {
+		if t == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+// mediaKey makes a new random key in a folder per month, like
+// "2024/05/3f2a9c0d1e2b4a5f6a7b8c9d0e1f2a3b.png".
+func mediaKey(contentType string) (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+
+	return time.Now().Format("2006/01/") + hex.EncodeToString(random) + mediaExtension(contentType), nil
+}
+
+func mediaExtension(contentType string) string {
+	if extension, ok := mediaExtensions[contentType]; ok {
+		return extension
+	}
+	if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+		return extensions[0]
+	}
+	return ""
+}
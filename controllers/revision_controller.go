@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/dto"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"echo-blog/service"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RevisionHandler serves the revisions of blogs, see routes.New.
+type RevisionHandler struct {
+	blogs *service.BlogService
+}
+
+func NewRevisionHandler(blogs *service.BlogService) *RevisionHandler {
+	return &RevisionHandler{blogs: blogs}
+}
+
+func (h *RevisionHandler) GetBlogRevisions(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
+
+	if e := h.authorize(id, c, "you are not allowed to see the revisions of this blog"); e != nil {
+		return e
+	}
+
+	revisions, e := database.GetBlogRevisions(id)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get blog revisions", &revisions).WriteToResponseBody(c.Response())
+}
+
+func (h *RevisionHandler) GetBlogRevision(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
+
+	if e := h.authorize(id, c, "you are not allowed to see the revisions of this blog"); e != nil {
+		return e
+	}
+
+	number, _ := strconv.Atoi(c.Param("revision"))
+	revision, e := database.GetBlogRevision(id, number)
+	if e != nil {
+		return apperror.Lookup(e, "revision not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get blog revision", &revision).WriteToResponseBody(c.Response())
+}
+
+func (h *RevisionHandler) DiffBlogRevisions(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
+
+	if e := h.authorize(id, c, "you are not allowed to see the revisions of this blog"); e != nil {
+		return e
+	}
+
+	from, errFrom := strconv.Atoi(c.QueryParam("from"))
+	to, errTo := strconv.Atoi(c.QueryParam("to"))
+	if errFrom != nil || errTo != nil {
+		return apperror.Validation("from and to revision numbers are required")
+	}
+
+	fromRevision, e := database.GetBlogRevision(id, from)
+	if e != nil {
+		return apperror.Lookup(e, "revision not found")
+	}
+	toRevision, e := database.GetBlogRevision(id, to)
+	if e != nil {
+		return apperror.Lookup(e, "revision not found")
+	}
+
+	diff := models.BlogRevisionDiff{
+		BlogID: fromRevision.BlogID,
+		From:   from,
+		To:     to,
+		Lines:  helper.DiffLines(fromRevision.Body, toRevision.Body),
+	}
+	return helper.WrapResponse(http.StatusOK, "success diff blog revisions", &diff).WriteToResponseBody(c.Response())
+}
+
+func (h *RevisionHandler) RestoreBlogRevision(c echo.Context) error {
+	id, err := paramID(c, "id", "blog not found")
+	if err != nil {
+		return err
+	}
+
+	if e := h.authorize(id, c, "you are not allowed to update this blog"); e != nil {
+		return e
+	}
+
+	number, _ := strconv.Atoi(c.Param("revision"))
+	blog, e := database.RestoreBlogRevision(id, number, currentUserID(c))
+	if e != nil {
+		return apperror.Lookup(e, "revision not found")
+	}
+	reindexBlog(id)
+
+	return helper.WrapResponse(http.StatusOK, "blog revision restored successfully", dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+// authorize answers Forbidden with message unless the current user may
+// modify the blog with id.
+func (h *RevisionHandler) authorize(id uint, c echo.Context, message string) error {
+	e := h.blogs.Authorize(id, currentViewer(c))
+	if errors.Is(e, service.ErrForbidden) {
+		return apperror.Forbidden(message)
+	}
+	if e != nil {
+		return apperror.Lookup(e, "blog not found")
+	}
+	return nil
+}
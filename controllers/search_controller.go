@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/dto"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/search"
+	"echo-blog/models"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+func SearchBlogs(c echo.Context) error {
+	query := strings.TrimSpace(c.QueryParam("q"))
+	if query == "" {
+		return apperror.Validation("q is required")
+	}
+
+	page, e := helper.ParsePageNumber(c)
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	if search.Engine == nil {
+		return apperror.Internal(search.ErrNotConfigured)
+	}
+	hits, total, e := search.Engine.Search(query, page.Limit, page.Offset())
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	ids := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.BlogID)
+	}
+	blogs, e := database.GetPublishedBlogsByIDs(ids)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	results := make([]dto.BlogSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if blog, ok := blogs[hit.BlogID]; ok {
+			results = append(results, dto.BlogSearchResult{Blog: dto.NewBlog(blog, dto.Viewer{}), Score: hit.Score, Snippet: hit.Snippet})
+		}
+	}
+
+	meta := models.PageMeta{
+		Total:   total,
+		Page:    page.Page,
+		Limit:   page.Limit,
+		HasNext: int64(page.Offset()+len(hits)) < total,
+		HasPrev: page.Page > 1,
+	}
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success search blogs", &results, &meta).WriteToResponseBody(c.Response())
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/config"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/sitemap"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetSitemap lists every published blog, or when there are more than
+// sitemap.MaxURLs of them, points to the numbered sitemaps at
+// /sitemaps/:page.xml holding them. /sitemap.xml.gz is the same gzipped.
+func GetSitemap(c echo.Context) error {
+	gz := strings.HasSuffix(c.Request().URL.Path, ".gz")
+	count, e := database.CountPublishedBlogs()
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	pages := sitemap.Pages(count)
+	if pages == 1 {
+		return serveSitemapPage(c, 1, gz)
+	}
+
+	extension := ".xml"
+	if gz {
+		extension += ".gz"
+	}
+	site := helper.SiteURL(c)
+	locs := make([]string, 0, pages)
+	for page := 1; page <= pages; page++ {
+		locs = append(locs, fmt.Sprintf("%s/sitemaps/%d%s", site, page, extension))
+	}
+	body, e := sitemap.Index(locs)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	return writeSitemap(c, body, gz, time.Time{})
+}
+
+func GetSitemapPage(c echo.Context) error {
+	file := c.Param("file")
+	gz := strings.HasSuffix(file, ".gz")
+	number, found := strings.CutSuffix(strings.TrimSuffix(file, ".gz"), ".xml")
+	page, e := strconv.Atoi(number)
+	if !found || e != nil || page < 1 {
+		return apperror.NotFound("sitemap not found")
+	}
+
+	count, e := database.CountPublishedBlogs()
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	if page > sitemap.Pages(count) {
+		return apperror.NotFound("sitemap not found")
+	}
+	return serveSitemapPage(c, page, gz)
+}
+
+func serveSitemapPage(c echo.Context, page int, gz bool) error {
+	blogs, e := database.GetSitemapBlogs((page-1)*sitemap.MaxURLs, sitemap.MaxURLs)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	site := helper.SiteURL(c)
+	urls := make([]sitemap.URL, 0, len(blogs))
+	var modified time.Time
+	for _, blog := range blogs {
+		urls = append(urls, sitemap.URL{Loc: helper.BlogURL(site, blog.Slug), LastMod: blog.UpdatedAt})
+		if blog.UpdatedAt.After(modified) {
+			modified = blog.UpdatedAt
+		}
+	}
+	body, e := sitemap.URLSet(urls)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	return writeSitemap(c, body, gz, modified)
+}
+
+func writeSitemap(c echo.Context, body []byte, gz bool, modified time.Time) error {
+	if !gz {
+		return helper.ServeCacheable(c, body, sitemap.ContentType, modified)
+	}
+	body, e := sitemap.Gzip(body)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	return helper.ServeCacheable(c, body, sitemap.GzipContentType, modified)
+}
+
+// robotsConfig sets robots.txt, see SetupRobots.
+var robotsConfig = config.Default().Robots
+
+// SetupRobots sets the file served as robots.txt, or the paths the
+// generated one disallows.
+func SetupRobots(robots config.RobotsConfig) {
+	robotsConfig = robots
+}
+
+// GetRobots serves the robots file when set. Otherwise it allows every
+// crawler except on the disallowed paths and points them to the sitemap.
+func GetRobots(c echo.Context) error {
+	if file := robotsConfig.File; file != "" {
+		body, e := os.ReadFile(file)
+		if e != nil {
+			return apperror.Internal(e)
+		}
+		return c.Blob(http.StatusOK, echo.MIMETextPlainCharsetUTF8, body)
+	}
+
+	var robots strings.Builder
+	robots.WriteString("User-agent: *\n")
+	for _, path := range robotsConfig.Disallow {
+		robots.WriteString("Disallow: " + path + "\n")
+	}
+	if len(robotsConfig.Disallow) == 0 {
+		robots.WriteString("Disallow:\n")
+	}
+	robots.WriteString("\nSitemap: " + helper.SiteURL(c) + "/sitemap.xml\n")
+	return c.String(http.StatusOK, robots.String())
+}
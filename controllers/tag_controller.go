@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/lib/validation"
+	"echo-blog/models"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func GetAllTags(c echo.Context) error {
+	tags, e := database.GetAllTags()
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get all tag", &tags).WriteToResponseBody(c.Response())
+}
+
+// GetTagCloud lists the most used tags with their blog counts, limit says
+// how many.
+func GetTagCloud(c echo.Context) error {
+	page, e := helper.ParsePageNumber(c)
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	tags, e := database.GetTagCloud(page.Limit)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get tag cloud", &tags).WriteToResponseBody(c.Response())
+}
+
+func GetTagByID(c echo.Context) error {
+	id, err := paramID(c, "id", "tag not found")
+	if err != nil {
+		return err
+	}
+
+	tag, e := database.GetTagByID(id)
+	if e != nil {
+		return apperror.Lookup(e, "tag not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, "success get tag by id", &tag).WriteToResponseBody(c.Response())
+}
+
+func AddNewTag(c echo.Context) error {
+	tag := models.Tag{}
+	if err := validation.Bind(c, &tag); err != nil {
+		return err
+	}
+
+	if err := database.CreateTag(&tag); err != nil {
+		return tagError(err, "failed to add new tag")
+	}
+	return helper.WrapResponse(http.StatusOK, "new tag added successfully", &tag).WriteToResponseBody(c.Response())
+}
+
+func UpdateTag(c echo.Context) error {
+	id, err := paramID(c, "id", "tag not found")
+	if err != nil {
+		return err
+	}
+
+	tag := models.Tag{}
+	if err := validation.BindChanges(c, &tag, id); err != nil {
+		return err
+	}
+
+	updatedTag, e := database.UpdateTag(id, tag)
+	if e != nil {
+		return tagError(e, "update failed, tag id not found")
+	}
+
+	return helper.WrapResponse(http.StatusOK, "tag updated successfully", &updatedTag).WriteToResponseBody(c.Response())
+}
+
+func DeleteTag(c echo.Context) error {
+	id, err := paramID(c, "id", "tag not found")
+	if err != nil {
+		return err
+	}
+
+	if _, e := database.DeleteTagByID(id); e != nil {
+		return apperror.Lookup(e, "delete failed, tag id not found")
+	}
+	return helper.WrapResponse(http.StatusOK, "tag deleted successfully", &models.Tag{}).WriteToResponseBody(c.Response())
+}
+
+// tagError maps the errors of saving a tag, notFound is the message for a
+// missing tag.
+func tagError(err error, notFound string) error {
+	switch {
+	case errors.Is(err, database.ErrTagExists):
+		return apperror.Conflict(err.Error())
+	case errors.Is(err, database.ErrNameWithoutSlug):
+		return apperror.Validation(err.Error())
+	}
+	return apperror.Lookup(err, notFound)
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/dto"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+var trashSortFields = map[string]string{
+	"created_at": "created_at",
+	"deleted_at": "deleted_at",
+}
+
+// GetTrashedBlogs lists the trashed blogs of the current user, editors and
+// admins see every trashed blog.
+func GetTrashedBlogs(c echo.Context) error {
+	page, e := helper.ParsePageRequest(c, trashSortFields, "-deleted_at")
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	userId := currentUserID(c)
+	if isModerator(c) {
+		userId = 0
+	}
+	blogs, meta, e := database.GetTrashedBlogs(userId, page)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success get trashed blogs", dto.NewBlogs(blogs, currentViewer(c)), &meta).WriteToResponseBody(c.Response())
+}
+
+func RestoreBlog(c echo.Context) error {
+	id, e := paramID(c, "id", "restore failed, blog id not found in trash")
+	if e != nil {
+		return e
+	}
+
+	authorId, e := database.GetTrashedBlogAuthorID(id)
+	if errors.Is(e, database.ErrNotInTrash) {
+		return apperror.NotFound("restore failed, blog id not found in trash")
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	if !canModifyBlog(c, authorId) {
+		return apperror.Forbidden("you are not allowed to restore this blog")
+	}
+
+	blog, e := database.RestoreBlog(id)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	reindexBlog(id)
+	return helper.WrapResponse(http.StatusOK, "blog restored successfully", dto.NewBlog(blog, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+func PurgeBlog(c echo.Context) error {
+	id, e := paramID(c, "id", "purge failed, blog id not found in trash")
+	if e != nil {
+		return e
+	}
+	e = database.PurgeBlog(id)
+	if errors.Is(e, database.ErrNotInTrash) {
+		return apperror.NotFound("purge failed, blog id not found in trash")
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	return helper.WrapResponse(http.StatusOK, "blog purged successfully", &models.Blog{}).WriteToResponseBody(c.Response())
+}
+
+func GetTrashedUsers(c echo.Context) error {
+	page, e := helper.ParsePageRequest(c, trashSortFields, "-deleted_at")
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
+
+	users, meta, e := database.GetTrashedUsers(page)
+	if e != nil {
+		return apperror.Internal(e)
+	}
+
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success get trashed users", dto.NewUsers(users, currentViewer(c)), &meta).WriteToResponseBody(c.Response())
+}
+
+func RestoreUser(c echo.Context) error {
+	id, e := paramID(c, "id", "restore failed, user id not found in trash")
+	if e != nil {
+		return e
+	}
+	user, e := database.RestoreUser(id)
+	if errors.Is(e, database.ErrNotInTrash) {
+		return apperror.NotFound("restore failed, user id not found in trash")
+	}
+	if errors.Is(e, database.ErrUserTaken) {
+		return apperror.Conflict("restore failed, " + e.Error())
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	return helper.WrapResponse(http.StatusOK, "user restored successfully", dto.NewUser(user, currentViewer(c))).WriteToResponseBody(c.Response())
+}
+
+func PurgeUser(c echo.Context) error {
+	id, e := paramID(c, "id", "purge failed, user id not found in trash")
+	if e != nil {
+		return e
+	}
+	e = database.PurgeUser(id)
+	if errors.Is(e, database.ErrNotInTrash) {
+		return apperror.NotFound("purge failed, user id not found in trash")
+	}
+	if errors.Is(e, database.ErrUserOwnsBlogs) {
+		return apperror.Conflict("purge failed, " + e.Error())
+	}
+	if e != nil {
+		return apperror.Internal(e)
+	}
+	return helper.WrapResponse(http.StatusOK, "user purged successfully", &models.User{}).WriteToResponseBody(c.Response())
+}
@@ -1,111 +1,142 @@
 package controllers
 
 import (
-	"echo-blog/config"
+	"echo-blog/apperror"
+	"echo-blog/dto"
 	"echo-blog/helper"
-	"echo-blog/lib/database"
+	"echo-blog/lib/validation"
 	"echo-blog/models"
-	"fmt"
+	"echo-blog/service"
+	"errors"
 	"net/http"
-	"strconv"
 
 	"github.com/labstack/echo/v4"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func GetAllUser(c echo.Context) error {
-	users, e := database.GetAllUsers()
-	if e != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, e.Error())
-	}
-
-	return helper.WrapResponse(http.StatusOK, "success get all user", &users).WriteToResponseBody(c.Response())
-
+var userSortFields = map[string]string{
+	"created_at": "created_at",
+	"username":   "username",
+	"email":      "email",
 }
 
-func GetUserByID(c echo.Context) error {
-	id := c.Param("id")
+// UserHandler serves the user and login routes, see routes.New.
+type UserHandler struct {
+	users *service.UserService
+}
 
-	user, e := database.GetUserByID(id)
+func NewUserHandler(users *service.UserService) *UserHandler {
+	return &UserHandler{users: users}
+}
 
+func (h *UserHandler) GetAllUser(c echo.Context) error {
+	page, e := helper.ParsePageRequest(c, userSortFields, "created_at")
 	if e != nil {
-		return helper.WrapResponse(http.StatusBadRequest, "user not found", e.Error()).WriteToResponseBody(c.Response())
+		return apperror.Validation(e.Error())
 	}
 
-	return helper.WrapResponse(http.StatusOK, "success get user by id", &user).WriteToResponseBody(c.Response())
-}
+	from, to, e := helper.ParseDateRange(c)
+	if e != nil {
+		return apperror.Validation(e.Error())
+	}
 
-func AddNewUser(c echo.Context) error {
-	user := models.User{}
-	c.Bind(&user)
+	filter := models.UserFilter{
+		Role: c.QueryParam("role"),
+		From: from,
+		To:   to,
+	}
+	if filter.Role != "" && !models.IsValidRole(filter.Role) {
+		return apperror.Validation("role must be one of admin, editor or author")
+	}
 
-	if err := user.ValidatorSanitizer(); err != nil {
-		return helper.WrapResponse(http.StatusBadRequest, err.Error(), &models.User{}).WriteToResponseBody(c.Response())
+	users, meta, e := h.users.List(filter, page)
+	if e != nil {
+		return apperror.Internal(e)
 	}
 
-	// Hash the user's password before saving it
-	hashedPassword, err := hashPassword(user.Password)
+	helper.PageLinks(c, &meta)
+	return helper.WrapPagedResponse(http.StatusOK, "success get all user", dto.NewUsers(users, currentViewer(c)), &meta).WriteToResponseBody(c.Response())
+
+}
+
+func (h *UserHandler) GetUserByID(c echo.Context) error {
+	id, err := paramID(c, "id", "user not found")
 	if err != nil {
-		return helper.WrapResponse(http.StatusInternalServerError, "failed to hash password", err.Error()).WriteToResponseBody(c.Response())
+		return err
 	}
-	user.Password = hashedPassword
 
-	if err := config.DB.Save(&user).Error; err != nil {
-		return helper.WrapResponse(http.StatusBadRequest, "failed to add new user", err.Error()).WriteToResponseBody(c.Response())
+	user, e := h.users.Get(id)
+
+	if e != nil {
+		return apperror.Lookup(e, "user not found")
 	}
-	return helper.WrapResponse(http.StatusOK, "new user added successfully", &user).WriteToResponseBody(c.Response())
+
+	return helper.WrapResponse(http.StatusOK, "success get user by id", dto.NewUser(user, currentViewer(c))).WriteToResponseBody(c.Response())
 }
 
-func hashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+func (h *UserHandler) AddNewUser(c echo.Context) error {
+	request := dto.UserRequest{}
+	if err := validation.Bind(c, &request, validation.WithEmailChecker(h.users)); err != nil {
+		return err
+	}
+
+	user, err := h.users.Register(request.Model())
+	if errors.Is(err, service.ErrEmailTaken) {
+		return validation.Taken("email")
+	}
 	if err != nil {
-		return "", err
+		return apperror.Internal(err)
 	}
-	return string(hashedPassword), nil
+	return helper.WrapResponse(http.StatusOK, "new user added successfully", dto.NewUser(user, dto.Viewer{ID: user.ID})).WriteToResponseBody(c.Response())
 }
 
-func UpdateUser(c echo.Context) error {
+func (h *UserHandler) UpdateUser(c echo.Context) error {
 
-	idParams := c.Param("id")
-	id, _ := strconv.Atoi(idParams)
-
-	user := models.User{}
-	c.Bind(&user)
+	id, err := paramID(c, "id", "user not found")
+	if err != nil {
+		return err
+	}
 
-	if rowsAff := config.DB.Model(&user).Where("id = ?", id).Updates(user).RowsAffected; rowsAff == 0 {
-		return helper.WrapResponse(http.StatusBadRequest, "failed to update user, user id not found", &models.User{}).WriteToResponseBody(c.Response())
+	request := dto.UserRequest{}
+	if err := validation.BindChanges(c, &request, id, validation.WithEmailChecker(h.users)); err != nil {
+		return err
 	}
 
-	return helper.WrapResponse(http.StatusOK, "user updated successfully", &user).WriteToResponseBody(c.Response())
+	updatedUser, e := h.users.Update(id, request.Model())
+	if errors.Is(e, service.ErrEmailTaken) {
+		return validation.Taken("email")
+	}
+	if e != nil {
+		return apperror.Lookup(e, "failed to update user, user id not found")
+	}
+	return helper.WrapResponse(http.StatusOK, "user updated successfully", dto.NewUser(updatedUser, currentViewer(c))).WriteToResponseBody(c.Response())
 }
 
-func DeleteUser(c echo.Context) error {
-	id := c.Param("id")
+func (h *UserHandler) DeleteUser(c echo.Context) error {
+	id, err := paramID(c, "id", "user not found")
+	if err != nil {
+		return err
+	}
 
-	deletedUser, e := database.DeleteUserByID(id)
+	e := h.users.Delete(id)
 
 	if e != nil {
-		return helper.WrapResponse(http.StatusBadRequest, "failed to delete user, id not found", e.Error()).WriteToResponseBody(c.Response())
+		return apperror.Lookup(e, "failed to delete user, id not found")
 	}
-	return helper.WrapResponse(http.StatusOK, "user deleted successfully", deletedUser).WriteToResponseBody(c.Response())
+	return helper.WrapResponse(http.StatusOK, "user deleted successfully", &dto.User{}).WriteToResponseBody(c.Response())
 }
 
-func LoginUser(c echo.Context) error {
-	user := models.User{}
-	c.Bind(&user)
-	users, e := database.LoginUser(&user)
+func (h *UserHandler) LoginUser(c echo.Context) error {
+	request := dto.LoginRequest{}
+	if err := validation.Bind(c, &request); err != nil {
+		return err
+	}
+	user, pair, e := h.users.Login(request.Email, request.Password)
 
+	if errors.Is(e, service.ErrWrongCredentials) {
+		return apperror.Unauthorized(e.Error())
+	}
 	if e != nil {
-		fmt.Println(e)
-		if e.Error() == "record not found" {
-			return echo.NewHTTPError(http.StatusBadRequest, "wrong email or password")
-		} else if e.Error() == "crypto/bcrypt: hashedPassword is not the hash of the given password" {
-			return echo.NewHTTPError(http.StatusBadRequest, "wrong email or password")
-		} else {
-			return echo.NewHTTPError(http.StatusInternalServerError, e.Error())
-		}
-	}
-	user.Password = ""
-	return helper.WrapResponse(http.StatusOK, "login successfully", &users).WriteToResponseBody(c.Response())
-
+		return apperror.Internal(e)
+	}
+	return helper.WrapResponse(http.StatusOK, "login successfully", dto.NewSession(user, pair)).WriteToResponseBody(c.Response())
 }
@@ -0,0 +1,84 @@
+package dto
+
+import (
+	"echo-blog/models"
+	"time"
+)
+
+// Blog is a blog as the API returns it. When it is scheduled and how its
+// comments are moderated are only shown to its author, editors and admins,
+// DeletedAt only to them while the blog is in the trash.
+type Blog struct {
+	ID          uint           `json:"ID"`
+	CreatedAt   time.Time      `json:"CreatedAt"`
+	UpdatedAt   time.Time      `json:"UpdatedAt"`
+	DeletedAt   *time.Time     `json:"DeletedAt,omitempty"`
+	Title       string         `json:"title"`
+	Body        string         `json:"body"`
+	Slug        string         `json:"slug"`
+	Status      string         `json:"status"`
+	PublishedAt *time.Time     `json:"publishedAt"`
+	ScheduledAt *time.Time     `json:"scheduledAt,omitempty"`
+	UserID      uint           `json:"userId"`
+	Author      *models.Author `json:"author,omitempty"`
+
+	CategoryID *uint            `json:"categoryId"`
+	Category   *models.Category `json:"category,omitempty"`
+	Tags       []models.Tag     `json:"tags"`
+
+	ContentFormat string            `json:"contentFormat"`
+	BodyHTML      string            `json:"bodyHtml"`
+	TOC           []models.TOCEntry `json:"toc"`
+
+	FeaturedMediaID *uint         `json:"featuredMediaId"`
+	FeaturedMedia   *models.Media `json:"featuredMedia,omitempty"`
+
+	AutoApproveComments *bool `json:"autoApproveComments,omitempty"`
+}
+
+func NewBlog(blog models.Blog, viewer Viewer) Blog {
+	response := Blog{
+		ID:              blog.ID,
+		CreatedAt:       blog.CreatedAt,
+		UpdatedAt:       blog.UpdatedAt,
+		Title:           blog.Title,
+		Body:            blog.Body,
+		Slug:            blog.Slug,
+		Status:          blog.Status,
+		PublishedAt:     blog.PublishedAt,
+		UserID:          blog.UserID,
+		Author:          blog.Author,
+		CategoryID:      blog.CategoryID,
+		Category:        blog.Category,
+		Tags:            blog.Tags,
+		ContentFormat:   blog.ContentFormat,
+		BodyHTML:        blog.BodyHTML,
+		TOC:             blog.TOC,
+		FeaturedMediaID: blog.FeaturedMediaID,
+		FeaturedMedia:   blog.FeaturedMedia,
+	}
+	if viewer.ID == blog.UserID || viewer.IsModerator() {
+		response.ScheduledAt = blog.ScheduledAt
+		response.AutoApproveComments = blog.AutoApproveComments
+		if blog.DeletedAt.Valid {
+			response.DeletedAt = &blog.DeletedAt.Time
+		}
+	}
+	return response
+}
+
+func NewBlogs(blogs []models.Blog, viewer Viewer) []Blog {
+	responses := make([]Blog, 0, len(blogs))
+	for _, blog := range blogs {
+		responses = append(responses, NewBlog(blog, viewer))
+	}
+	return responses
+}
+
+// BlogSearchResult is a blog found by a search, Snippet is the part of the
+// body matching the query as HTML with the matching words in <mark>.
+type BlogSearchResult struct {
+	Blog    Blog    `json:"blog"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
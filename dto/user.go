@@ -0,0 +1,83 @@
+package dto
+
+import (
+	"echo-blog/models"
+	"time"
+)
+
+// User is a user as the API returns it. The password hash and stored token
+// are never part of it. Email and role are shown to the user themselves
+// and to admins, DeletedAt only to admins.
+type User struct {
+	ID        uint       `json:"ID"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+	UpdatedAt time.Time  `json:"UpdatedAt"`
+	DeletedAt *time.Time `json:"DeletedAt,omitempty"`
+	Username  string     `json:"username"`
+	Email     string     `json:"email,omitempty"`
+	Role      string     `json:"role,omitempty"`
+}
+
+func NewUser(user models.User, viewer Viewer) User {
+	response := User{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Username:  user.Username,
+	}
+	if viewer.ID == user.ID || viewer.IsAdmin() {
+		response.Email = user.Email
+		response.Role = user.Role
+	}
+	if viewer.IsAdmin() && user.DeletedAt.Valid {
+		response.DeletedAt = &user.DeletedAt.Time
+	}
+	return response
+}
+
+func NewUsers(users []models.User, viewer Viewer) []User {
+	responses := make([]User, 0, len(users))
+	for _, user := range users {
+		responses = append(responses, NewUser(user, viewer))
+	}
+	return responses
+}
+
+// Session is returned on login, the user with the tokens of the new session.
+type Session struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
+
+func NewSession(user models.User, pair *models.TokenPair) Session {
+	return Session{
+		User:         NewUser(user, Viewer{ID: user.ID, Role: user.Role}),
+		Token:        pair.Token,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    pair.ExpiresIn,
+	}
+}
+
+// UserRequest is the body to register or update a user.
+type UserRequest struct {
+	Username string `json:"username" form:"username" validate:"required,min=3,max=50"`
+	Email    string `json:"email" form:"email" validate:"required,email,max=191,unique_email"`
+	Password string `json:"password" form:"password" validate:"required,password"`
+	Role     string `json:"role" form:"role" validate:"omitempty,oneof=admin editor author"`
+}
+
+func (request UserRequest) Model() models.User {
+	return models.User{
+		Username: request.Username,
+		Email:    request.Email,
+		Password: request.Password,
+		Role:     request.Role,
+	}
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" form:"email" validate:"required"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
@@ -0,0 +1,22 @@
+// Package dto holds the representations of users and blogs in API requests
+// and responses, separate from the models saved in the database. Responses
+// are built for a Viewer, fields only some viewers may see are left out for
+// everybody else.
+package dto
+
+import "echo-blog/models"
+
+// Viewer is the user a response is built for, the zero Viewer is an
+// anonymous visitor.
+type Viewer struct {
+	ID   uint
+	Role string
+}
+
+func (viewer Viewer) IsAdmin() bool {
+	return viewer.Role == models.RoleAdmin
+}
+
+func (viewer Viewer) IsModerator() bool {
+	return viewer.Role == models.RoleAdmin || viewer.Role == models.RoleEditor
+}
@@ -0,0 +1,31 @@
+package helper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ServeCacheable writes a generated document with an ETag of its body and
+// modified as Last-Modified, answering conditional requests for an
+// unchanged document with a 304. Without a site URL the links in the
+// document come from the Host of the request, shared caches must not keep
+// them.
+func ServeCacheable(c echo.Context, body []byte, contentType string, modified time.Time) error {
+	sum := sha256.Sum256(body)
+	header := c.Response().Header()
+	header.Set(echo.HeaderContentType, contentType)
+	header.Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	if siteURL != "" {
+		header.Set("Cache-Control", "public, max-age=300")
+	} else {
+		header.Set("Cache-Control", "private, max-age=300")
+		header.Add("Vary", "Host")
+	}
+	http.ServeContent(c.Response(), c.Request(), "", modified.Truncate(time.Second), bytes.NewReader(body))
+	return nil
+}
@@ -0,0 +1,132 @@
+package helper
+
+import (
+	"echo-blog/models"
+	"strings"
+)
+
+// maxDiffEdits bounds the work of DiffLines, the search keeps a trace that
+// grows with the square of the number of edits. Bodies further apart are
+// shown as replaced as a whole.
+const maxDiffEdits = 1000
+
+// DiffLines returns the line-level edit script turning a into b, using the
+// Myers algorithm so the result is a shortest one as long as it needs no
+// more than maxDiffEdits inserted and deleted lines.
+func DiffLines(a, b string) []models.DiffLine {
+	x, y := splitLines(a), splitLines(b)
+
+	// the lines shared at the start and at the end are not searched
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	var lines []models.DiffLine
+	lines = appendLines(lines, models.DiffEqual, x[:prefix])
+	middle, ok := myersDiff(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix], maxDiffEdits)
+	if ok {
+		lines = append(lines, middle...)
+	} else {
+		lines = appendLines(lines, models.DiffDelete, x[prefix:len(x)-suffix])
+		lines = appendLines(lines, models.DiffInsert, y[prefix:len(y)-suffix])
+	}
+	return appendLines(lines, models.DiffEqual, x[len(x)-suffix:])
+}
+
+// myersDiff returns a shortest edit script turning x into y, false when it
+// needs more than limit edits.
+func myersDiff(x, y []string, limit int) ([]models.DiffLine, bool) {
+	n, m := len(x), len(y)
+	max := n + m
+
+	// v[max+k] is the furthest x reached on diagonal k. trace[d] keeps the
+	// diagonals -d..d of v as they were before round d, the only ones round d
+	// reads, so the path can be walked back afterwards
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	found := false
+
+search:
+	for d := 0; d <= max && d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[max-d:max+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[max+k-1] < v[max+k+1]) {
+				i = v[max+k+1]
+			} else {
+				i = v[max+k-1] + 1
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[max+k] = i
+			if i >= n && j >= m {
+				found = true
+				break search
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	var lines []models.DiffLine
+	i, j := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := i - j
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		var prevI int
+		if d > 0 {
+			prevI = v[d+prevK]
+		}
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			lines = append(lines, models.DiffLine{Op: models.DiffEqual, Text: x[i-1]})
+			i--
+			j--
+		}
+		if d > 0 {
+			if i == prevI {
+				lines = append(lines, models.DiffLine{Op: models.DiffInsert, Text: y[j-1]})
+			} else {
+				lines = append(lines, models.DiffLine{Op: models.DiffDelete, Text: x[i-1]})
+			}
+		}
+		i, j = prevI, prevJ
+	}
+
+	for l, r := 0, len(lines)-1; l < r; l, r = l+1, r-1 {
+		lines[l], lines[r] = lines[r], lines[l]
+	}
+	return lines, true
+}
+
+func appendLines(lines []models.DiffLine, op string, texts []string) []models.DiffLine {
+	for _, text := range texts {
+		lines = append(lines, models.DiffLine{Op: op, Text: text})
+	}
+	return lines
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	return lines
+}
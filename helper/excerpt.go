@@ -0,0 +1,32 @@
+package helper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Excerpt shortens text to at most length characters without cutting a
+// word, collapsing whitespace and adding "…" when something was left out.
+func Excerpt(text string, length int) string {
+	words := strings.Fields(text)
+	var excerpt strings.Builder
+	size := 0
+	for i, word := range words {
+		wordSize := len([]rune(word))
+		if i > 0 {
+			wordSize++
+		}
+		if size+wordSize > length {
+			if i == 0 {
+				return string([]rune(word)[:length]) + "…"
+			}
+			return strings.TrimRightFunc(excerpt.String(), unicode.IsPunct) + "…"
+		}
+		if i > 0 {
+			excerpt.WriteByte(' ')
+		}
+		excerpt.WriteString(word)
+		size += wordSize
+	}
+	return excerpt.String()
+}
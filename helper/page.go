@@ -0,0 +1,159 @@
+package helper
+
+import (
+	"echo-blog/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+func WrapPagedResponse(code int, status string, response interface{}, meta *models.PageMeta) *models.WebResponse {
+	newResponse := WrapResponse(code, status, response)
+	newResponse.Meta = meta
+	return newResponse
+}
+
+// ParsePageRequest reads page, limit, sort, after and before from the query
+// string. sortable lists the accepted sort fields mapped to their columns,
+// a leading - sorts descending.
+func ParsePageRequest(c echo.Context, sortable map[string]string, defaultSort string) (models.PageRequest, error) {
+	page, err := ParsePageNumber(c)
+	if err != nil {
+		return page, err
+	}
+
+	sortBy := c.QueryParam("sort")
+	if sortBy == "" {
+		sortBy = defaultSort
+	}
+	page.Desc = strings.HasPrefix(sortBy, "-")
+	column, ok := sortable[strings.TrimPrefix(sortBy, "-")]
+	if !ok {
+		return page, fmt.Errorf("sort must be one of %s", sortFields(sortable))
+	}
+	page.Sort = column
+
+	if value := c.QueryParam("after"); value != "" {
+		if page.After, err = models.DecodeCursor(value); err != nil {
+			return page, err
+		}
+	}
+	if value := c.QueryParam("before"); value != "" {
+		if page.Before, err = models.DecodeCursor(value); err != nil {
+			return page, err
+		}
+	}
+	if page.After != nil && page.Before != nil {
+		return page, fmt.Errorf("after and before cannot be used together")
+	}
+	if page.Keyset() && page.Sort != "created_at" {
+		return page, fmt.Errorf("after and before can only be used when sorting by created_at")
+	}
+	if page.Keyset() {
+		page.Page = 0
+	}
+
+	return page, nil
+}
+
+// ParsePageNumber reads only page and limit, for lists that have a fixed
+// order.
+func ParsePageNumber(c echo.Context) (models.PageRequest, error) {
+	page := models.PageRequest{Page: 1, Limit: models.DefaultPageLimit}
+
+	if value := c.QueryParam("page"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			return page, fmt.Errorf("page must be a positive number")
+		}
+		page.Page = number
+	}
+
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > models.MaxPageLimit {
+			return page, fmt.Errorf("limit must be between 1 and %d", models.MaxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	return page, nil
+}
+
+// ParseDateRange reads the from and to query parameters. They accept a date
+// (2006-01-02) or a RFC 3339 time, a date in to includes that whole day.
+func ParseDateRange(c echo.Context) (*time.Time, *time.Time, error) {
+	from, err := parseDate(c.QueryParam("from"), false)
+	if err != nil {
+		return nil, nil, fmt.Errorf("from must be a date like 2006-01-02 or a RFC 3339 time")
+	}
+	to, err := parseDate(c.QueryParam("to"), true)
+	if err != nil {
+		return nil, nil, fmt.Errorf("to must be a date like 2006-01-02 or a RFC 3339 time")
+	}
+	return from, to, nil
+}
+
+func parseDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// PageLinks fills in the next and prev links of meta, keeping the other
+// query parameters of the current request.
+func PageLinks(c echo.Context, meta *models.PageMeta) {
+	link := func(set map[string]string) string {
+		u := *c.Request().URL
+		query := u.Query()
+		query.Del("page")
+		query.Del("after")
+		query.Del("before")
+		for key, value := range set {
+			query.Set(key, value)
+		}
+		u.RawQuery = query.Encode()
+		return u.RequestURI()
+	}
+
+	if meta.Page > 0 {
+		if meta.HasNext {
+			meta.Next = link(map[string]string{"page": strconv.Itoa(meta.Page + 1)})
+		}
+		if meta.HasPrev {
+			meta.Prev = link(map[string]string{"page": strconv.Itoa(meta.Page - 1)})
+		}
+		return
+	}
+
+	if meta.HasNext {
+		meta.Next = link(map[string]string{"after": meta.NextCursor})
+	}
+	if meta.HasPrev {
+		meta.Prev = link(map[string]string{"before": meta.PrevCursor})
+	}
+}
+
+func sortFields(sortable map[string]string) string {
+	fields := make([]string, 0, len(sortable))
+	for field := range sortable {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return strings.Join(fields, ", ")
+}
@@ -1,6 +1,9 @@
 package helper
 
-import "echo-blog/models"
+import (
+	"echo-blog/apperror"
+	"echo-blog/models"
+)
 
 func WrapResponse(code int, status string, response interface{}) *models.WebResponse {
 	newResponse := new(models.WebResponse)
@@ -9,3 +12,11 @@ func WrapResponse(code int, status string, response interface{}) *models.WebResp
 	newResponse.Data = response
 	return newResponse
 }
+
+// WrapError answers a failed request, the status is the message of err.
+func WrapError(err *apperror.Error) *models.WebResponse {
+	newResponse := WrapResponse(err.Status, err.Message, nil)
+	newResponse.ErrorCode = err.Code
+	newResponse.Errors = err.Fields
+	return newResponse
+}
@@ -0,0 +1,46 @@
+package helper
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	DefaultSiteTitle   = "echo-blog"
+	DefaultBlogURLPath = "/api/v1/blogs/slug/"
+)
+
+var (
+	siteURL     string
+	siteTitle   = DefaultSiteTitle
+	blogURLPath = DefaultBlogURLPath
+)
+
+// SetSite sets the public base URL, the title and the path of the blogs the
+// feeds and sitemaps link to, see config.SiteConfig.
+func SetSite(url string, title string, blogPath string) {
+	siteURL = strings.TrimRight(url, "/")
+	siteTitle = title
+	blogURLPath = blogPath
+}
+
+// SiteURL is the public base URL of the blog, the one set with SetSite or
+// the scheme and host of the request.
+func SiteURL(c echo.Context) string {
+	if siteURL != "" {
+		return siteURL
+	}
+	return c.Scheme() + "://" + c.Request().Host
+}
+
+func SiteTitle() string {
+	return siteTitle
+}
+
+// BlogURL is the public address of a blog. By default the slug route of the
+// API is used.
+func BlogURL(site string, slug string) string {
+	return site + blogURLPath + url.PathEscape(slug)
+}
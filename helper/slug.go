@@ -0,0 +1,22 @@
+package helper
+
+import (
+	"strings"
+
+	"github.com/gosimple/slug"
+)
+
+// maxSlugLength leaves room for a collision suffix like -12 within the 191
+// characters MySQL can index.
+const maxSlugLength = 180
+
+// MakeSlug turns any text into a lowercase, dash separated, ASCII slug.
+// Non latin characters are transliterated, e.g. "Čaj & Kafić" becomes
+// "caj-and-kafic".
+func MakeSlug(text string) string {
+	s := slug.Make(text)
+	if len(s) > maxSlugLength {
+		s = strings.TrimRight(s[:maxSlugLength], "-")
+	}
+	return s
+}
@@ -2,32 +2,442 @@ package database
 
 import (
 	"echo-blog/config"
+	"echo-blog/helper"
+	"echo-blog/lib/render"
 	"echo-blog/models"
 	"errors"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func GetAllBlogs() (interface{}, error) {
+var ErrBlogAlreadyPublished = errors.New("blog is already published")
+
+// GormBlogRepository keeps blogs in the database DB, it is the
+// service.BlogRepository of the app. Ids are the ones of the routes, blogs
+// that do not exist or are hidden from the viewer give gorm.ErrRecordNotFound.
+type GormBlogRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormBlogRepository(db *gorm.DB) *GormBlogRepository {
+	return &GormBlogRepository{DB: db}
+}
+
+// visibleBlogs limits a query to the blogs the viewer may read: everybody
+// sees published blogs, authors also see their own drafts and archived
+// blogs, editors and admins see everything.
+func visibleBlogs(userId uint, role string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if role == models.RoleAdmin || role == models.RoleEditor {
+			return db
+		}
+		if userId != 0 {
+			return db.Where("blogs.status = ? OR blogs.user_id = ?", models.BlogStatusPublished, userId)
+		}
+		return db.Where("blogs.status = ?", models.BlogStatusPublished)
+	}
+}
+
+// withBlogRelations loads what is embedded in blog responses.
+func withBlogRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Author").Preload("Category").Preload("FeaturedMedia").Preload("FeaturedMedia.Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("media_variants.width")
+	}).Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name")
+	})
+}
+
+// filterBlogs applies the list filters. Author is a user id or a username,
+// Tag and Category are slugs, a category includes its subcategories. From
+// and To bound created_at.
+func filterBlogs(filter models.BlogFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		// subqueries start from the same database, without the conditions of db
+		fresh := db.Session(&gorm.Session{NewDB: true})
+		if filter.Author != "" {
+			if authorId, err := strconv.ParseUint(filter.Author, 10, 64); err == nil {
+				db = db.Where("blogs.user_id = ?", authorId)
+			} else {
+				db = db.Where("blogs.user_id IN (?)", fresh.Model(&models.User{}).Select("id").Where("username = ?", filter.Author))
+			}
+		}
+		if filter.Status != "" {
+			db = db.Where("blogs.status = ?", filter.Status)
+		}
+		if filter.Tag != "" {
+			db = db.Where("blogs.id IN (?)", fresh.Table("blog_tags").
+				Select("blog_tags.blog_id").
+				Joins("JOIN tags ON tags.id = blog_tags.tag_id").
+				Where("tags.slug = ?", helper.MakeSlug(filter.Tag)))
+		}
+		if filter.Category != "" {
+			var category models.Category
+			if e := fresh.Select("id").Where("slug = ?", helper.MakeSlug(filter.Category)).First(&category).Error; e != nil {
+				return db.Where("1 = 0")
+			}
+			ids, e := categoryDescendants(fresh, category.ID)
+			if e != nil {
+				db.AddError(e)
+				return db
+			}
+			db = db.Where("blogs.category_id IN ?", ids)
+		}
+		if filter.From != nil {
+			db = db.Where("blogs.created_at >= ?", *filter.From)
+		}
+		if filter.To != nil {
+			db = db.Where("blogs.created_at < ?", *filter.To)
+		}
+		return db
+	}
+}
+
+func (r *GormBlogRepository) List(filter models.BlogFilter, page models.PageRequest, userId uint, role string) ([]models.Blog, models.PageMeta, error) {
+	query := r.DB.Model(&models.Blog{}).Scopes(visibleBlogs(userId, role), filterBlogs(filter), withBlogRelations)
+	return findPage(query, "blogs", page, func(blog models.Blog) models.Cursor {
+		return models.Cursor{CreatedAt: blog.CreatedAt, ID: blog.ID}
+	})
+}
+
+// GetFeedBlogs returns the limit most recently published blogs matching
+// filter, newest first.
+func GetFeedBlogs(filter models.BlogFilter, limit int) ([]models.Blog, error) {
+	var blogs []models.Blog
+
+	filter.Status = models.BlogStatusPublished
+	if e := config.DB.Scopes(filterBlogs(filter), withBlogRelations).
+		Order("blogs.published_at DESC").Order("blogs.id DESC").
+		Limit(limit).Find(&blogs).Error; e != nil {
+		return nil, e
+	}
+	return blogs, nil
+}
+
+func CountPublishedBlogs() (int64, error) {
+	var count int64
+
+	if e := config.DB.Model(&models.Blog{}).Where("status = ?", models.BlogStatusPublished).Count(&count).Error; e != nil {
+		return 0, e
+	}
+	return count, nil
+}
+
+// GetSitemapBlogs returns the slug and last update of published blogs in id
+// order, limit blogs starting at offset.
+func GetSitemapBlogs(offset int, limit int) ([]models.Blog, error) {
 	var blogs []models.Blog
-	if e := config.DB.Find(&blogs).Error; e != nil {
+
+	if e := config.DB.Select("id", "slug", "updated_at").Where("status = ?", models.BlogStatusPublished).
+		Order("id").Offset(offset).Limit(limit).Find(&blogs).Error; e != nil {
 		return nil, e
 	}
 	return blogs, nil
 }
 
-func GetBlogByID(id string) (interface{}, error) {
+func (r *GormBlogRepository) FindByID(id uint, userId uint, role string) (models.Blog, error) {
 	var blog models.Blog
 
-	if e := config.DB.First(&blog, id).Error; e != nil {
+	if e := r.DB.Scopes(visibleBlogs(userId, role), withBlogRelations).First(&blog, id).Error; e != nil {
+		return blog, e
+	}
+	return blog, nil
+}
+
+// GetPublishedBlogsByIDs loads published blogs by id, keyed by id.
+func GetPublishedBlogsByIDs(ids []uint) (map[uint]models.Blog, error) {
+	var blogs []models.Blog
+
+	if len(ids) == 0 {
+		return map[uint]models.Blog{}, nil
+	}
+	if e := config.DB.Scopes(withBlogRelations).Where("status = ? AND id IN ?", models.BlogStatusPublished, ids).Find(&blogs).Error; e != nil {
 		return nil, e
 	}
+
+	byID := make(map[uint]models.Blog, len(blogs))
+	for _, blog := range blogs {
+		byID[blog.ID] = blog
+	}
+	return byID, nil
+}
+
+func (r *GormBlogRepository) AuthorID(id uint) (uint, error) {
+	var blog models.Blog
+
+	if e := r.DB.Select("id", "user_id").First(&blog, id).Error; e != nil {
+		return 0, e
+	}
+	return blog.UserID, nil
+}
+
+// Create saves a new blog together with its first revision. The slug is
+// made from the title when the blog has none, and made unique either way.
+// Tags are looked up by name and created when missing, see resolveTags.
+func (r *GormBlogRepository) Create(blog *models.Blog) error {
+	wanted := *blog
+	return retrySlugTaken(func() error {
+		*blog = wanted
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			return createBlog(tx, blog)
+		})
+	})
+}
+
+func createBlog(tx *gorm.DB, blog *models.Blog) error {
+	slug, e := blogSlugFor(tx, blog, blog.Slug)
+	if e != nil {
+		return e
+	}
+	blog.Slug = slug
+
+	if blog.CategoryID != nil && *blog.CategoryID == 0 {
+		blog.CategoryID = nil
+	}
+	if blog.CategoryID != nil {
+		if e := categoryExists(tx, *blog.CategoryID); e != nil {
+			return e
+		}
+	}
+	if blog.FeaturedMediaID != nil && *blog.FeaturedMediaID == 0 {
+		blog.FeaturedMediaID = nil
+	}
+	if blog.FeaturedMediaID != nil {
+		if e := mediaExists(tx, *blog.FeaturedMediaID); e != nil {
+			return e
+		}
+	}
+	if blog.Tags, e = resolveTags(tx, blog.Tags); e != nil {
+		return e
+	}
+	if blog.BodyHTML, blog.TOC, e = render.Render(blog.ContentFormat, blog.Body); e != nil {
+		return e
+	}
+
+	if e := tx.Where("slug = ?", slug).Delete(&models.BlogSlugRedirect{}).Error; e != nil {
+		return e
+	}
+	if e := tx.Create(blog).Error; e != nil {
+		return e
+	}
+	_, e = addBlogRevision(tx, blog, blog.UserID, nil)
+	return e
+}
+
+// Update applies the non-zero fields of changes to the blog and records
+// the resulting content as a new revision made by userId. A new title gives
+// the blog a new slug unless one is requested, the old slug keeps
+// redirecting to the blog. Tags are replaced when changes has a tags list,
+// a CategoryID of 0 removes the blog from its category and a
+// FeaturedMediaID of 0 its featured image.
+func (r *GormBlogRepository) Update(id uint, changes models.Blog, userId uint) (models.Blog, error) {
+	var blog models.Blog
+
+	err := retrySlugTaken(func() error {
+		// every attempt starts over from the requested changes
+		blog = models.Blog{}
+		changes := changes
+		return r.DB.Transaction(func(tx *gorm.DB) error {
+			if e := lockBlog(tx, &blog, id); e != nil {
+				return e
+			}
+			titleChanged := changes.Title != "" && changes.Title != blog.Title
+			requestedSlug := changes.Slug
+			changes.Slug = ""
+			tags, categoryId, featuredMediaId := changes.Tags, changes.CategoryID, changes.FeaturedMediaID
+			changes.Tags, changes.CategoryID, changes.Category = nil, nil, nil
+			changes.FeaturedMediaID, changes.FeaturedMedia = nil, nil
+			changes.BodyHTML, changes.TOC = "", nil
+
+			if e := tx.Model(&blog).Updates(changes).Error; e != nil {
+				return e
+			}
+			if e := setBlogCategory(tx, &blog, categoryId); e != nil {
+				return e
+			}
+			if e := setBlogFeaturedMedia(tx, &blog, featuredMediaId); e != nil {
+				return e
+			}
+			if tags != nil {
+				resolved, e := resolveTags(tx, tags)
+				if e != nil {
+					return e
+				}
+				if e := tx.Model(&blog).Association("Tags").Replace(resolved); e != nil {
+					return e
+				}
+			}
+			if e := tx.Scopes(withBlogRelations).First(&blog, blog.ID).Error; e != nil {
+				return e
+			}
+			if e := saveRenderedBody(tx, &blog); e != nil {
+				return e
+			}
+
+			if requestedSlug != "" || titleChanged {
+				slug, e := blogSlugFor(tx, &blog, requestedSlug)
+				if e != nil {
+					return e
+				}
+				if e := changeBlogSlug(tx, &blog, slug); e != nil {
+					return e
+				}
+			}
+
+			_, e := addBlogRevision(tx, &blog, userId, nil)
+			return e
+		})
+	})
+	return blog, err
+}
+
+// saveRenderedBody renders the body of blog again and stores the result,
+// after its body or content format changed.
+func saveRenderedBody(tx *gorm.DB, blog *models.Blog) error {
+	bodyHTML, toc, e := render.Render(blog.ContentFormat, blog.Body)
+	if e != nil {
+		return e
+	}
+	blog.BodyHTML, blog.TOC = bodyHTML, toc
+	return tx.Model(blog).Select("body_html", "toc").UpdateColumns(models.Blog{BodyHTML: bodyHTML, TOC: toc}).Error
+}
+
+func setBlogCategory(tx *gorm.DB, blog *models.Blog, categoryId *uint) error {
+	if categoryId == nil {
+		return nil
+	}
+	if *categoryId == 0 {
+		return tx.Model(blog).Update("category_id", nil).Error
+	}
+	if e := categoryExists(tx, *categoryId); e != nil {
+		return e
+	}
+	return tx.Model(blog).Update("category_id", *categoryId).Error
+}
+
+func setBlogFeaturedMedia(tx *gorm.DB, blog *models.Blog, mediaId *uint) error {
+	if mediaId == nil {
+		return nil
+	}
+	if *mediaId == 0 {
+		return tx.Model(blog).Update("featured_media_id", nil).Error
+	}
+	if e := mediaExists(tx, *mediaId); e != nil {
+		return e
+	}
+	return tx.Model(blog).Update("featured_media_id", *mediaId).Error
+}
+
+// UpdateStatus moves a blog to another lifecycle status. PublishedAt is
+// set the first time a blog is published and cleared when it goes back to
+// draft.
+func (r *GormBlogRepository) UpdateStatus(id uint, status string) (models.Blog, error) {
+	var blog models.Blog
+
+	if e := r.DB.First(&blog, id).Error; e != nil {
+		return blog, e
+	}
+
+	updates := map[string]interface{}{"status": status, "scheduled_at": nil}
+	switch status {
+	case models.BlogStatusPublished:
+		if blog.PublishedAt == nil {
+			updates["published_at"] = time.Now()
+		}
+	case models.BlogStatusDraft:
+		updates["published_at"] = nil
+	}
+
+	if e := r.DB.Model(&blog).Updates(updates).Error; e != nil {
+		return blog, e
+	}
 	return blog, nil
 }
 
-func DeleteBlogByID(id string) (interface{}, error) {
+// Schedule sets a draft blog to be published automatically at publishAt,
+// see PublishDueBlogs.
+func (r *GormBlogRepository) Schedule(id uint, publishAt time.Time) (models.Blog, error) {
 	var blog models.Blog
 
-	if rowsAff := config.DB.Delete(&blog, id).RowsAffected; rowsAff == 0 {
-		return nil, errors.New("delete failed, blog id not found")
+	if e := r.DB.First(&blog, id).Error; e != nil {
+		return blog, e
+	}
+	if blog.Status == models.BlogStatusPublished {
+		return blog, ErrBlogAlreadyPublished
+	}
+
+	updates := map[string]interface{}{
+		"status":       models.BlogStatusScheduled,
+		"scheduled_at": publishAt,
+		"published_at": nil,
+	}
+	if e := r.DB.Model(&blog).Updates(updates).Error; e != nil {
+		return blog, e
 	}
 	return blog, nil
 }
+
+// ListScheduled lists the upcoming scheduled blogs, soonest first. Authors
+// only see their own, editors and admins see all of them.
+func (r *GormBlogRepository) ListScheduled(userId uint, role string) ([]models.Blog, error) {
+	var blogs []models.Blog
+
+	query := r.DB.Scopes(withBlogRelations).Where("status = ?", models.BlogStatusScheduled)
+	if role != models.RoleAdmin && role != models.RoleEditor {
+		query = query.Where("user_id = ?", userId)
+	}
+	if e := query.Order("scheduled_at").Find(&blogs).Error; e != nil {
+		return nil, e
+	}
+	return blogs, nil
+}
+
+// PublishDueBlogs publishes every scheduled blog whose time has come and
+// returns them. The due rows are claimed with SELECT ... FOR UPDATE SKIP
+// LOCKED, so several instances running the scheduler against the same
+// database never publish the same blog twice.
+func PublishDueBlogs(now time.Time) ([]models.Blog, error) {
+	var blogs []models.Blog
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND scheduled_at <= ?", models.BlogStatusScheduled, now).
+			Find(&blogs).Error; e != nil {
+			return e
+		}
+
+		for i := range blogs {
+			updates := map[string]interface{}{
+				"status":       models.BlogStatusPublished,
+				"published_at": *blogs[i].ScheduledAt,
+				"scheduled_at": nil,
+			}
+			if e := tx.Model(&blogs[i]).Updates(updates).Error; e != nil {
+				return e
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blogs, nil
+}
+
+// Delete moves a blog to the trash. Its slug is moved aside, see
+// trashedSlug, so a new blog can take it.
+func (r *GormBlogRepository) Delete(id uint) error {
+	var blog models.Blog
+
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.Select("id", "slug").First(&blog, id).Error; e != nil {
+			return e
+		}
+		if e := tx.Model(&blog).UpdateColumn("slug", trashedSlug(blog.Slug, blog.ID)).Error; e != nil {
+			return e
+		}
+		return tx.Delete(&blog).Error
+	})
+}
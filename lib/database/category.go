@@ -0,0 +1,221 @@
+package database
+
+import (
+	"echo-blog/config"
+	"echo-blog/helper"
+	"echo-blog/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryExists   = errors.New("category already exists")
+	ErrCategoryNotFound = errors.New("category not found")
+	ErrCategoryCycle    = errors.New("a category cannot be moved below itself")
+)
+
+// GetCategoryTree returns the root categories with their subcategories
+// nested in Children, sorted by name on every level.
+func GetCategoryTree() (interface{}, error) {
+	var categories []models.Category
+
+	if e := config.DB.Order("name").Find(&categories).Error; e != nil {
+		return nil, e
+	}
+	return categoryChildren(categories, 0), nil
+}
+
+// GetCategoryByID returns a category with its whole subtree.
+func GetCategoryByID(id uint) (interface{}, error) {
+	var category models.Category
+
+	if e := config.DB.First(&category, id).Error; e != nil {
+		return nil, e
+	}
+
+	var categories []models.Category
+	if e := config.DB.Order("name").Find(&categories).Error; e != nil {
+		return nil, e
+	}
+	category.Children = categoryChildren(categories, category.ID)
+	return category, nil
+}
+
+func CreateCategory(category *models.Category) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		slug, e := categorySlugFor(tx, category.Name, category.Slug, 0)
+		if e != nil {
+			return e
+		}
+		category.ID = 0
+		category.Slug = slug
+		category.Children = nil
+
+		if category.ParentID != nil && *category.ParentID == 0 {
+			category.ParentID = nil
+		}
+		if category.ParentID != nil {
+			if e := categoryExists(tx, *category.ParentID); e != nil {
+				return e
+			}
+		}
+		return tx.Create(category).Error
+	})
+}
+
+// UpdateCategory renames or moves a category. A ParentID of 0 moves it to
+// the root, a category cannot be moved below one of its own descendants.
+func UpdateCategory(id uint, changes models.Category) (interface{}, error) {
+	var category models.Category
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.First(&category, id).Error; e != nil {
+			return e
+		}
+
+		updates := map[string]interface{}{}
+		if changes.Name != "" {
+			updates["name"] = changes.Name
+		}
+		if changes.Description != "" {
+			updates["description"] = changes.Description
+		}
+		if changes.Name != "" || changes.Slug != "" {
+			name := changes.Name
+			if name == "" {
+				name = category.Name
+			}
+			slug, e := categorySlugFor(tx, name, changes.Slug, category.ID)
+			if e != nil {
+				return e
+			}
+			updates["slug"] = slug
+		}
+		if changes.ParentID != nil {
+			if *changes.ParentID == 0 {
+				updates["parent_id"] = nil
+			} else {
+				if e := categoryExists(tx, *changes.ParentID); e != nil {
+					return e
+				}
+				subtree, e := categoryDescendants(tx, category.ID)
+				if e != nil {
+					return e
+				}
+				for _, descendant := range subtree {
+					if descendant == *changes.ParentID {
+						return ErrCategoryCycle
+					}
+				}
+				updates["parent_id"] = *changes.ParentID
+			}
+		}
+
+		if len(updates) == 0 {
+			return nil
+		}
+		if e := tx.Model(&category).Updates(updates).Error; e != nil {
+			return e
+		}
+		return tx.First(&category, category.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// DeleteCategoryByID deletes a category. Its subcategories and blogs move up
+// to its parent, or to no category when it was a root.
+func DeleteCategoryByID(id uint) (interface{}, error) {
+	var category models.Category
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.First(&category, id).Error; e != nil {
+			return e
+		}
+		if e := tx.Model(&models.Category{}).Where("parent_id = ?", category.ID).Update("parent_id", category.ParentID).Error; e != nil {
+			return e
+		}
+		if e := tx.Unscoped().Model(&models.Blog{}).Where("category_id = ?", category.ID).UpdateColumn("category_id", category.ParentID).Error; e != nil {
+			return e
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return category, nil
+}
+
+// categoryDescendants returns the id of a category followed by the ids of
+// all categories below it.
+func categoryDescendants(tx *gorm.DB, id uint) ([]uint, error) {
+	var categories []models.Category
+
+	if e := tx.Select("id", "parent_id").Find(&categories).Error; e != nil {
+		return nil, e
+	}
+
+	children := make(map[uint][]uint, len(categories))
+	for _, category := range categories {
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+// categoryChildren nests the categories below parentId, 0 being the root.
+func categoryChildren(categories []models.Category, parentId uint) []models.Category {
+	children := []models.Category{}
+	for _, category := range categories {
+		var parent uint
+		if category.ParentID != nil {
+			parent = *category.ParentID
+		}
+		if parent == parentId && category.ID != parentId {
+			category.Children = categoryChildren(categories, category.ID)
+			children = append(children, category)
+		}
+	}
+	return children
+}
+
+func categoryExists(tx *gorm.DB, id uint) error {
+	var found int64
+	if e := tx.Model(&models.Category{}).Where("id = ?", id).Count(&found).Error; e != nil {
+		return e
+	}
+	if found == 0 {
+		return ErrCategoryNotFound
+	}
+	return nil
+}
+
+// categorySlugFor makes the slug of a category from the requested slug or
+// its name and fails when another category already has it.
+func categorySlugFor(tx *gorm.DB, name string, requested string, categoryId uint) (string, error) {
+	slug := helper.MakeSlug(requested)
+	if slug == "" {
+		slug = helper.MakeSlug(name)
+	}
+	if slug == "" {
+		return "", ErrNameWithoutSlug
+	}
+
+	var taken int64
+	if e := tx.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, categoryId).Count(&taken).Error; e != nil {
+		return "", e
+	}
+	if taken > 0 {
+		return "", ErrCategoryExists
+	}
+	return slug, nil
+}
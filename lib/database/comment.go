@@ -0,0 +1,170 @@
+package database
+
+import (
+	"echo-blog/config"
+	"echo-blog/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCommentNotFound       = errors.New("comment not found")
+	ErrCommentParentNotFound = errors.New("parent comment not found")
+)
+
+// GetBlogComments returns the comments of a blog oldest first, either as a
+// tree of replies or flattened in reading order with their depth. Viewers
+// see approved comments and their own, moderators see all. Deleted and
+// hidden comments that still have visible replies stay in the thread
+// without body and author, so the replies keep their place.
+func GetBlogComments(blogId uint, format string, viewerId uint, moderator bool) (interface{}, error) {
+	var comments []models.Comment
+
+	if e := config.DB.Unscoped().Preload("Author").Where("blog_id = ?", blogId).Order("created_at").Order("id").Find(&comments).Error; e != nil {
+		return nil, e
+	}
+
+	byParent := make(map[uint][]models.Comment, len(comments))
+	for _, comment := range comments {
+		if !moderator {
+			comment.SpamReason = ""
+		}
+		var parent uint
+		if comment.ParentID != nil {
+			parent = *comment.ParentID
+		}
+		byParent[parent] = append(byParent[parent], comment)
+	}
+
+	visible := func(comment models.Comment) bool {
+		if comment.DeletedAt.Valid {
+			return false
+		}
+		return moderator || comment.UserID == viewerId || comment.Status == models.CommentStatusApproved
+	}
+
+	tree := commentReplies(byParent, visible, 0, 0)
+	if format == models.CommentFormatFlat {
+		return flattenComments(tree, []models.Comment{}), nil
+	}
+	return tree, nil
+}
+
+func GetCommentByID(blogId uint, id uint) (models.Comment, error) {
+	var comment models.Comment
+
+	if e := config.DB.Preload("Author").Where("blog_id = ?", blogId).First(&comment, id).Error; e != nil {
+		return comment, e
+	}
+	return comment, nil
+}
+
+// CreateComment saves a comment, a reply must answer an approved comment of
+// the same blog or one of the user's own.
+func CreateComment(comment *models.Comment) error {
+	if comment.ParentID != nil && *comment.ParentID == 0 {
+		comment.ParentID = nil
+	}
+	if comment.ParentID != nil {
+		var found int64
+		if e := config.DB.Model(&models.Comment{}).Where("id = ? AND blog_id = ?", *comment.ParentID, comment.BlogID).
+			Where("status = ? OR user_id = ?", models.CommentStatusApproved, comment.UserID).Count(&found).Error; e != nil {
+			return e
+		}
+		if found == 0 {
+			return ErrCommentParentNotFound
+		}
+	}
+	if e := config.DB.Create(comment).Error; e != nil {
+		return e
+	}
+	return config.DB.Preload("Author").First(comment, comment.ID).Error
+}
+
+// UpdateComment changes the body of a comment together with the status the
+// new body deserves. A moderator's decision was about the old body, so it
+// is dropped.
+func UpdateComment(comment *models.Comment, body string, status string, spamReason string) error {
+	return config.DB.Model(comment).Updates(map[string]interface{}{
+		"body":         body,
+		"status":       status,
+		"spam_reason":  spamReason,
+		"moderated_by": nil,
+		"moderated_at": nil,
+	}).Error
+}
+
+// GetModerationQueue lists the comments with status, oldest first.
+func GetModerationQueue(status string, page models.PageRequest) ([]models.Comment, models.PageMeta, error) {
+	query := config.DB.Model(&models.Comment{}).Preload("Author").Where("comments.status = ?", status)
+	return findPage(query, "comments", page, func(comment models.Comment) models.Cursor {
+		return models.Cursor{CreatedAt: comment.CreatedAt, ID: comment.ID}
+	})
+}
+
+// ModerateComments gives the comments with ids the status decided by the
+// moderator and returns them as they were before and after.
+func ModerateComments(ids []uint, status string, moderatorId uint) ([]models.Comment, []models.Comment, error) {
+	var before, after []models.Comment
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.Where("id IN ?", ids).Order("id").Find(&before).Error; e != nil {
+			return e
+		}
+		if len(before) == 0 {
+			return ErrCommentNotFound
+		}
+
+		updates := map[string]interface{}{
+			"status":       status,
+			"moderated_by": moderatorId,
+			"moderated_at": time.Now(),
+		}
+		if e := tx.Model(&models.Comment{}).Where("id IN ?", ids).Updates(updates).Error; e != nil {
+			return e
+		}
+		return tx.Preload("Author").Where("id IN ?", ids).Order("id").Find(&after).Error
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return before, after, nil
+}
+
+func DeleteComment(comment *models.Comment) error {
+	return config.DB.Delete(comment).Error
+}
+
+// commentReplies nests the comments answering parentId, 0 being the blog
+// itself. Comments that are not visible are only kept, emptied, when a
+// reply below them is.
+func commentReplies(byParent map[uint][]models.Comment, visible func(models.Comment) bool, parentId uint, depth int) []models.Comment {
+	replies := []models.Comment{}
+	for _, comment := range byParent[parentId] {
+		comment.Depth = depth
+		comment.Replies = commentReplies(byParent, visible, comment.ID, depth+1)
+		if !visible(comment) {
+			if len(comment.Replies) == 0 {
+				continue
+			}
+			comment.Body = ""
+			comment.UserID = 0
+			comment.Author = nil
+			comment.SpamReason = ""
+		}
+		replies = append(replies, comment)
+	}
+	return replies
+}
+
+func flattenComments(tree []models.Comment, flat []models.Comment) []models.Comment {
+	for _, comment := range tree {
+		replies := comment.Replies
+		comment.Replies = nil
+		flat = append(flat, comment)
+		flat = flattenComments(replies, flat)
+	}
+	return flat
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"echo-blog/config"
+	"echo-blog/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrMediaNotFound = errors.New("media not found")
+
+// withVariants loads the variants of images, smallest first.
+func withVariants(db *gorm.DB) *gorm.DB {
+	return db.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("media_variants.width")
+	})
+}
+
+// GetAllMedia lists the files uploaded by userId, or every upload when
+// userId is 0.
+func GetAllMedia(userId uint, page models.PageRequest) ([]models.Media, models.PageMeta, error) {
+	query := config.DB.Model(&models.Media{}).Scopes(withVariants)
+	if userId != 0 {
+		query = query.Where("media.user_id = ?", userId)
+	}
+	return findPage(query, "media", page, func(media models.Media) models.Cursor {
+		return models.Cursor{CreatedAt: media.CreatedAt, ID: media.ID}
+	})
+}
+
+func GetMediaByID(id uint) (models.Media, error) {
+	var media models.Media
+
+	if e := config.DB.Scopes(withVariants).First(&media, id).Error; e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return media, ErrMediaNotFound
+		}
+		return media, e
+	}
+	return media, nil
+}
+
+// GetMediaFile describes the stored file with key, an upload or one of its
+// variants. For a variant the key, type and size are those of the variant.
+func GetMediaFile(key string) (models.Media, error) {
+	var media models.Media
+
+	e := config.DB.Where(&models.Media{Key: key}).First(&media).Error
+	if !errors.Is(e, gorm.ErrRecordNotFound) {
+		return media, e
+	}
+
+	var variant models.MediaVariant
+	if e := config.DB.Where(&models.MediaVariant{Key: key}).First(&variant).Error; e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return media, ErrMediaNotFound
+		}
+		return media, e
+	}
+	if e := config.DB.First(&media, variant.MediaID).Error; e != nil {
+		return media, e
+	}
+	media.Key, media.ContentType, media.Size = variant.Key, variant.ContentType, variant.Size
+	return media, nil
+}
+
+func CreateMedia(media *models.Media) error {
+	return config.DB.Create(media).Error
+}
+
+// DeleteMedia removes the record of an upload and its variants, blogs
+// featuring it are left without a featured image. The files themselves are
+// removed by the caller.
+func DeleteMedia(media models.Media) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.Unscoped().Model(&models.Blog{}).Where("featured_media_id = ?", media.ID).UpdateColumn("featured_media_id", nil).Error; e != nil {
+			return e
+		}
+		if e := tx.Where("media_id = ?", media.ID).Delete(&models.MediaVariant{}).Error; e != nil {
+			return e
+		}
+		return tx.Delete(&media).Error
+	})
+}
+
+// MediaOwnerID returns the user who uploaded the media with id.
+func (r *GormBlogRepository) MediaOwnerID(id uint) (uint, error) {
+	var media models.Media
+
+	if e := r.DB.Select("id", "user_id").First(&media, id).Error; e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return 0, ErrMediaNotFound
+		}
+		return 0, e
+	}
+	return media.UserID, nil
+}
+
+func mediaExists(tx *gorm.DB, id uint) error {
+	var found int64
+	if e := tx.Model(&models.Media{}).Where("id = ?", id).Count(&found).Error; e != nil {
+		return e
+	}
+	if found == 0 {
+		return ErrMediaNotFound
+	}
+	return nil
+}
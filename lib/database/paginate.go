@@ -0,0 +1,84 @@
+package database
+
+import (
+	"echo-blog/models"
+
+	"gorm.io/gorm"
+)
+
+// findPage loads one page of an already filtered query and describes it.
+// It fetches one row more than the limit to know whether another page
+// follows. With a before cursor the rows are read backwards and put back in
+// order afterwards.
+func findPage[T any](query *gorm.DB, table string, page models.PageRequest, cursorOf func(T) models.Cursor) ([]T, models.PageMeta, error) {
+	meta := models.PageMeta{Page: page.Page, Limit: page.Limit}
+
+	if e := query.Session(&gorm.Session{}).Count(&meta.Total).Error; e != nil {
+		return nil, meta, e
+	}
+
+	desc := page.Desc
+	if page.Before != nil {
+		desc = !desc
+	}
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+
+	find := query.Session(&gorm.Session{}).
+		Order(table + "." + page.Sort + direction).
+		Order(table + ".id" + direction)
+
+	if page.Keyset() {
+		cursor := page.After
+		if cursor == nil {
+			cursor = page.Before
+		}
+		operator := " > "
+		if desc {
+			operator = " < "
+		}
+		find = find.Where(
+			"("+table+".created_at"+operator+"?) OR ("+table+".created_at = ? AND "+table+".id"+operator+"?)",
+			cursor.CreatedAt, cursor.CreatedAt, cursor.ID,
+		)
+	} else {
+		find = find.Offset(page.Offset())
+	}
+
+	var rows []T
+	if e := find.Limit(page.Limit + 1).Find(&rows).Error; e != nil {
+		return nil, meta, e
+	}
+
+	more := len(rows) > page.Limit
+	if more {
+		rows = rows[:page.Limit]
+	}
+	if page.Before != nil {
+		for l, r := 0, len(rows)-1; l < r; l, r = l+1, r-1 {
+			rows[l], rows[r] = rows[r], rows[l]
+		}
+	}
+
+	switch {
+	case page.After != nil:
+		meta.HasNext, meta.HasPrev = more, len(rows) > 0
+	case page.Before != nil:
+		meta.HasNext, meta.HasPrev = len(rows) > 0, more
+	default:
+		meta.HasNext, meta.HasPrev = more, page.Page > 1
+	}
+
+	if len(rows) > 0 && page.Sort == "created_at" {
+		if meta.HasNext {
+			meta.NextCursor = cursorOf(rows[len(rows)-1]).Encode()
+		}
+		if meta.HasPrev && page.Keyset() {
+			meta.PrevCursor = cursorOf(rows[0]).Encode()
+		}
+	}
+
+	return rows, meta, nil
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"echo-blog/config"
+	"echo-blog/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RestoreBlogRevision puts the content of an old revision back on the blog.
+// History is never rewritten, the restore is recorded as a new revision.
+func RestoreBlogRevision(id uint, number int, userId uint) (models.Blog, error) {
+	var blog models.Blog
+
+	err := retrySlugTaken(func() error {
+		blog = models.Blog{}
+		return config.DB.Transaction(func(tx *gorm.DB) error {
+			if e := lockBlog(tx, &blog, id); e != nil {
+				return e
+			}
+
+			var revision models.BlogRevision
+			if e := tx.Where("blog_id = ? AND number = ?", blog.ID, number).First(&revision).Error; e != nil {
+				return e
+			}
+
+			content := map[string]interface{}{
+				"title": revision.Title,
+				"body":  revision.Body,
+			}
+			if e := tx.Model(&blog).Updates(content).Error; e != nil {
+				return e
+			}
+			if e := tx.First(&blog, blog.ID).Error; e != nil {
+				return e
+			}
+			if e := saveRenderedBody(tx, &blog); e != nil {
+				return e
+			}
+
+			slug, e := blogSlugFor(tx, &blog, revision.Slug)
+			if e != nil {
+				return e
+			}
+			if e := changeBlogSlug(tx, &blog, slug); e != nil {
+				return e
+			}
+			_, e = addBlogRevision(tx, &blog, userId, &number)
+			return e
+		})
+	})
+	return blog, err
+}
+
+func GetBlogRevisions(id uint) (interface{}, error) {
+	var revisions []models.BlogRevision
+
+	if e := config.DB.Omit("body").Preload("Author").Where("blog_id = ?", id).Order("number").Find(&revisions).Error; e != nil {
+		return nil, e
+	}
+	return revisions, nil
+}
+
+func GetBlogRevision(id uint, number int) (models.BlogRevision, error) {
+	var revision models.BlogRevision
+
+	if e := config.DB.Preload("Author").Where("blog_id = ? AND number = ?", id, number).First(&revision).Error; e != nil {
+		return revision, e
+	}
+	return revision, nil
+}
+
+// lockBlog loads the blog for update, so concurrent edits of the same blog
+// get consecutive revision numbers. Blogs written before revisions existed
+// first get their current content saved as a baseline revision.
+func lockBlog(tx *gorm.DB, blog *models.Blog, id uint) error {
+	if e := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(blog, id).Error; e != nil {
+		return e
+	}
+
+	var revisions int64
+	if e := tx.Model(&models.BlogRevision{}).Where("blog_id = ?", blog.ID).Count(&revisions).Error; e != nil {
+		return e
+	}
+	if revisions == 0 {
+		if _, e := addBlogRevision(tx, blog, blog.UserID, nil); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func addBlogRevision(tx *gorm.DB, blog *models.Blog, userId uint, restoredFrom *int) (models.BlogRevision, error) {
+	var last int
+	if e := tx.Model(&models.BlogRevision{}).Where("blog_id = ?", blog.ID).Select("COALESCE(MAX(number), 0)").Scan(&last).Error; e != nil {
+		return models.BlogRevision{}, e
+	}
+
+	revision := models.BlogRevision{
+		BlogID:       blog.ID,
+		Number:       last + 1,
+		Title:        blog.Title,
+		Body:         blog.Body,
+		Slug:         blog.Slug,
+		RestoredFrom: restoredFrom,
+		UserID:       userId,
+	}
+	if e := tx.Create(&revision).Error; e != nil {
+		return revision, e
+	}
+	return revision, nil
+}
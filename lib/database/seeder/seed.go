@@ -2,8 +2,10 @@ package seeder
 
 import (
 	"echo-blog/config"
+	"echo-blog/lib/render"
 	"echo-blog/models"
 	"log"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -13,8 +15,8 @@ type seed struct {
 	DB *gorm.DB
 }
 
+// NewSeeder seeds the database opened by config.InitDB.
 func NewSeeder() *seed {
-	config.InitDB()
 	return &seed{DB: config.DB}
 }
 
@@ -36,6 +38,24 @@ func (s *seed) UserSeed() {
 			Email:    "test2@mail.com",
 			Password: "1234", // Original password
 		},
+		{
+			Model: gorm.Model{
+				ID: 3,
+			},
+			Username: "test3",
+			Email:    "test3@mail.com",
+			Password: "1234", // Original password
+			Role:     models.RoleEditor,
+		},
+		{
+			Model: gorm.Model{
+				ID: 4,
+			},
+			Username: "test4",
+			Email:    "test4@mail.com",
+			Password: "1234", // Original password
+			Role:     models.RoleAdmin,
+		},
 	}
 
 	// Hash passwords before inserting
@@ -64,23 +84,70 @@ func hashPassword(password string) (string, error) {
 }
 
 func (s *seed) BlogSeed() {
+	programming, golang := uint(1), uint(2)
+	categories := []models.Category{
+		{ID: programming, Name: "Programming", Slug: "programming"},
+		{ID: golang, Name: "Go", Slug: "go", ParentID: &programming},
+		{ID: 3, Name: "Travel", Slug: "travel"},
+	}
+	if err := s.DB.Create(&categories).Error; err != nil {
+		log.Printf("cannot seed data categories, error : %v\n", err)
+	}
+
+	tags := []models.Tag{
+		{ID: 1, Name: "Go", Slug: "go"},
+		{ID: 2, Name: "Web", Slug: "web"},
+		{ID: 3, Name: "Unused", Slug: "unused"},
+	}
+	if err := s.DB.Create(&tags).Error; err != nil {
+		log.Printf("cannot seed data tags, error : %v\n", err)
+	}
+
+	publishedAt := time.Now()
+	autoApprove := true
 	blogs := []models.Blog{
 		{
 			Model: gorm.Model{
 				ID: 1,
 			},
-			Title: "Test Blog 1",
-			Body:  "Test Body 1",
-			Slug:  "slug1",
+			Title:       "Test Blog 1",
+			Body:        "Test Body 1",
+			Slug:        "slug1",
+			Status:      models.BlogStatusPublished,
+			PublishedAt: &publishedAt,
+			UserID:      1,
+			CategoryID:  &golang,
+			Tags:        []models.Tag{tags[0], tags[1]},
+
+			AutoApproveComments: &autoApprove,
 		},
 		{
 			Model: gorm.Model{
 				ID: 2,
 			},
-			Title: "Test Blog 2",
-			Body:  "Test Body 2",
-			Slug:  "slug2",
+			Title:       "Test Blog 2",
+			Body:        "Test Body 2",
+			Slug:        "slug2",
+			Status:      models.BlogStatusPublished,
+			PublishedAt: &publishedAt,
+			UserID:      2,
+			CategoryID:  &programming,
+			Tags:        []models.Tag{tags[1]},
 		},
+		{
+			Model: gorm.Model{
+				ID: 3,
+			},
+			Title:  "Test Draft 3",
+			Body:   "Test Body 3",
+			Slug:   "slug3",
+			Status: models.BlogStatusDraft,
+			UserID: 1,
+			Tags:   []models.Tag{tags[0]},
+		},
+	}
+	for i := range blogs {
+		blogs[i].BodyHTML, blogs[i].TOC, _ = render.Render(blogs[i].ContentFormat, blogs[i].Body)
 	}
 	if err := s.DB.Create(&blogs).Error; err != nil {
 		log.Printf("cannot seed data blogs, error : %v\n", err)
@@ -93,5 +160,13 @@ func (s *seed) UserDelete() {
 }
 
 func (s *seed) BlogDelete() {
+	s.DB.Exec("DELETE FROM comments")
+	s.DB.Exec("DELETE FROM blog_revisions")
+	s.DB.Exec("DELETE FROM blog_slug_redirects")
+	s.DB.Exec("DELETE FROM blog_tags")
 	s.DB.Exec("DELETE FROM blogs")
+	s.DB.Exec("DELETE FROM media_variants")
+	s.DB.Exec("DELETE FROM media")
+	s.DB.Exec("DELETE FROM tags")
+	s.DB.Exec("DELETE FROM categories")
 }
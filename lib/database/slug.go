@@ -0,0 +1,143 @@
+package database
+
+import (
+	"echo-blog/helper"
+	"echo-blog/models"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// slugMaxLength is the size of the slug columns.
+const slugMaxLength = 191
+
+// slugAttempts is how often saving a blog is tried when a concurrent save
+// takes its slug between picking and saving it.
+const slugAttempts = 5
+
+// ErrNameWithoutSlug is returned for tags and categories whose name makes an
+// empty slug.
+var ErrNameWithoutSlug = errors.New("name must contain letters or digits")
+
+func (r *GormBlogRepository) FindBySlug(slug string, userId uint, role string) (models.Blog, error) {
+	var blog models.Blog
+
+	if e := r.DB.Scopes(visibleBlogs(userId, role), withBlogRelations).Where("slug = ?", slug).First(&blog).Error; e != nil {
+		return blog, e
+	}
+	return blog, nil
+}
+
+// FindSlugRedirect returns the current slug of the blog that used to be
+// reachable under slug, when the viewer may read the blog.
+func (r *GormBlogRepository) FindSlugRedirect(slug string, userId uint, role string) (string, error) {
+	var current string
+
+	e := r.DB.Model(&models.BlogSlugRedirect{}).
+		Joins("JOIN blogs ON blogs.id = blog_slug_redirects.blog_id AND blogs.deleted_at IS NULL").
+		Scopes(visibleBlogs(userId, role)).
+		Where("blog_slug_redirects.slug = ?", slug).
+		Pluck("blogs.slug", &current).Error
+	if e != nil {
+		return "", e
+	}
+	if current == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	return current, nil
+}
+
+// blogSlugFor picks the slug for a blog: the requested one when given,
+// otherwise one made from the title, made unique with a -2, -3... suffix.
+func blogSlugFor(tx *gorm.DB, blog *models.Blog, requested string) (string, error) {
+	base := helper.MakeSlug(requested)
+	if base == "" {
+		base = helper.MakeSlug(blog.Title)
+	}
+	if base == "" {
+		base = "blog"
+	}
+	return uniqueSlug(tx, &models.Blog{}, base, blog.ID)
+}
+
+// uniqueSlug returns base, or base with the first free -2, -3... suffix,
+// among the slugs of model's table other than the row with id.
+func uniqueSlug(tx *gorm.DB, model interface{}, base string, id uint) (string, error) {
+	var taken []string
+
+	// the unique index covers trashed blogs too, their slugs have a ~ suffix
+	if e := tx.Unscoped().Model(model).
+		Where("id <> ? AND (slug = ? OR slug LIKE ?)", id, base, base+"-%").
+		Pluck("slug", &taken).Error; e != nil {
+		return "", e
+	}
+
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+
+	slug := base
+	for n := 2; used[slug]; n++ {
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+	return slug, nil
+}
+
+// retrySlugTaken runs save again while it fails on a unique index. The
+// slugs are checked before saving, so that happens when a concurrent save
+// took the slug meanwhile, the next attempt sees it and picks the next
+// suffix. save runs a whole transaction, a failed statement aborts it.
+func retrySlugTaken(save func() error) error {
+	var e error
+	for attempt := 0; attempt < slugAttempts; attempt++ {
+		if e = save(); !errors.Is(e, gorm.ErrDuplicatedKey) {
+			return e
+		}
+	}
+	return e
+}
+
+// changeBlogSlug moves a saved blog to a new slug and keeps the old one as a
+// redirect. A redirect with the new slug is dropped, current slugs win.
+func changeBlogSlug(tx *gorm.DB, blog *models.Blog, slug string) error {
+	if slug == blog.Slug {
+		return nil
+	}
+
+	if e := tx.Where("slug = ?", slug).Delete(&models.BlogSlugRedirect{}).Error; e != nil {
+		return e
+	}
+	if blog.Slug != "" {
+		redirect := models.BlogSlugRedirect{Slug: blog.Slug, BlogID: blog.ID}
+		if e := tx.Create(&redirect).Error; e != nil {
+			return e
+		}
+	}
+	if e := tx.Model(blog).Update("slug", slug).Error; e != nil {
+		return e
+	}
+	blog.Slug = slug
+	return nil
+}
+
+// trashedSlug is the slug a blog keeps while it is in the trash, like
+// "hello-world~42". Made slugs never contain a ~, so it cannot clash with a
+// live blog.
+func trashedSlug(slug string, id uint) string {
+	suffix := fmt.Sprintf("~%d", id)
+	if len(slug)+len(suffix) > slugMaxLength {
+		slug = slug[:slugMaxLength-len(suffix)]
+	}
+	return slug + suffix
+}
+
+// restoredSlug undoes trashedSlug.
+func restoredSlug(slug string) string {
+	if i := strings.LastIndex(slug, "~"); i >= 0 {
+		return slug[:i]
+	}
+	return slug
+}
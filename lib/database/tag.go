@@ -0,0 +1,198 @@
+package database
+
+import (
+	"echo-blog/config"
+	"echo-blog/helper"
+	"echo-blog/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTagExists   = errors.New("tag already exists")
+	ErrTagNotFound = errors.New("tag not found")
+)
+
+// tagCounts selects tags together with the number of published blogs using
+// them.
+func tagCounts(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Tag{}).
+		Select("tags.*, COUNT(blogs.id) AS count").
+		Joins("LEFT JOIN blog_tags ON blog_tags.tag_id = tags.id").
+		Joins("LEFT JOIN blogs ON blogs.id = blog_tags.blog_id AND blogs.status = ? AND blogs.deleted_at IS NULL", models.BlogStatusPublished).
+		Group("tags.id")
+}
+
+func GetAllTags() (interface{}, error) {
+	var tags []models.Tag
+
+	if e := config.DB.Scopes(tagCounts).Order("tags.name").Find(&tags).Error; e != nil {
+		return nil, e
+	}
+	return tags, nil
+}
+
+// GetTagCloud returns the limit most used tags, most used first. Tags
+// without published blogs are left out.
+func GetTagCloud(limit int) (interface{}, error) {
+	var tags []models.Tag
+
+	if e := config.DB.Scopes(tagCounts).Having("COUNT(blogs.id) > 0").Order("count DESC").Order("tags.name").Limit(limit).Find(&tags).Error; e != nil {
+		return nil, e
+	}
+	return tags, nil
+}
+
+func GetTagByID(id uint) (interface{}, error) {
+	var tag models.Tag
+
+	if e := config.DB.Scopes(tagCounts).Where("tags.id = ?", id).First(&tag).Error; e != nil {
+		return nil, e
+	}
+	return tag, nil
+}
+
+func CreateTag(tag *models.Tag) error {
+	slug, e := tagSlugFor(config.DB, tag.Name, tag.Slug, 0)
+	if e != nil {
+		return e
+	}
+	tag.ID = 0
+	tag.Slug = slug
+	return config.DB.Create(tag).Error
+}
+
+func UpdateTag(id uint, changes models.Tag) (interface{}, error) {
+	var tag models.Tag
+
+	if e := config.DB.First(&tag, id).Error; e != nil {
+		return nil, e
+	}
+
+	if changes.Name != "" || changes.Slug != "" {
+		name := changes.Name
+		if name == "" {
+			name = tag.Name
+		}
+		slug, e := tagSlugFor(config.DB, name, changes.Slug, tag.ID)
+		if e != nil {
+			return nil, e
+		}
+		if e := config.DB.Model(&tag).Updates(models.Tag{Name: changes.Name, Slug: slug}).Error; e != nil {
+			return nil, e
+		}
+	}
+	return GetTagByID(id)
+}
+
+// DeleteTagByID removes a tag from every blog and deletes it.
+func DeleteTagByID(id uint) (interface{}, error) {
+	var tag models.Tag
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := tx.First(&tag, id).Error; e != nil {
+			return e
+		}
+		if e := tx.Exec("DELETE FROM blog_tags WHERE tag_id = ?", tag.ID).Error; e != nil {
+			return e
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// tagSlugFor makes the slug of a tag from the requested slug or its name and
+// fails when another tag already has it.
+func tagSlugFor(tx *gorm.DB, name string, requested string, tagId uint) (string, error) {
+	slug := helper.MakeSlug(requested)
+	if slug == "" {
+		slug = helper.MakeSlug(name)
+	}
+	if slug == "" {
+		return "", ErrNameWithoutSlug
+	}
+
+	var taken int64
+	if e := tx.Model(&models.Tag{}).Where("slug = ? AND id <> ?", slug, tagId).Count(&taken).Error; e != nil {
+		return "", e
+	}
+	if taken > 0 {
+		return "", ErrTagExists
+	}
+	return slug, nil
+}
+
+// resolveTags turns the tags sent with a blog into saved tags. A tag is
+// looked up by id when it has one, otherwise by the slug of its name, and
+// created when no tag has that slug yet.
+func resolveTags(tx *gorm.DB, tags []models.Tag) ([]models.Tag, error) {
+	resolved := make([]models.Tag, 0, len(tags))
+	seen := make(map[uint]bool, len(tags))
+
+	for _, wanted := range tags {
+		var tag models.Tag
+
+		if wanted.ID != 0 {
+			if e := tx.First(&tag, wanted.ID).Error; e != nil {
+				if errors.Is(e, gorm.ErrRecordNotFound) {
+					return nil, ErrTagNotFound
+				}
+				return nil, e
+			}
+		} else {
+			slug, name := wantedTag(wanted)
+			if slug == "" {
+				continue
+			}
+			if e := tx.Where(models.Tag{Slug: slug}).Attrs(models.Tag{Name: name}).FirstOrCreate(&tag).Error; e != nil {
+				return nil, e
+			}
+		}
+
+		if !seen[tag.ID] {
+			seen[tag.ID] = true
+			resolved = append(resolved, tag)
+		}
+	}
+	return resolved, nil
+}
+
+// MissingTags returns the names of the tags sent with a blog that do not
+// exist yet, the ones resolveTags would create. Tags sent by id are left to
+// resolveTags.
+func (r *GormBlogRepository) MissingTags(tags []models.Tag) ([]string, error) {
+	var missing []string
+
+	for _, wanted := range tags {
+		slug, name := wantedTag(wanted)
+		if wanted.ID != 0 || slug == "" {
+			continue
+		}
+		var count int64
+		if e := r.DB.Model(&models.Tag{}).Where("slug = ?", slug).Count(&count).Error; e != nil {
+			return nil, e
+		}
+		if count == 0 {
+			missing = append(missing, name)
+		}
+	}
+	return missing, nil
+}
+
+// wantedTag returns the slug and name of a tag sent by name, the slug is
+// made from the name unless one is given.
+func wantedTag(wanted models.Tag) (slug string, name string) {
+	slug = helper.MakeSlug(wanted.Slug)
+	if slug == "" {
+		slug = helper.MakeSlug(wanted.Name)
+	}
+	name = wanted.Name
+	if name == "" {
+		name = slug
+	}
+	return slug, name
+}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"echo-blog/middlewares"
+	"echo-blog/models"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token invalid")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+)
+
+// GormSessionRepository keeps the refresh tokens of login sessions in the
+// database DB, it is the service.SessionRepository of the app.
+type GormSessionRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormSessionRepository(db *gorm.DB) *GormSessionRepository {
+	return &GormSessionRepository{DB: db}
+}
+
+// Start starts a new login session for the user and returns its first
+// access and refresh tokens.
+func (r *GormSessionRepository) Start(user *models.User) (*models.TokenPair, error) {
+	sessionId, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return issueTokenPair(r.DB, user, sessionId)
+}
+
+// RevokeUser ends every session of the user.
+func (r *GormSessionRepository) RevokeUser(userId uint) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// Refresh exchanges a refresh token for a new token pair of the same
+// session. A refresh token can be used only once: presenting one that was
+// already rotated means it leaked, so the whole session is revoked.
+func (r *GormSessionRepository) Refresh(refreshToken string) (*models.TokenPair, error) {
+	var pair *models.TokenPair
+	var sessionId string
+
+	err := r.DB.Transaction(func(tx *gorm.DB) error {
+		var record models.RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(refreshToken)).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+		sessionId = record.SessionID
+
+		if record.RevokedAt != nil || time.Now().After(record.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+		if record.UsedAt != nil {
+			return ErrRefreshTokenReused
+		}
+
+		// the used_at guard makes concurrent rotations of the same token
+		// race for a single row, only one of them can win
+		res := tx.Model(&models.RefreshToken{}).Where("id = ? AND used_at IS NULL", record.ID).Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var user models.User
+		if err := tx.First(&user, record.UserID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		var err error
+		pair, err = issueTokenPair(tx, &user, record.SessionID)
+		return err
+	})
+
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if e := r.Revoke(sessionId); e != nil {
+			return nil, e
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Revoke ends the session by revoking its refresh tokens.
+func (r *GormSessionRepository) Revoke(sessionId string) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("session_id = ? AND revoked_at IS NULL", sessionId).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsRevoked reports whether the session has no refresh token left that is
+// not revoked, i.e. the user logged out or the session was killed.
+func (r *GormSessionRepository) IsRevoked(sessionId string) (bool, error) {
+	if sessionId == "" {
+		return true, nil
+	}
+	var active int64
+	if err := r.DB.Model(&models.RefreshToken{}).Where("session_id = ? AND revoked_at IS NULL", sessionId).Count(&active).Error; err != nil {
+		return false, err
+	}
+	return active == 0, nil
+}
+
+func issueTokenPair(tx *gorm.DB, user *models.User, sessionId string) (*models.TokenPair, error) {
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	record := models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: sessionId,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(middlewares.RefreshTokenTTL),
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		return nil, err
+	}
+
+	token, err := middlewares.CreateToken(int(user.ID), user.Role, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(middlewares.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken is what gets stored, so a database leak does not leak usable
+// refresh tokens.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,217 @@
+package database
+
+import (
+	"echo-blog/config"
+	"echo-blog/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotInTrash    = errors.New("id not found in trash")
+	ErrUserTaken     = errors.New("email is already used by another user")
+	ErrUserOwnsBlogs = errors.New("user still owns blogs, purge them first")
+)
+
+// trashed limits an unscoped query to soft deleted rows of table.
+func trashed(table string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Where(table + ".deleted_at IS NOT NULL")
+	}
+}
+
+// firstTrashed loads the trashed row of dest's table with id.
+func firstTrashed(tx *gorm.DB, table string, dest interface{}, id uint) error {
+	e := tx.Scopes(trashed(table)).First(dest, id).Error
+	if errors.Is(e, gorm.ErrRecordNotFound) {
+		return ErrNotInTrash
+	}
+	return e
+}
+
+// GetTrashedBlogs lists the blogs in the trash, of userId only unless it is 0.
+func GetTrashedBlogs(userId uint, page models.PageRequest) ([]models.Blog, models.PageMeta, error) {
+	query := config.DB.Model(&models.Blog{}).Scopes(trashed("blogs"), withBlogRelations)
+	if userId != 0 {
+		query = query.Where("blogs.user_id = ?", userId)
+	}
+	return findPage(query, "blogs", page, func(blog models.Blog) models.Cursor {
+		return models.Cursor{CreatedAt: blog.CreatedAt, ID: blog.ID}
+	})
+}
+
+func GetTrashedUsers(page models.PageRequest) ([]models.User, models.PageMeta, error) {
+	query := config.DB.Model(&models.User{}).Scopes(trashed("users"))
+	return findPage(query, "users", page, func(user models.User) models.Cursor {
+		return models.Cursor{CreatedAt: user.CreatedAt, ID: user.ID}
+	})
+}
+
+func GetTrashedBlogAuthorID(id uint) (uint, error) {
+	var blog models.Blog
+
+	if e := firstTrashed(config.DB.Select("id", "user_id"), "blogs", &blog, id); e != nil {
+		return 0, e
+	}
+	return blog.UserID, nil
+}
+
+// RestoreBlog takes a blog out of the trash. It gets its old slug back, or
+// the first free variant of it when another blog took the slug meanwhile.
+func RestoreBlog(id uint) (models.Blog, error) {
+	var blog models.Blog
+
+	err := retrySlugTaken(func() error {
+		blog = models.Blog{}
+		return config.DB.Transaction(func(tx *gorm.DB) error {
+			if e := firstTrashed(tx, "blogs", &blog, id); e != nil {
+				return e
+			}
+			slug, e := blogSlugFor(tx, &blog, restoredSlug(blog.Slug))
+			if e != nil {
+				return e
+			}
+			if e := tx.Unscoped().Model(&blog).UpdateColumns(map[string]interface{}{"slug": slug, "deleted_at": nil}).Error; e != nil {
+				return e
+			}
+			return tx.Scopes(withBlogRelations).First(&blog, blog.ID).Error
+		})
+	})
+	return blog, err
+}
+
+// RestoreUser takes a user out of the trash, unless another user registered
+// with the same email meanwhile. Usernames are not unique.
+func RestoreUser(id uint) (models.User, error) {
+	var user models.User
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if e := firstTrashed(tx, "users", &user, id); e != nil {
+			return e
+		}
+		var taken int64
+		if e := tx.Model(&models.User{}).Where("email = ?", user.Email).Count(&taken).Error; e != nil {
+			return e
+		}
+		if taken > 0 {
+			return ErrUserTaken
+		}
+		if e := tx.Unscoped().Model(&user).UpdateColumn("deleted_at", nil).Error; e != nil {
+			return e
+		}
+		user.DeletedAt = gorm.DeletedAt{}
+		return nil
+	})
+	return user, err
+}
+
+// PurgeBlog permanently deletes a trashed blog with its comments, revisions,
+// old slugs and tag links.
+func PurgeBlog(id uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var blog models.Blog
+		if e := firstTrashed(tx.Select("id"), "blogs", &blog, id); e != nil {
+			return e
+		}
+		return purgeBlog(tx, blog.ID)
+	})
+}
+
+func purgeBlog(tx *gorm.DB, id uint) error {
+	if e := tx.Exec("DELETE FROM blog_tags WHERE blog_id = ?", id).Error; e != nil {
+		return e
+	}
+	if e := tx.Unscoped().Where("blog_id = ?", id).Delete(&models.Comment{}).Error; e != nil {
+		return e
+	}
+	if e := tx.Where("blog_id = ?", id).Delete(&models.BlogRevision{}).Error; e != nil {
+		return e
+	}
+	if e := tx.Where("blog_id = ?", id).Delete(&models.BlogSlugRedirect{}).Error; e != nil {
+		return e
+	}
+	return tx.Unscoped().Delete(&models.Blog{}, id).Error
+}
+
+// PurgeUser permanently deletes a trashed user. Users who still own blogs,
+// trashed ones included, cannot be purged. Their comments are deleted, the
+// replies to them move up to the parent comment, and the revisions they
+// made of other blogs are credited to the blog's author. Uploads are kept,
+// they may still be in use.
+func PurgeUser(id uint) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if e := firstTrashed(tx.Select("id"), "users", &user, id); e != nil {
+			return e
+		}
+		return purgeUser(tx, user.ID)
+	})
+}
+
+func purgeUser(tx *gorm.DB, id uint) error {
+	var blogs int64
+	if e := tx.Unscoped().Model(&models.Blog{}).Where("user_id = ?", id).Count(&blogs).Error; e != nil {
+		return e
+	}
+	if blogs > 0 {
+		return ErrUserOwnsBlogs
+	}
+
+	var comments []models.Comment
+	if e := tx.Unscoped().Select("id", "parent_id").Where("user_id = ?", id).Find(&comments).Error; e != nil {
+		return e
+	}
+	for _, comment := range comments {
+		if e := tx.Unscoped().Model(&models.Comment{}).Where("parent_id = ?", comment.ID).UpdateColumn("parent_id", comment.ParentID).Error; e != nil {
+			return e
+		}
+	}
+	if e := tx.Unscoped().Where("user_id = ?", id).Delete(&models.Comment{}).Error; e != nil {
+		return e
+	}
+
+	if e := tx.Model(&models.BlogRevision{}).Where("user_id = ?", id).
+		UpdateColumn("user_id", tx.Unscoped().Model(&models.Blog{}).Select("user_id").Where("blogs.id = blog_revisions.blog_id")).Error; e != nil {
+		return e
+	}
+	if e := tx.Unscoped().Where("user_id = ?", id).Delete(&models.RefreshToken{}).Error; e != nil {
+		return e
+	}
+	return tx.Unscoped().Delete(&models.User{}, id).Error
+}
+
+// PurgeTrash permanently deletes the blogs and then the users that were
+// trashed before the given time, and returns how many of each went. Users
+// who still own blogs are kept until their blogs are purged too.
+func PurgeTrash(before time.Time) (int, int, error) {
+	var blogIds, userIds []uint
+
+	if e := config.DB.Model(&models.Blog{}).Scopes(trashed("blogs")).Where("deleted_at < ?", before).Pluck("id", &blogIds).Error; e != nil {
+		return 0, 0, e
+	}
+	blogs := 0
+	for _, id := range blogIds {
+		if e := config.DB.Transaction(func(tx *gorm.DB) error { return purgeBlog(tx, id) }); e != nil {
+			return blogs, 0, e
+		}
+		blogs++
+	}
+
+	if e := config.DB.Model(&models.User{}).Scopes(trashed("users")).Where("deleted_at < ?", before).Pluck("id", &userIds).Error; e != nil {
+		return blogs, 0, e
+	}
+	users := 0
+	for _, id := range userIds {
+		e := config.DB.Transaction(func(tx *gorm.DB) error { return purgeUser(tx, id) })
+		if errors.Is(e, ErrUserOwnsBlogs) {
+			continue
+		}
+		if e != nil {
+			return blogs, users, e
+		}
+		users++
+	}
+	return blogs, users, nil
+}
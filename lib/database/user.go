@@ -1,60 +1,97 @@
 package database
 
 import (
-	"echo-blog/config"
-	"echo-blog/middlewares"
 	"echo-blog/models"
-	"errors"
+	"echo-blog/service"
 
-	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
-func GetAllUsers() (interface{}, error) {
-	var users []models.User
-	if e := config.DB.Find(&users).Error; e != nil {
-		return nil, e
+// GormUserRepository keeps users in the database DB, it is the
+// service.UserRepository of the app.
+type GormUserRepository struct {
+	DB *gorm.DB
+}
+
+func NewGormUserRepository(db *gorm.DB) *GormUserRepository {
+	return &GormUserRepository{DB: db}
+}
+
+func (r *GormUserRepository) List(filter models.UserFilter, page models.PageRequest) ([]models.User, models.PageMeta, error) {
+	query := r.DB.Model(&models.User{})
+	if filter.Role != "" {
+		query = query.Where("users.role = ?", filter.Role)
 	}
-	return users, nil
+	if filter.From != nil {
+		query = query.Where("users.created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("users.created_at < ?", *filter.To)
+	}
+
+	return findPage(query, "users", page, func(user models.User) models.Cursor {
+		return models.Cursor{CreatedAt: user.CreatedAt, ID: user.ID}
+	})
 }
 
-func GetUserByID(id string) (interface{}, error) {
+func (r *GormUserRepository) FindByID(id uint) (models.User, error) {
 	var user models.User
 
-	if e := config.DB.First(&user, id).Error; e != nil {
-		return nil, e
+	if e := r.DB.First(&user, id).Error; e != nil {
+		return user, e
 	}
 	return user, nil
 }
 
-func DeleteUserByID(id string) (interface{}, error) {
+func (r *GormUserRepository) FindByEmail(email string) (models.User, error) {
 	var user models.User
 
-	if rowsAff := config.DB.Delete(&user, id).RowsAffected; rowsAff == 0 {
-		return nil, errors.New("delete failed, user id not found")
+	if e := r.DB.Where("email = ?", email).First(&user).Error; e != nil {
+		return user, e
 	}
 	return user, nil
 }
 
-func LoginUser(user *models.User) (interface{}, error) {
-	var err error
-	foundUser := models.User{}
+func (r *GormUserRepository) Create(user *models.User) error {
+	return r.DB.Create(user).Error
+}
 
-	if err = config.DB.Where("email = ?", user.Email).First(&foundUser).Error; err != nil {
-		return nil, err
+// Update applies the non-zero fields of changes to the user.
+func (r *GormUserRepository) Update(id uint, changes models.User) error {
+	result := r.DB.Model(&models.User{}).Where("id = ?", id).Updates(changes)
+	if result.Error != nil {
+		return result.Error
 	}
-
-	if err = bcrypt.CompareHashAndPassword([]byte(foundUser.Password), []byte(user.Password)); err != nil {
-		return nil, err
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
+	return nil
+}
 
-	user.Token, err = middlewares.CreateToken(int(foundUser.ID))
-	if err != nil {
-		return nil, err
+// Delete moves a user to the trash.
+func (r *GormUserRepository) Delete(id uint) error {
+	result := r.DB.Delete(&models.User{}, id)
+	if result.Error != nil {
+		return result.Error
 	}
-
-	if err := config.DB.Model(&foundUser).Update("token", user.Token).Error; err != nil {
-		return nil, err
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
 	}
+	return nil
+}
 
-	return user, nil
+// EmailTaken reports whether a user other than exceptId already uses email.
+func (r *GormUserRepository) EmailTaken(email string, exceptId uint) (bool, error) {
+	var count int64
+
+	err := r.DB.Model(&models.User{}).Where("email = ? AND id <> ?", email, exceptId).Count(&count).Error
+	return count > 0, err
+}
+
+// Transaction runs fn with a user and a session repository on the same
+// database transaction.
+func (r *GormUserRepository) Transaction(fn func(users service.UserRepository, sessions service.SessionRepository) error) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(NewGormUserRepository(tx), NewGormSessionRepository(tx))
+	})
 }
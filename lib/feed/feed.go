@@ -0,0 +1,284 @@
+// Package feed writes blog feeds as RSS 2.0, Atom and JSON Feed.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"html"
+	"strings"
+	"time"
+)
+
+const (
+	RSSContentType  = "application/rss+xml; charset=utf-8"
+	AtomContentType = "application/atom+xml; charset=utf-8"
+	JSONContentType = "application/feed+json; charset=utf-8"
+)
+
+// Feed is a list of entries, newest first, independent of the format it is
+// written in. Link is the page the feed belongs to, FeedURL the feed
+// itself.
+type Feed struct {
+	Title       string
+	Description string
+	Link        string
+	FeedURL     string
+	Updated     time.Time
+	Items       []Item
+}
+
+// Item is one entry of a feed. Summary is always set, Content only when the
+// feed carries full content. ContentHTML says whether Content is HTML or
+// plain text.
+type Item struct {
+	ID          string
+	Title       string
+	Link        string
+	Author      string
+	Tags        []string
+	Summary     string
+	Content     string
+	ContentHTML bool
+	Published   time.Time
+	Updated     time.Time
+}
+
+type rss struct {
+	XMLName       xml.Name   `xml:"rss"`
+	Version       string     `xml:"version,attr"`
+	AtomNamespace string     `xml:"xmlns:atom,attr"`
+	DCNamespace   string     `xml:"xmlns:dc,attr"`
+	ContentModule string     `xml:"xmlns:content,attr"`
+	Channel       rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	SelfLink      atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+	Content     *cdata   `xml:"content:encoded,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type cdata struct {
+	Value string `xml:",cdata"`
+}
+
+// RSS writes the feed as RSS 2.0. Full content goes into content:encoded,
+// the summary into description.
+func (f Feed) RSS() ([]byte, error) {
+	channel := rssChannel{
+		Title:       f.Title,
+		Link:        f.Link,
+		Description: f.Description,
+		SelfLink:    atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+		Items:       []rssItem{},
+	}
+	if !f.Updated.IsZero() {
+		channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+
+	for _, item := range f.Items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{Value: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Creator:     item.Author,
+			Categories:  item.Tags,
+			Description: item.Summary,
+		}
+		if item.ContentHTML {
+			entry.Content = &cdata{Value: item.Content}
+		} else if item.Content != "" {
+			entry.Content = &cdata{Value: textToHTML(item.Content)}
+		}
+		channel.Items = append(channel.Items, entry)
+	}
+
+	return marshalXML(rss{
+		Version:       "2.0",
+		AtomNamespace: "http://www.w3.org/2005/Atom",
+		DCNamespace:   "http://purl.org/dc/elements/1.1/",
+		ContentModule: "http://purl.org/rss/1.0/modules/content/",
+		Channel:       channel,
+	})
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomAuthor    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    atomText       `xml:"summary"`
+	Content    *atomText      `xml:"content,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom writes the feed as Atom 1.0.
+func (f Feed) Atom() ([]byte, error) {
+	feed := atomFeed{
+		Xmlns:   "http://www.w3.org/2005/Atom",
+		Title:   f.Title,
+		ID:      f.FeedURL,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.Link, Rel: "alternate"},
+		},
+		Entries: []atomEntry{},
+	}
+
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:     item.Title,
+			ID:        item.ID,
+			Link:      atomLink{Href: item.Link, Rel: "alternate"},
+			Published: item.Published.UTC().Format(time.RFC3339),
+			Updated:   item.Updated.UTC().Format(time.RFC3339),
+			Summary:   atomText{Type: "text", Value: item.Summary},
+		}
+		if item.Author != "" {
+			entry.Author = &atomAuthor{Name: item.Author}
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		if item.Content != "" {
+			entry.Content = &atomText{Type: "text", Value: item.Content}
+			if item.ContentHTML {
+				entry.Content.Type = "html"
+			}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return marshalXML(feed)
+}
+
+type jsonFeed struct {
+	Version     string     `json:"version"`
+	Title       string     `json:"title"`
+	HomePageURL string     `json:"home_page_url"`
+	FeedURL     string     `json:"feed_url"`
+	Description string     `json:"description,omitempty"`
+	Items       []jsonItem `json:"items"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url"`
+	Title         string       `json:"title"`
+	Summary       string       `json:"summary"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	DatePublished string       `json:"date_published"`
+	DateModified  string       `json:"date_modified"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+	Tags          []string     `json:"tags,omitempty"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+// JSON writes the feed as JSON Feed 1.1. Items without full content carry
+// their summary as content_text, the spec requires some content.
+func (f Feed) JSON() ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.Link,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Items:       []jsonItem{},
+	}
+
+	for _, item := range f.Items {
+		entry := jsonItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			Summary:       item.Summary,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+			Tags:          item.Tags,
+		}
+		switch {
+		case item.Content == "":
+			entry.ContentText = item.Summary
+		case item.ContentHTML:
+			entry.ContentHTML = item.Content
+		default:
+			entry.ContentText = item.Content
+		}
+		if item.Author != "" {
+			entry.Authors = []jsonAuthor{{Name: item.Author}}
+		}
+		feed.Items = append(feed.Items, entry)
+	}
+
+	return json.MarshalIndent(feed, "", "  ")
+}
+
+// textToHTML escapes plain text for content:encoded, which readers show as
+// HTML, keeping its line breaks.
+func textToHTML(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n", "<br>\n")
+}
+
+func marshalXML(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
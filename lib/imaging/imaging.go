@@ -0,0 +1,206 @@
+// Package imaging prepares uploaded images for the web: it strips their
+// metadata and makes smaller variants for thumbnails and srcset.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	// formats image.Decode understands
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// ThumbnailSize is the width and height of the square thumbnail.
+	ThumbnailSize = 160
+	ThumbnailName = "thumbnail"
+
+	jpegQuality = 85
+)
+
+// DefaultWidths are the widths of the variants made for srcset.
+var DefaultWidths = []int{320, 640, 1280}
+
+// MaxPixels guards against images that would take too much memory to
+// decode.
+var MaxPixels = 40_000_000
+
+var ErrTooLarge = errors.New("image has too many pixels")
+
+// Variant is a smaller version of an image, Name is "thumbnail" or the
+// width followed by "w", like "640w".
+type Variant struct {
+	Name        string
+	Width       int
+	Height      int
+	ContentType string
+	Data        []byte
+}
+
+// Result is a processed image. Data is the original without metadata,
+// turned upright when its EXIF orientation said it was rotated.
+type Result struct {
+	Data     []byte
+	Width    int
+	Height   int
+	Variants []Variant
+}
+
+// IsImage reports whether Process can handle files of contentType.
+func IsImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// Process strips the metadata of an image and makes a thumbnail and a
+// variant for each of widths smaller than the image. Variants of JPEG and
+// opaque WebP images are JPEG, the others PNG, as there is no WebP encoder
+// in Go.
+func Process(contentType string, data []byte, widths []int) (Result, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, err
+	}
+	if config.Width*config.Height > MaxPixels {
+		return Result{}, ErrTooLarge
+	}
+
+	stripped, orientation, err := StripMetadata(contentType, data)
+	if err != nil {
+		return Result{}, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{Data: stripped}
+	if orientation > 1 {
+		img = Orient(img, orientation)
+		// webp cannot be written back, it keeps its orientation
+		if contentType == "image/jpeg" || contentType == "image/png" {
+			if result.Data, err = encode(img, contentType); err != nil {
+				return Result{}, err
+			}
+		}
+	}
+	bounds := img.Bounds()
+	result.Width, result.Height = bounds.Dx(), bounds.Dy()
+
+	variantType := "image/png"
+	if opaque, ok := img.(interface{ Opaque() bool }); contentType == "image/jpeg" || contentType == "image/webp" && ok && opaque.Opaque() {
+		variantType = "image/jpeg"
+	}
+
+	thumbnail, err := encode(Thumbnail(img, ThumbnailSize), variantType)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Variants = append(result.Variants, Variant{Name: ThumbnailName, Width: ThumbnailSize, Height: ThumbnailSize, ContentType: variantType, Data: thumbnail})
+
+	for _, width := range widths {
+		if width <= 0 || width >= result.Width {
+			continue
+		}
+		resized := Resize(img, width)
+		encoded, err := encode(resized, variantType)
+		if err != nil {
+			return Result{}, err
+		}
+		result.Variants = append(result.Variants, Variant{
+			Name:        fmt.Sprintf("%dw", width),
+			Width:       width,
+			Height:      resized.Bounds().Dy(),
+			ContentType: variantType,
+			Data:        encoded,
+		})
+	}
+	return result, nil
+}
+
+// Resize scales img to width, keeping its aspect ratio.
+func Resize(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+// Thumbnail crops the center square of img and scales it to size.
+func Thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	if bounds.Dy() < side {
+		side = bounds.Dy()
+	}
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, image.Rect(x, y, x+side, y+side), draw.Src, nil)
+	return dst
+}
+
+// Orient turns an image stored with an EXIF orientation from 2 to 8
+// upright.
+func Orient(img image.Image, orientation int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			default:
+				dx, dy = x, y
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,183 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed image")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// StripMetadata removes the EXIF, XMP and IPTC metadata of a JPEG, PNG or
+// WebP file without decoding the image, and returns the EXIF orientation
+// it found, 1 when there was none. Other types are returned as they are.
+func StripMetadata(contentType string, data []byte) ([]byte, int, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	}
+	return data, 1, nil
+}
+
+// stripJPEG drops APP1 (EXIF, XMP), APP13 (IPTC) and comment segments.
+// Everything from the start of the scan on is image data and kept as is.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 1, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	orientation := 1
+
+	i := 2
+	for i < len(data) {
+		if i+2 > len(data) || data[i] != 0xFF {
+			return nil, 1, errMalformed
+		}
+		marker := data[i+1]
+		switch {
+		case marker == 0xFF:
+			i++
+			continue
+		case marker == 0xDA || marker == 0xD9:
+			return append(out, data[i:]...), orientation, nil
+		case marker >= 0xD0 && marker <= 0xD7 || marker == 0x01:
+			out = append(out, data[i:i+2]...)
+			i += 2
+			continue
+		}
+
+		if i+4 > len(data) {
+			return nil, 1, errMalformed
+		}
+		end := i + 2 + int(binary.BigEndian.Uint16(data[i+2:i+4]))
+		if end > len(data) {
+			return nil, 1, errMalformed
+		}
+		segment := data[i:end]
+		switch marker {
+		case 0xE1:
+			if payload := segment[4:]; bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				orientation = exifOrientation(payload[6:])
+			}
+		case 0xED, 0xFE:
+		default:
+			out = append(out, segment...)
+		}
+		i = end
+	}
+	return out, orientation, nil
+}
+
+// stripPNG drops the eXIf chunk and the text and time chunks.
+func stripPNG(data []byte) ([]byte, int, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, 1, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	orientation := 1
+
+	for i := len(pngSignature); i < len(data); {
+		if i+8 > len(data) {
+			return nil, 1, errMalformed
+		}
+		length := int(binary.BigEndian.Uint32(data[i : i+4]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, 1, errMalformed
+		}
+		switch string(data[i+4 : i+8]) {
+		case "eXIf":
+			orientation = exifOrientation(data[i+8 : i+8+length])
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+	return out, orientation, nil
+}
+
+// stripWebP drops the EXIF and XMP chunks of an extended WebP file and
+// clears their flags in the VP8X header.
+func stripWebP(data []byte) ([]byte, int, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, 1, errMalformed
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	orientation := 1
+
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, 1, errMalformed
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		end := i + 8 + size + size%2
+		if size < 0 || end > len(data) {
+			return nil, 1, errMalformed
+		}
+		chunk := data[i:end]
+		switch string(chunk[0:4]) {
+		case "EXIF":
+			exif := chunk[8 : 8+size]
+			orientation = exifOrientation(bytes.TrimPrefix(exif, []byte("Exif\x00\x00")))
+		case "XMP ":
+		case "VP8X":
+			start := len(out)
+			out = append(out, chunk...)
+			if size > 0 {
+				out[start+8] &^= 0x08 | 0x04
+			}
+		default:
+			out = append(out, chunk...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:8], uint32(len(out)-8))
+	return out, orientation, nil
+}
+
+// exifOrientation reads the orientation tag from the first IFD of TIFF
+// formatted EXIF data, 1 when it is missing or invalid.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[0:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset : offset+2]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8 : entry+10]))
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+			return orientation
+		}
+	}
+	return 1
+}
@@ -0,0 +1,282 @@
+// Package migration applies numbered schema changes to the database and
+// records the applied ones in the schema_migrations table. Every migration
+// can be reverted by its Down.
+//
+// A migration that has been released must not change anymore, a later
+// change to the schema is a new migration with a higher version.
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// lockName and lockKey name the advisory lock on MySQL and PostgreSQL.
+	lockName = "echo-blog:schema_migrations"
+	lockKey  = 7_410_528_366_190_422
+	// LockTimeout is how long to wait for another instance to finish.
+	LockTimeout = time.Minute
+)
+
+var (
+	ErrUnknownVersion = errors.New("unknown migration version")
+	ErrLocked         = errors.New("migrations are being run by another instance")
+	ErrIrreversible   = errors.New("migration cannot be reverted")
+)
+
+// Migration changes the schema from Version-1 to Version. Up and Down run in
+// a transaction, on MySQL schema changes commit by themselves though, so
+// they are written to be run again after a failure.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// Record is the row of an applied migration.
+type Record struct {
+	Version   int    `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"size:255"`
+	AppliedAt time.Time
+}
+
+func (Record) TableName() string {
+	return "schema_migrations"
+}
+
+// State is a migration as listed by Status. Name is empty for a version that
+// is applied but unknown to this build, applied by a newer one.
+type State struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+func (s State) Applied() bool {
+	return s.AppliedAt != nil
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+// New returns a Migrator for migrations, they are sorted by version.
+func New(db *gorm.DB, migrations []Migration) *Migrator {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// Latest is the version the migrations bring the schema to.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the last steps applied migrations.
+func (m *Migrator) Down(steps int) error {
+	return m.locked(func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			if _, ok := applied[m.migrations[i].Version]; !ok {
+				continue
+			}
+			if err := m.revert(conn, m.migrations[i]); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To applies or reverts migrations until the schema is at version, 0
+// reverts them all.
+func (m *Migrator) To(version int) error {
+	if version != 0 && m.find(version) < 0 {
+		return fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+
+	return m.locked(func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && m.migrations[i].Version > version; i-- {
+			if _, ok := applied[m.migrations[i].Version]; ok {
+				if err := m.revert(conn, m.migrations[i]); err != nil {
+					return err
+				}
+			}
+		}
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; !ok {
+				if err := m.apply(conn, migration); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every migration and when it was applied, sorted by version.
+func (m *Migrator) Status() ([]State, error) {
+	applied := map[int]Record{}
+	if m.db.Migrator().HasTable(&Record{}) {
+		var err error
+		if applied, err = appliedVersions(m.db); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]State, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		state := State{Version: migration.Version, Name: migration.Name}
+		if record, ok := applied[migration.Version]; ok {
+			state.AppliedAt = &record.AppliedAt
+			delete(applied, migration.Version)
+		}
+		states = append(states, state)
+	}
+	for _, record := range applied {
+		record := record
+		states = append(states, State{Version: record.Version, AppliedAt: &record.AppliedAt})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// Pending lists the migrations Up would apply.
+func (m *Migrator) Pending() ([]Migration, error) {
+	states, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, state := range states {
+		if i := m.find(state.Version); i >= 0 && !state.Applied() {
+			pending = append(pending, m.migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) find(version int) int {
+	for i, migration := range m.migrations {
+		if migration.Version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Migrator) apply(conn *gorm.DB, migration Migration) error {
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&Record{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("migration %d %s applied\n", migration.Version, migration.Name)
+	return nil
+}
+
+func (m *Migrator) revert(conn *gorm.DB, migration Migration) error {
+	if migration.Down == nil {
+		return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, ErrIrreversible)
+	}
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&Record{}, migration.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+	log.Printf("migration %d %s reverted\n", migration.Version, migration.Name)
+	return nil
+}
+
+func appliedVersions(db *gorm.DB) (map[int]Record, error) {
+	var records []Record
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]Record, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// localLock serializes the migrators of this process on databases without
+// advisory locks.
+var localLock sync.Mutex
+
+// locked runs fn on a single connection holding the migration lock, so
+// instances starting together do not migrate at the same time. MySQL and
+// PostgreSQL use an advisory lock. SQLite has none, there only migrators of
+// the same process are kept apart.
+func (m *Migrator) locked(fn func(conn *gorm.DB) error) error {
+	return m.db.Connection(func(conn *gorm.DB) error {
+		// every statement starts afresh, still on the locked connection
+		conn = conn.Session(&gorm.Session{NewDB: true})
+		switch conn.Dialector.Name() {
+		case "mysql":
+			var got int
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", lockName, int(LockTimeout.Seconds())).Scan(&got).Error; err != nil {
+				return err
+			}
+			if got != 1 {
+				return ErrLocked
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", lockName)
+		case "postgres":
+			if err := conn.Exec(fmt.Sprintf("SET lock_timeout = %d", LockTimeout.Milliseconds())).Error; err != nil {
+				return err
+			}
+			err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error
+			conn.Exec("RESET lock_timeout")
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrLocked, err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", lockKey)
+		default:
+			localLock.Lock()
+			defer localLock.Unlock()
+		}
+
+		if err := conn.AutoMigrate(&Record{}); err != nil {
+			return err
+		}
+		return fn(conn)
+	})
+}
@@ -0,0 +1,79 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// highlightStyle is the chroma style of HighlightCSS.
+const highlightStyle = "github"
+
+// code is highlighted with classes instead of inline styles, the sanitizer
+// drops style attributes
+var formatter = chromahtml.New(chromahtml.WithClasses(true))
+
+// highlightBlock replaces the content of a <pre><code class="language-x">
+// block, as written by markdown code fences, with its highlighted version.
+// It reports whether it did, blocks without a known language stay as they
+// are.
+func highlightBlock(pre *html.Node) bool {
+	code := pre.FirstChild
+	for code != nil && code.Type == html.TextNode && strings.TrimSpace(code.Data) == "" {
+		code = code.NextSibling
+	}
+	if code == nil || code.DataAtom != atom.Code {
+		return false
+	}
+
+	var language string
+	for _, class := range strings.Fields(attr(code, "class")) {
+		if strings.HasPrefix(class, "language-") {
+			language = strings.TrimPrefix(class, "language-")
+		}
+	}
+	lexer := lexers.Get(language)
+	if language == "" || lexer == nil {
+		return false
+	}
+
+	tokens, err := chroma.Coalesce(lexer).Tokenise(nil, textContent(code))
+	if err != nil {
+		return false
+	}
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, styles.Get(highlightStyle), tokens); err != nil {
+		return false
+	}
+	nodes, err := html.ParseFragment(&buf, &html.Node{Type: html.ElementNode, Data: "div", DataAtom: atom.Div})
+	if err != nil || len(nodes) != 1 || nodes[0].DataAtom != atom.Pre {
+		return false
+	}
+
+	highlighted := nodes[0]
+	pre.Attr = highlighted.Attr
+	for pre.FirstChild != nil {
+		pre.RemoveChild(pre.FirstChild)
+	}
+	for highlighted.FirstChild != nil {
+		child := highlighted.FirstChild
+		highlighted.RemoveChild(child)
+		pre.AppendChild(child)
+	}
+	return true
+}
+
+// HighlightCSS is the stylesheet for the classes of highlighted code.
+func HighlightCSS() (string, error) {
+	var buf bytes.Buffer
+	if err := formatter.WriteCSS(&buf, styles.Get(highlightStyle)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
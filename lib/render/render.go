@@ -0,0 +1,185 @@
+// Package render turns the body of a blog into safe HTML. Markdown, HTML
+// and plain text are first converted to HTML, then headings get anchors,
+// code blocks get highlighted and the result goes through an allow-list
+// sanitizer.
+package render
+
+import (
+	"bytes"
+	"echo-blog/helper"
+	"echo-blog/models"
+	"fmt"
+	stdhtml "html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	markdown = goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		// raw HTML is kept here and cleaned by the sanitizer like the html format
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+
+	policy = newPolicy()
+	strict = bluemonday.StrictPolicy()
+
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+)
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// classes of highlighted code and of fenced code blocks
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^[\w\- ]+$`)).OnElements("pre", "code", "span")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^[a-z0-9\-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	return p
+}
+
+// Render converts body written in format to sanitized HTML and returns it
+// with the table of contents of its headings.
+func Render(format string, body string) (string, []models.TOCEntry, error) {
+	var source string
+	switch format {
+	case models.ContentFormatMarkdown, "":
+		var buf bytes.Buffer
+		if err := markdown.Convert([]byte(body), &buf); err != nil {
+			return "", nil, err
+		}
+		source = buf.String()
+	case models.ContentFormatHTML:
+		source = body
+	case models.ContentFormatPlain:
+		source = plainToHTML(body)
+	default:
+		return "", nil, fmt.Errorf("unknown content format %q", format)
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(source), &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body})
+	if err != nil {
+		return "", nil, err
+	}
+	d := decorator{ids: map[string]bool{}, toc: []models.TOCEntry{}}
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		d.walk(node)
+		if err := html.Render(&buf, node); err != nil {
+			return "", nil, err
+		}
+	}
+	return policy.Sanitize(buf.String()), d.toc, nil
+}
+
+// Text strips the tags of rendered HTML, for excerpts and summaries.
+func Text(rendered string) string {
+	return stdhtml.UnescapeString(strict.Sanitize(rendered))
+}
+
+// plainToHTML escapes text and keeps its paragraphs and line breaks.
+func plainToHTML(text string) string {
+	var b strings.Builder
+	for _, paragraph := range paragraphBreak.Split(strings.TrimSpace(text), -1) {
+		if paragraph == "" {
+			continue
+		}
+		lines := strings.Split(stdhtml.EscapeString(paragraph), "\n")
+		b.WriteString("<p>" + strings.Join(lines, "<br>\n") + "</p>\n")
+	}
+	return b.String()
+}
+
+// decorator gives headings unique anchors while collecting them into the
+// table of contents, and highlights code blocks with a known language.
+type decorator struct {
+	ids map[string]bool
+	toc []models.TOCEntry
+}
+
+func (d *decorator) walk(node *html.Node) {
+	if node.Type == html.ElementNode {
+		if level := headingLevel(node.DataAtom); level > 0 {
+			d.heading(node, level)
+			return
+		}
+		if node.DataAtom == atom.Pre && highlightBlock(node) {
+			return
+		}
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		d.walk(child)
+	}
+}
+
+func (d *decorator) heading(node *html.Node, level int) {
+	text := strings.Join(strings.Fields(textContent(node)), " ")
+
+	id := helper.MakeSlug(attr(node, "id"))
+	if id == "" || d.ids[id] {
+		id = helper.MakeSlug(text)
+	}
+	if id == "" {
+		id = "section"
+	}
+	base := id
+	for n := 2; d.ids[id]; n++ {
+		id = fmt.Sprintf("%s-%d", base, n)
+	}
+	d.ids[id] = true
+
+	setAttr(node, "id", id)
+	d.toc = append(d.toc, models.TOCEntry{Level: level, ID: id, Text: text})
+}
+
+func headingLevel(a atom.Atom) int {
+	switch a {
+	case atom.H1:
+		return 1
+	case atom.H2:
+		return 2
+	case atom.H3:
+		return 3
+	case atom.H4:
+		return 4
+	case atom.H5:
+		return 5
+	case atom.H6:
+		return 6
+	}
+	return 0
+}
+
+func textContent(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var b strings.Builder
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		b.WriteString(textContent(child))
+	}
+	return b.String()
+}
+
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(node *html.Node, key string, value string) {
+	for i := range node.Attr {
+		if node.Attr[i].Key == key {
+			node.Attr[i].Val = value
+			return
+		}
+	}
+	node.Attr = append(node.Attr, html.Attribute{Key: key, Val: value})
+}
@@ -0,0 +1,80 @@
+package scheduler
+
+import (
+	"context"
+	"echo-blog/lib/database"
+	"echo-blog/lib/search"
+	"log"
+	"time"
+)
+
+const (
+	DefaultInterval    = time.Minute
+	TrashPurgeInterval = time.Hour
+)
+
+// Start runs the publishing scheduler in the background until ctx is done.
+// Every interval it promotes the scheduled blogs that are due. It is safe to
+// run in several instances at once, see database.PublishDueBlogs.
+func Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			PublishDueBlogs()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func PublishDueBlogs() {
+	blogs, err := database.PublishDueBlogs(time.Now())
+	if err != nil {
+		log.Printf("cannot publish scheduled blogs, error : %v\n", err)
+		return
+	}
+	for _, blog := range blogs {
+		search.Reindex(blog.ID)
+		log.Printf("scheduled blog %d published\n", blog.ID)
+	}
+}
+
+// StartTrashPurge runs the retention job in the background until ctx is
+// done. Every TrashPurgeInterval it permanently deletes what has been in the
+// trash for longer than retention.
+func StartTrashPurge(ctx context.Context, retention time.Duration) {
+	go func() {
+		ticker := time.NewTicker(TrashPurgeInterval)
+		defer ticker.Stop()
+
+		for {
+			PurgeTrash(retention)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func PurgeTrash(retention time.Duration) {
+	blogs, users, err := database.PurgeTrash(time.Now().Add(-retention))
+	if err != nil {
+		log.Printf("cannot purge trash, error : %v\n", err)
+	}
+	if blogs > 0 || users > 0 {
+		log.Printf("purged %d blogs and %d users from the trash\n", blogs, users)
+	}
+}
@@ -0,0 +1,168 @@
+package search
+
+import (
+	"echo-blog/models"
+	"math"
+	"sort"
+	"sync"
+)
+
+// BM25 parameters, the usual defaults
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+
+	// a word in the title counts as much as titleBoost words in the body
+	titleBoost = 2
+)
+
+type memoryDocument struct {
+	body      string
+	terms     []string
+	published bool
+	length    int
+}
+
+// MemorySearcher is an inverted index kept in memory and ranked with BM25.
+// It needs no database support, which makes it the engine for tests and
+// SQLite setups, but it has to be filled with Index on startup.
+type MemorySearcher struct {
+	mu          sync.RWMutex
+	documents   map[uint]memoryDocument
+	postings    map[string]map[uint]int
+	totalLength int
+}
+
+func NewMemorySearcher() *MemorySearcher {
+	return &MemorySearcher{
+		documents: map[uint]memoryDocument{},
+		postings:  map[string]map[uint]int{},
+	}
+}
+
+func (m *MemorySearcher) Index(blog models.Blog) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(blog.ID)
+	if blog.DeletedAt.Valid {
+		return nil
+	}
+
+	frequencies := map[string]int{}
+	for _, term := range Tokenize(blog.Title) {
+		frequencies[term] += titleBoost
+	}
+	for _, term := range Tokenize(blog.Body) {
+		frequencies[term]++
+	}
+
+	length := 0
+	terms := make([]string, 0, len(frequencies))
+	for term, frequency := range frequencies {
+		if m.postings[term] == nil {
+			m.postings[term] = map[uint]int{}
+		}
+		m.postings[term][blog.ID] = frequency
+		length += frequency
+		terms = append(terms, term)
+	}
+
+	m.documents[blog.ID] = memoryDocument{
+		body:      blog.Body,
+		terms:     terms,
+		published: blog.Status == models.BlogStatusPublished,
+		length:    length,
+	}
+	m.totalLength += length
+	return nil
+}
+
+func (m *MemorySearcher) Remove(blogID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(blogID)
+	return nil
+}
+
+func (m *MemorySearcher) remove(blogID uint) {
+	document, ok := m.documents[blogID]
+	if !ok {
+		return
+	}
+	for _, term := range document.terms {
+		delete(m.postings[term], blogID)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	m.totalLength -= document.length
+	delete(m.documents, blogID)
+}
+
+func (m *MemorySearcher) Search(query string, limit int, offset int) ([]Hit, int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := uniqueTerms(Tokenize(query))
+	if len(terms) == 0 || len(m.documents) == 0 {
+		return nil, 0, nil
+	}
+
+	count := float64(len(m.documents))
+	averageLength := float64(m.totalLength) / count
+
+	scores := map[uint]float64{}
+	for _, term := range terms {
+		posting := m.postings[term]
+		frequency := float64(len(posting))
+		idf := math.Log(1 + (count-frequency+0.5)/(frequency+0.5))
+
+		for blogID, termFrequency := range posting {
+			document := m.documents[blogID]
+			if !document.published {
+				continue
+			}
+			tf := float64(termFrequency)
+			norm := 1 - bm25B + bm25B*float64(document.length)/averageLength
+			scores[blogID] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for blogID, score := range scores {
+		hits = append(hits, Hit{BlogID: blogID, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].BlogID < hits[j].BlogID
+	})
+
+	total := int64(len(hits))
+	if offset >= len(hits) {
+		return []Hit{}, total, nil
+	}
+	hits = hits[offset:]
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	for i := range hits {
+		hits[i].Snippet = Highlight(m.documents[hits[i].BlogID].body, terms)
+	}
+	return hits, total, nil
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,61 @@
+package search
+
+import (
+	"echo-blog/models"
+
+	"gorm.io/gorm"
+)
+
+// MySQLSearcher searches with the FULLTEXT index on blogs (title, body) that
+// the blog_fulltext_index migration creates on MySQL, see config.Migrations.
+// MySQL keeps that index up to date by itself, so Index and Remove have
+// nothing to do.
+type MySQLSearcher struct {
+	DB *gorm.DB
+}
+
+func NewMySQLSearcher(db *gorm.DB) *MySQLSearcher {
+	return &MySQLSearcher{DB: db}
+}
+
+func (s *MySQLSearcher) Index(blog models.Blog) error {
+	return nil
+}
+
+func (s *MySQLSearcher) Remove(blogID uint) error {
+	return nil
+}
+
+func (s *MySQLSearcher) Search(query string, limit int, offset int) ([]Hit, int64, error) {
+	const match = "MATCH (title, body) AGAINST (? IN NATURAL LANGUAGE MODE)"
+
+	published := s.DB.Model(&models.Blog{}).
+		Where("status = ?", models.BlogStatusPublished).
+		Where(match, query)
+
+	var total int64
+	if err := published.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		ID    uint
+		Body  string
+		Score float64
+	}
+	err := published.Session(&gorm.Session{}).
+		Select("id, body, "+match+" AS score", query).
+		Order("score DESC, id").
+		Limit(limit).Offset(offset).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	terms := Tokenize(query)
+	hits := make([]Hit, 0, len(rows))
+	for _, row := range rows {
+		hits = append(hits, Hit{BlogID: row.ID, Score: row.Score, Snippet: Highlight(row.Body, terms)})
+	}
+	return hits, total, nil
+}
@@ -0,0 +1,186 @@
+package search
+
+import (
+	"echo-blog/config"
+	"echo-blog/models"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/gosimple/unidecode"
+	"gorm.io/gorm"
+)
+
+// Hit is one blog matching a search, best hits come first.
+type Hit struct {
+	BlogID  uint
+	Score   float64
+	Snippet string
+}
+
+// Searcher finds published blogs by the words in their title and body.
+// Index and Remove keep it in sync with the blogs table, implementations
+// that search the table directly may treat them as no-ops.
+type Searcher interface {
+	Index(blog models.Blog) error
+	Remove(blogID uint) error
+	Search(query string, limit int, offset int) ([]Hit, int64, error)
+}
+
+// Engine is the searcher used by the API, set up by Setup.
+var Engine Searcher
+
+var ErrNotConfigured = errors.New("search is not configured")
+
+// Setup picks the search engine: "mysql" uses the FULLTEXT index of the
+// blogs table and only works on MySQL, "memory" keeps an inverted index in
+// the process and fills it from the database. Without a driver mysql is used
+// on MySQL databases and memory otherwise.
+func Setup(driver string) error {
+	if driver == "" {
+		driver = "memory"
+		if config.DB.Dialector.Name() == "mysql" {
+			driver = "mysql"
+		}
+	}
+
+	switch driver {
+	case "mysql":
+		if config.DB.Dialector.Name() != "mysql" {
+			return fmt.Errorf("search driver mysql needs a MySQL database, not %s", config.DB.Dialector.Name())
+		}
+		Engine = NewMySQLSearcher(config.DB)
+	case "memory":
+		memory := NewMemorySearcher()
+		var blogs []models.Blog
+		if err := config.DB.Find(&blogs).Error; err != nil {
+			return err
+		}
+		for _, blog := range blogs {
+			memory.Index(blog)
+		}
+		Engine = memory
+	default:
+		return fmt.Errorf("unknown search driver %q", driver)
+	}
+	return nil
+}
+
+// Tokenize splits text into lowercase ASCII words, so "Café" and "cafe" are
+// the same term.
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(unidecode.Unidecode(text)), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+const snippetLength = 160
+
+// Highlight cuts a snippet of about snippetLength characters out of text
+// around the first word matching terms and wraps the matching words in
+// <mark>. Everything else is HTML escaped.
+func Highlight(text string, terms []string) string {
+	wanted := make(map[string]bool, len(terms))
+	for _, term := range terms {
+		wanted[term] = true
+	}
+
+	type word struct{ start, end int }
+	runes := []rune(text)
+	var words []word
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		start := i
+		for i < len(runes) && isWordRune(runes[i]) {
+			i++
+		}
+		words = append(words, word{start, i})
+	}
+
+	matches := func(w word) bool {
+		for _, token := range Tokenize(string(runes[w.start:w.end])) {
+			if wanted[token] {
+				return true
+			}
+		}
+		return false
+	}
+
+	from := 0
+	for _, w := range words {
+		if matches(w) {
+			from = w.start - snippetLength/3
+			break
+		}
+	}
+	if from < 0 {
+		from = 0
+	}
+	to := from + snippetLength
+	if to > len(runes) {
+		to = len(runes)
+	}
+	// do not cut words in half at either end
+	for _, w := range words {
+		if w.start < from && from < w.end {
+			from = w.end
+		}
+		if w.start < to && to < w.end {
+			to = w.start
+		}
+	}
+
+	var snippet strings.Builder
+	position := from
+	for _, w := range words {
+		// only whole words inside the window
+		if w.start < from || w.end > to {
+			continue
+		}
+		if !matches(w) {
+			continue
+		}
+		snippet.WriteString(html.EscapeString(string(runes[position:w.start])))
+		snippet.WriteString("<mark>" + html.EscapeString(string(runes[w.start:w.end])) + "</mark>")
+		position = w.end
+	}
+	snippet.WriteString(html.EscapeString(string(runes[position:to])))
+
+	result := strings.TrimSpace(snippet.String())
+	if from > 0 {
+		result = "…" + result
+	}
+	if to < len(runes) {
+		result += "…"
+	}
+	return result
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Reindex brings the engine up to date with the stored blog, call it after
+// every change to a blog.
+func Reindex(blogID uint) {
+	if Engine == nil {
+		return
+	}
+
+	var blog models.Blog
+	err := config.DB.First(&blog, blogID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = Engine.Remove(blogID)
+	} else if err == nil {
+		err = Engine.Index(blog)
+	}
+	if err != nil {
+		log.Printf("cannot update search index for blog %d, error : %v\n", blogID, err)
+	}
+}
@@ -0,0 +1,93 @@
+// Package sitemap writes XML sitemaps and sitemap indexes as described on
+// sitemaps.org.
+package sitemap
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"time"
+)
+
+const (
+	ContentType     = "application/xml; charset=utf-8"
+	GzipContentType = "application/gzip"
+	namespace       = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+// MaxURLs is how many URLs one sitemap holds before the list is split
+// behind a sitemap index, the limit of the protocol.
+var MaxURLs = 50000
+
+type URL struct {
+	Loc     string
+	LastMod time.Time
+}
+
+type urlSet struct {
+	XMLName xml.Name   `xml:"urlset"`
+	Xmlns   string     `xml:"xmlns,attr"`
+	URLs    []location `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name   `xml:"sitemapindex"`
+	Xmlns    string     `xml:"xmlns,attr"`
+	Sitemaps []location `xml:"sitemap"`
+}
+
+type location struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// Pages is how many sitemaps are needed for count URLs.
+func Pages(count int64) int {
+	if count <= int64(MaxURLs) {
+		return 1
+	}
+	return int((count + int64(MaxURLs) - 1) / int64(MaxURLs))
+}
+
+// URLSet writes a sitemap listing urls.
+func URLSet(urls []URL) ([]byte, error) {
+	set := urlSet{Xmlns: namespace, URLs: []location{}}
+	for _, url := range urls {
+		entry := location{Loc: url.Loc}
+		if !url.LastMod.IsZero() {
+			entry.LastMod = url.LastMod.UTC().Format(time.RFC3339)
+		}
+		set.URLs = append(set.URLs, entry)
+	}
+	return marshal(set)
+}
+
+// Index writes a sitemap index pointing to the sitemaps at locs.
+func Index(locs []string) ([]byte, error) {
+	index := sitemapIndex{Xmlns: namespace, Sitemaps: []location{}}
+	for _, loc := range locs {
+		index.Sitemaps = append(index.Sitemaps, location{Loc: loc})
+	}
+	return marshal(index)
+}
+
+// Gzip compresses a sitemap for the .xml.gz variants.
+func Gzip(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshal(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
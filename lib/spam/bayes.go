@@ -0,0 +1,102 @@
+package spam
+
+import (
+	"echo-blog/lib/search"
+	"math"
+	"sort"
+	"sync"
+)
+
+const (
+	// the scorer stays neutral until it learned this many texts of each kind
+	minTrainingTexts = 5
+
+	// only the words telling the most about a text are combined
+	interestingWords = 15
+)
+
+// Bayes is a naive Bayes spam scorer. It counts in how many spam and ham
+// (not spam) texts each word appears.
+type Bayes struct {
+	mu        sync.RWMutex
+	spam      map[string]int
+	ham       map[string]int
+	spamTexts int
+	hamTexts  int
+}
+
+func NewBayes() *Bayes {
+	return &Bayes{spam: map[string]int{}, ham: map[string]int{}}
+}
+
+func (b *Bayes) Learn(text string, spam bool) {
+	b.update(text, spam, 1)
+}
+
+func (b *Bayes) Unlearn(text string, spam bool) {
+	b.update(text, spam, -1)
+}
+
+func (b *Bayes) update(text string, spam bool, delta int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	counts, texts := b.ham, &b.hamTexts
+	if spam {
+		counts, texts = b.spam, &b.spamTexts
+	}
+
+	*texts += delta
+	if *texts < 0 {
+		*texts = 0
+	}
+	for word := range uniqueWords(text) {
+		if n := counts[word] + delta; n > 0 {
+			counts[word] = n
+		} else {
+			delete(counts, word)
+		}
+	}
+}
+
+// Score returns the probability that text is spam.
+func (b *Bayes) Score(text string) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.spamTexts < minTrainingTexts || b.hamTexts < minTrainingTexts {
+		return 0.5
+	}
+
+	var probabilities []float64
+	for word := range uniqueWords(text) {
+		spam, ham := b.spam[word], b.ham[word]
+		if spam+ham == 0 {
+			continue
+		}
+		// Laplace smoothing keeps words seen only on one side below 1
+		inSpam := float64(spam+1) / float64(b.spamTexts+2)
+		inHam := float64(ham+1) / float64(b.hamTexts+2)
+		probabilities = append(probabilities, inSpam/(inSpam+inHam))
+	}
+	sort.Slice(probabilities, func(i, j int) bool {
+		return math.Abs(probabilities[i]-0.5) > math.Abs(probabilities[j]-0.5)
+	})
+	if len(probabilities) > interestingWords {
+		probabilities = probabilities[:interestingWords]
+	}
+
+	logOdds := 0.0
+	for _, p := range probabilities {
+		logOdds += math.Log(p) - math.Log(1-p)
+	}
+	return 1 / (1 + math.Exp(-logOdds))
+}
+
+func uniqueWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range search.Tokenize(text) {
+		words[word] = true
+	}
+	return words
+}
@@ -0,0 +1,64 @@
+package spam
+
+import (
+	"echo-blog/lib/search"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// texts the Bayes scorer rates at least this likely are spam
+const spamThreshold = 0.9
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// HeuristicChecker flags texts with more than MaxLinks links, texts using
+// one of the banned words or phrases, and texts the Bayes scorer trained on
+// moderator decisions finds likely to be spam.
+type HeuristicChecker struct {
+	MaxLinks int
+	Bayes    *Bayes
+
+	banned []string
+}
+
+func NewHeuristicChecker(bannedWords []string, maxLinks int) *HeuristicChecker {
+	checker := &HeuristicChecker{MaxLinks: maxLinks, Bayes: NewBayes()}
+	for _, word := range bannedWords {
+		if phrase := strings.Join(search.Tokenize(word), " "); phrase != "" {
+			checker.banned = append(checker.banned, phrase)
+		}
+	}
+	return checker
+}
+
+func (h *HeuristicChecker) Check(text string) Verdict {
+	verdict := Verdict{Score: h.Bayes.Score(text)}
+
+	if links := len(linkPattern.FindAllStringIndex(text, -1)); links > h.MaxLinks {
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("%d links", links))
+	}
+
+	// match whole words, so a banned "ass" does not catch "class"
+	words := " " + strings.Join(search.Tokenize(text), " ") + " "
+	for _, phrase := range h.banned {
+		if strings.Contains(words, " "+phrase+" ") {
+			verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("banned word %q", phrase))
+		}
+	}
+
+	if verdict.Score >= spamThreshold {
+		verdict.Reasons = append(verdict.Reasons, fmt.Sprintf("spam score %.2f", verdict.Score))
+	}
+
+	verdict.Spam = len(verdict.Reasons) > 0
+	return verdict
+}
+
+func (h *HeuristicChecker) Learn(text string, spam bool) {
+	h.Bayes.Learn(text, spam)
+}
+
+func (h *HeuristicChecker) Unlearn(text string, spam bool) {
+	h.Bayes.Unlearn(text, spam)
+}
@@ -0,0 +1,66 @@
+package spam
+
+import (
+	"echo-blog/config"
+	"echo-blog/models"
+)
+
+// Verdict is the outcome of checking a text. Score is the spam probability
+// estimated from what was learned so far, 0.5 while there is not enough to
+// tell. Reasons says why the text was considered spam.
+type Verdict struct {
+	Spam    bool
+	Score   float64
+	Reasons []string
+}
+
+// SpamChecker decides whether a comment is spam. Learn feeds it the
+// decisions of moderators, Unlearn takes one back when a moderator changes
+// their mind.
+type SpamChecker interface {
+	Check(text string) Verdict
+	Learn(text string, spam bool)
+	Unlearn(text string, spam bool)
+}
+
+// Checker is the spam checker used by the API, set up by Setup. Without one
+// no comment is considered spam.
+var Checker SpamChecker
+
+// Setup installs a HeuristicChecker and trains it with the comments
+// moderators already marked as spam or approved.
+func Setup(bannedWords []string, maxLinks int) error {
+	checker := NewHeuristicChecker(bannedWords, maxLinks)
+
+	var comments []models.Comment
+	if err := config.DB.Unscoped().Select("body", "status").
+		Where("moderated_by IS NOT NULL AND status IN ?", []string{models.CommentStatusSpam, models.CommentStatusApproved}).
+		Find(&comments).Error; err != nil {
+		return err
+	}
+	for _, comment := range comments {
+		checker.Learn(comment.Body, comment.Status == models.CommentStatusSpam)
+	}
+
+	Checker = checker
+	return nil
+}
+
+func Check(text string) Verdict {
+	if Checker == nil {
+		return Verdict{Score: 0.5}
+	}
+	return Checker.Check(text)
+}
+
+func Learn(text string, spam bool) {
+	if Checker != nil {
+		Checker.Learn(text, spam)
+	}
+}
+
+func Unlearn(text string, spam bool) {
+	if Checker != nil {
+		Checker.Unlearn(text, spam)
+	}
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDir is where LocalStorage keeps files when no directory is given.
+const DefaultDir = "uploads"
+
+// LocalStorage keeps files in a directory, a key is the path of a file
+// below it.
+type LocalStorage struct {
+	Dir string
+}
+
+func NewLocalStorage(dir string) *LocalStorage {
+	if dir == "" {
+		dir = DefaultDir
+	}
+	return &LocalStorage{Dir: dir}
+}
+
+// Put writes to a temporary file first, so a failed upload never leaves a
+// partial file behind the key.
+func (s *LocalStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+// Open returns an *os.File, which can seek.
+func (s *LocalStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (s *LocalStorage) Delete(ctx context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps a key to a file, refusing keys that would leave the directory.
+func (s *LocalStorage) path(key string) (string, error) {
+	if key == "" || path.IsAbs(key) || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,201 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// hex encoded sha256 of an empty body
+	emptyPayload = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// S3Config describes a bucket of Amazon S3 or of a compatible server like
+// MinIO. Endpoint is the base URL of the server, the bucket is addressed in
+// the path so any host name works.
+type S3Config struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+}
+
+// S3Storage keeps files as objects of a bucket, signing its requests with
+// AWS signature version 4.
+type S3Storage struct {
+	config S3Config
+	client *http.Client
+}
+
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("s3 storage needs an endpoint and a bucket")
+	}
+	if _, err := url.Parse(config.Endpoint); err != nil {
+		return nil, err
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &S3Storage{config: config, client: &http.Client{Timeout: time.Minute}}, nil
+}
+
+// Put streams the file without hashing it first, which S3 allows by
+// signing the payload as UNSIGNED-PAYLOAD.
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := s.request(ctx, http.MethodPut, key, r, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", contentType)
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return s3Error(res, key)
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.request(ctx, http.MethodGet, key, nil, emptyPayload)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s3Error(res, key); err != nil {
+		res.Body.Close()
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := s.request(ctx, http.MethodDelete, key, nil, emptyPayload)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if err := s3Error(res, key); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (s *S3Storage) request(ctx context.Context, method string, key string, body io.Reader, payloadHash string) (*http.Request, error) {
+	if key == "" {
+		return nil, ErrInvalidKey
+	}
+	path := "/" + s3Escape(s.config.Bucket) + "/" + s3Escape(key)
+	req, err := http.NewRequestWithContext(ctx, method, s.config.Endpoint+path, body)
+	if err != nil {
+		return nil, err
+	}
+	s.sign(req, path, payloadHash, time.Now().UTC())
+	return req, nil
+}
+
+// sign adds the Authorization header of AWS signature version 4, see
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *S3Storage) sign(req *http.Request, path string, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{
+		"host":                 req.URL.Host,
+		"x-amz-content-sha256": payloadHash,
+		"x-amz-date":           amzDate,
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hashHex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+func s3Error(res *http.Response, key string) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	if res.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s %s", res.Request.Method, key, res.Status, strings.TrimSpace(string(message)))
+}
+
+// s3Escape percent encodes everything but unreserved characters and
+// slashes, as S3 expects in canonical paths.
+func s3Escape(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,67 @@
+// Package storage keeps uploaded files, on the local disk or in an S3
+// compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrNotFound      = errors.New("file not found")
+	ErrNotConfigured = errors.New("storage is not configured")
+	ErrInvalidKey    = errors.New("invalid file key")
+)
+
+// Storage saves files under a key like "2024/05/3f2a9c.png". Open returns
+// ErrNotFound for keys that were never put or have been deleted, deleting
+// such a key is not an error.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Store is the storage used by the API, set up by Setup.
+var Store Storage
+
+// Setup picks the storage: "local" keeps files in dir, "s3" in the bucket
+// described by s3. Without a driver local is used.
+func Setup(driver string, dir string, s3 S3Config) error {
+	switch driver {
+	case "", "local":
+		Store = NewLocalStorage(dir)
+	case "s3":
+		store, err := NewS3Storage(s3)
+		if err != nil {
+			return err
+		}
+		Store = store
+	default:
+		return fmt.Errorf("unknown storage driver %q", driver)
+	}
+	return nil
+}
+
+func Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if Store == nil {
+		return ErrNotConfigured
+	}
+	return Store.Put(ctx, key, r, size, contentType)
+}
+
+func Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if Store == nil {
+		return nil, ErrNotConfigured
+	}
+	return Store.Open(ctx, key)
+}
+
+func Delete(ctx context.Context, key string) error {
+	if Store == nil {
+		return ErrNotConfigured
+	}
+	return Store.Delete(ctx, key)
+}
@@ -0,0 +1,270 @@
+// Package validation binds request bodies and checks them against the
+// validate tags of the struct they are bound to. Every rejected field is
+// reported at once as a models.ValidationErrors.
+package validation
+
+import (
+	"context"
+	"echo-blog/helper"
+	"echo-blog/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	slugMaxLength     = 180
+	passwordMinLength = 8
+)
+
+type (
+	exceptKey  struct{}
+	checkerKey struct{}
+)
+
+// EmailChecker tells whether a user other than exceptId uses email, the
+// unique_email rule asks it.
+type EmailChecker interface {
+	EmailTaken(email string, exceptId uint) (bool, error)
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	// report fields by their json name, the one clients send
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			return field.Name
+		}
+		return name
+	})
+	v.RegisterValidation("slug", validSlug)
+	v.RegisterValidation("password", validPassword)
+	v.RegisterValidationCtx("unique_email", uniqueEmail)
+	return v
+}
+
+// Option gives the rules of Bind and BindChanges what they look up.
+type Option func(ctx context.Context) context.Context
+
+// WithEmailChecker makes the unique_email rule ask checker. Without one the
+// rule rejects every email, a request type with the rule needs it.
+func WithEmailChecker(checker EmailChecker) Option {
+	return func(ctx context.Context) context.Context {
+		return context.WithValue(ctx, checkerKey{}, checker)
+	}
+}
+
+// Bind reads the body of a create request into dest and checks every field
+// of it.
+func Bind(c echo.Context, dest interface{}, opts ...Option) error {
+	if err := decode(c, dest); err != nil {
+		return err
+	}
+	return convert(validate.StructCtx(withOptions(c, opts), dest))
+}
+
+// BindChanges reads the body of an update request into dest. Only the
+// fields that were sent are checked, the others are left unchanged by the
+// update. id is the record being changed, unique rules ignore it.
+func BindChanges(c echo.Context, dest interface{}, id uint, opts ...Option) error {
+	if err := decode(c, dest); err != nil {
+		return err
+	}
+
+	fields := sentFields(dest)
+	if len(fields) == 0 {
+		return nil
+	}
+	ctx := context.WithValue(withOptions(c, opts), exceptKey{}, id)
+	return convert(validate.StructPartialCtx(ctx, dest, fields...))
+}
+
+func withOptions(c echo.Context, opts []Option) context.Context {
+	ctx := c.Request().Context()
+	for _, opt := range opts {
+		ctx = opt(ctx)
+	}
+	return ctx
+}
+
+// decode reads JSON bodies strictly, unknown fields and values of the wrong
+// type are rejected instead of silently dropped. Other bodies go through
+// the usual echo binder.
+func decode(c echo.Context, dest interface{}) error {
+	req := c.Request()
+	if !strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		if err := c.Bind(dest); err != nil {
+			return models.ValidationErrors{{Code: "invalid_body", Message: "request body could not be read"}}
+		}
+		return nil
+	}
+	if req.Body == nil {
+		return nil
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(dest)
+	if err == nil || errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return models.ValidationErrors{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: fmt.Sprintf("%s must be %s", typeErr.Field, typeName(typeErr.Type)),
+		}}
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, _ = strconv.Unquote(field)
+		return models.ValidationErrors{{Field: field, Code: "unknown_field", Message: field + " is not a known field"}}
+	}
+	return models.ValidationErrors{{Code: "invalid_body", Message: "request body must be valid JSON"}}
+}
+
+// sentFields lists the top level fields of dest that are set.
+func sentFields(dest interface{}) []string {
+	value := reflect.Indirect(reflect.ValueOf(dest))
+	var fields []string
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.IsExported() && !field.Anonymous && !value.Field(i).IsZero() {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+func convert(err error) error {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+
+	errs := make(models.ValidationErrors, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		// the namespace starts with the struct name, leave it out
+		_, field, _ := strings.Cut(fieldErr.Namespace(), ".")
+		errs = append(errs, models.FieldError{
+			Field:   field,
+			Code:    code(fieldErr.Tag()),
+			Message: message(field, fieldErr),
+		})
+	}
+	return errs
+}
+
+// Taken is the error of a field whose value another record already holds,
+// for the uniqueness the database finds violated after the checks passed.
+func Taken(field string) error {
+	return models.ValidationErrors{{Field: field, Code: "taken", Message: field + " is already taken"}}
+}
+
+func code(tag string) string {
+	if tag == "unique_email" {
+		return "taken"
+	}
+	return tag
+}
+
+func message(field string, err validator.FieldError) string {
+	param := err.Param()
+	switch err.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "min", "max":
+		bound := "at least"
+		if err.Tag() == "max" {
+			bound = "at most"
+		}
+		switch err.Kind() {
+		case reflect.String:
+			return fmt.Sprintf("%s must be %s %s characters", field, bound, param)
+		case reflect.Slice:
+			return fmt.Sprintf("%s must list %s %s items", field, bound, param)
+		}
+		return fmt.Sprintf("%s must be %s %s", field, bound, param)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, list(strings.Fields(param)))
+	case "slug":
+		return fmt.Sprintf("%s must contain letters or digits and be at most %d characters", field, slugMaxLength)
+	case "password":
+		return fmt.Sprintf("%s must be at least %d characters and contain a letter and a digit", field, passwordMinLength)
+	case "unique_email":
+		return field + " is already taken"
+	}
+	return field + " is invalid"
+}
+
+// list joins values like "a, b or c".
+func list(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	}
+	return "an object"
+}
+
+// validSlug accepts anything that still makes a slug once normalized, the
+// database layer does the normalizing.
+func validSlug(fl validator.FieldLevel) bool {
+	slug := helper.MakeSlug(fl.Field().String())
+	return slug != "" && len(slug) <= slugMaxLength
+}
+
+func validPassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len(password) < passwordMinLength {
+		return false
+	}
+
+	var letter, digit bool
+	for _, r := range password {
+		letter = letter || unicode.IsLetter(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return letter && digit
+}
+
+// uniqueEmail lets a failing lookup pass, the unique index of users
+// rejects the email on save. Without a checker it fails closed.
+func uniqueEmail(ctx context.Context, fl validator.FieldLevel) bool {
+	checker, ok := ctx.Value(checkerKey{}).(EmailChecker)
+	if !ok {
+		return false
+	}
+	except, _ := ctx.Value(exceptKey{}).(uint)
+	taken, err := checker.EmailTaken(fl.Field().String(), except)
+	return err != nil || !taken
+}
@@ -1,23 +1,144 @@
 package main
 
 import (
+	"context"
 	"echo-blog/config"
+	"echo-blog/controllers"
+	"echo-blog/helper"
+	"echo-blog/lib/migration"
+	"echo-blog/lib/scheduler"
+	"echo-blog/lib/search"
+	"echo-blog/lib/spam"
+	"echo-blog/lib/storage"
+	"echo-blog/middlewares"
 	"echo-blog/routes"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 func init() {
-	err := godotenv.Load(".env")
-
-	if err != nil {
-		log.Fatalf("Error loading .env file")
+	// .env is optional, the settings can come from the environment or a config file as well
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatalf("Error loading .env file: %v", err)
 	}
 }
 
+const usage = "usage: go run main.go [flags] [migrate ... | config]"
+
 func main() {
-	config.InitDB()
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Error loading the configuration!\n%v", err)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			migrate(cfg, args[1:])
+		case "config":
+			fmt.Print(cfg)
+		default:
+			log.Fatal(usage)
+		}
+		return
+	}
+
+	middlewares.SetJWTSecret(cfg.JWTSecret)
+	config.InitDB(cfg)
+
+	if err := search.Setup(cfg.Search.Driver); err != nil {
+		log.Fatalf("Error initializing search: %v", err)
+	}
+
+	if err := spam.Setup(cfg.Spam.BannedWords, cfg.Spam.MaxLinks); err != nil {
+		log.Fatalf("Error initializing spam checker: %v", err)
+	}
+
+	s3 := storage.S3Config{
+		Endpoint:  cfg.Storage.S3.Endpoint,
+		Region:    cfg.Storage.S3.Region,
+		Bucket:    cfg.Storage.S3.Bucket,
+		AccessKey: cfg.Storage.S3.AccessKey,
+		SecretKey: cfg.Storage.S3.SecretKey,
+	}
+	if err := storage.Setup(cfg.Storage.Driver, cfg.Storage.MediaDir, s3); err != nil {
+		log.Fatalf("Error initializing storage: %v", err)
+	}
+
+	helper.SetSite(cfg.Site.URL, cfg.Site.Title, cfg.Site.BlogPath)
+	controllers.SetupMedia(cfg.Media)
+	controllers.SetupFeed(cfg.Feed)
+	controllers.SetupRobots(cfg.Robots)
+
+	scheduler.Start(context.Background(), cfg.Scheduler.Interval)
+
+	// TRASH_RETENTION_DAYS=0 keeps trashed blogs and users until they are purged by hand
+	if days := cfg.Scheduler.TrashRetentionDays; days > 0 {
+		scheduler.StartTrashPurge(context.Background(), time.Duration(days)*24*time.Hour)
+	}
+
 	e := routes.New()
-	e.Logger.Fatal(e.Start(":3000"))
+	e.Server.ReadTimeout = cfg.Server.ReadTimeout
+	e.Server.WriteTimeout = cfg.Server.WriteTimeout
+	e.Server.IdleTimeout = cfg.Server.IdleTimeout
+	e.Logger.Fatal(e.Start(fmt.Sprintf(":%d", cfg.Server.Port)))
+}
+
+const migrateUsage = "usage: go run main.go migrate [up | down [steps] | to <version> | status]"
+
+// migrate runs the migrate command, it changes the schema and nothing else.
+func migrate(cfg *config.Config, args []string) {
+	config.Connect(cfg.Database)
+	migrator := migration.New(config.DB, config.Migrations)
+
+	if len(args) == 0 {
+		log.Fatal(migrateUsage)
+	}
+	var err error
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		err = migrator.Down(steps)
+	case "to":
+		if len(args) < 2 {
+			log.Fatal(migrateUsage)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			log.Fatal(migrateUsage)
+		}
+		err = migrator.To(version)
+	case "status":
+		var states []migration.State
+		if states, err = migrator.Status(); err == nil {
+			for _, state := range states {
+				name, applied := state.Name, "pending"
+				if name == "" {
+					name = "(unknown to this build)"
+				}
+				if state.Applied() {
+					applied = "applied " + state.AppliedAt.Format(time.RFC3339)
+				}
+				fmt.Printf("%4d  %-30s %s\n", state.Version, name, applied)
+			}
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatalf("Error migrating the database! %v", err)
+	}
 }
@@ -0,0 +1,30 @@
+package middlewares
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/helper"
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ErrorHandler writes the errors returned by handlers and middlewares in
+// the same shape as every other response. Internal errors are logged, the
+// client only sees that something went wrong.
+func ErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	appErr := apperror.From(err)
+	if appErr.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s failed, error : %v\n", c.Request().Method, c.Request().URL.Path, err)
+	}
+
+	if c.Request().Method == http.MethodHead {
+		c.NoContent(appErr.Status)
+		return
+	}
+	helper.WrapError(appErr).WriteToResponseBody(c.Response())
+}
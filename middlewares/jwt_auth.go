@@ -1,11 +1,9 @@
 package middlewares
 
 import (
-	"echo-blog/helper"
-	"echo-blog/models"
+	"echo-blog/apperror"
 	"errors"
-	"net/http"
-	"os"
+	"fmt"
 	"strings"
 	"time"
 
@@ -13,58 +11,127 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	AccessTokenTTL  = time.Minute * 15
+	RefreshTokenTTL = time.Hour * 24 * 7
+)
+
+// jwtSecret signs and checks the tokens, see SetJWTSecret.
+var jwtSecret []byte
+
+// SetJWTSecret sets the key tokens are signed with, Config.JWTSecret. It is
+// called once on startup, before any token is issued.
+func SetJWTSecret(secret string) {
+	jwtSecret = []byte(secret)
+}
+
 type MyCustomClaims struct {
-	UserId int `json:"userId"`
+	UserId int    `json:"userId"`
+	Role   string `json:"role"`
 	jwt.StandardClaims
 }
 
-func CreateToken(userId int) (string, error) {
+// CreateToken issues a short-lived access token bound to a login session.
+func CreateToken(userId int, role string, sessionId string) (string, error) {
 	claims := MyCustomClaims{
 		userId,
+		role,
 		jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Hour * 1).Unix(), //Token expires after 1 hour
+			Id:        sessionId,
+			ExpiresAt: time.Now().Add(AccessTokenTTL).Unix(),
 		},
 	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	return token.SignedString(jwtSecret)
+}
+
+// Sessions tells whether the login session of a token was ended, see
+// service.SessionRepository.
+type Sessions interface {
+	IsRevoked(sessionId string) (bool, error)
 }
 
-func validateToken(encodedToken string) (int, error) {
-	signatureKey := []byte(os.Getenv("JWT_SECRET"))
+// errTokenInvalid is wrapped by every error of validateToken that means the
+// token must not be accepted, any other error is a failure of the server.
+var errTokenInvalid = errors.New("token invalid")
+
+func validateToken(encodedToken string, sessions Sessions) (*MyCustomClaims, error) {
 	token, err := jwt.ParseWithClaims(encodedToken, &MyCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return signatureKey, nil
+		return jwtSecret, nil
 	})
 
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("%w: %v", errTokenInvalid, err)
 	}
 
 	claims, ok := token.Claims.(*MyCustomClaims)
-	if ok && token.Valid {
-		return claims.UserId, nil
-	} else {
-		return 0, errors.New("token invalid")
+	if !ok || !token.Valid || claims.UserId == 0 {
+		return nil, errTokenInvalid
 	}
-
+	revoked, err := sessions.IsRevoked(claims.Id)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, fmt.Errorf("%w: token revoked", errTokenInvalid)
+	}
+	return claims, nil
 }
 
-func UserAuthMiddlewares() func(next echo.HandlerFunc) echo.HandlerFunc {
+// UserAuthMiddlewares rejects requests without a valid token of a session
+// that was not ended.
+func UserAuthMiddlewares(sessions Sessions) func(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 
 			authHeader := c.Request().Header.Get("Authorization")
 			if authHeader == "" {
-				return helper.WrapResponse(http.StatusUnauthorized, "You are not Authorized!", &models.User{}).WriteToResponseBody(c.Response())
+				return apperror.Unauthorized("You are not Authorized!")
 			}
 
-			token := strings.Split(authHeader, " ")[1]
-			userId, e := validateToken(token)
-			if userId == 0 || e != nil {
-				return helper.WrapResponse(http.StatusUnauthorized, "You are not Authorized!", &models.User{}).WriteToResponseBody(c.Response())
+			claims, e := validateToken(bearerToken(authHeader), sessions)
+			if errors.Is(e, errTokenInvalid) {
+				return apperror.Unauthorized("You are not Authorized!")
+			}
+			if e != nil {
+				return apperror.Internal(e)
 			}
 
-			c.Set("userId", userId)
+			setClaims(c, claims)
 			return next(c)
 		}
 	}
 }
+
+// OptionalUserAuthMiddlewares identifies the user when a valid token is sent
+// but lets anonymous requests through, for routes whose output depends on
+// who is asking.
+func OptionalUserAuthMiddlewares(sessions Sessions) func(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+
+			authHeader := c.Request().Header.Get("Authorization")
+			if authHeader != "" {
+				claims, e := validateToken(bearerToken(authHeader), sessions)
+				if e != nil && !errors.Is(e, errTokenInvalid) {
+					return apperror.Internal(e)
+				}
+				if e == nil {
+					setClaims(c, claims)
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+func bearerToken(authHeader string) string {
+	_, token, _ := strings.Cut(authHeader, " ")
+	return token
+}
+
+func setClaims(c echo.Context, claims *MyCustomClaims) {
+	c.Set("userId", claims.UserId)
+	c.Set("role", claims.Role)
+	c.Set("sessionId", claims.Id)
+}
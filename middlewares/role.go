@@ -0,0 +1,23 @@
+package middlewares
+
+import (
+	"echo-blog/apperror"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RoleAuthMiddlewares only lets through users whose role is one of roles.
+// It must run after UserAuthMiddlewares, which puts the role in the context.
+func RoleAuthMiddlewares(roles ...string) func(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role, _ := c.Get("role").(string)
+			for _, allowed := range roles {
+				if role == allowed {
+					return next(c)
+				}
+			}
+			return apperror.Forbidden("You are not allowed to access this resource!")
+		}
+	}
+}
@@ -1,27 +1,96 @@
 package models
 
 import (
-	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+const (
+	ContentFormatMarkdown = "markdown"
+	ContentFormatHTML     = "html"
+	ContentFormatPlain    = "plain"
+)
+
+const (
+	BlogStatusDraft     = "draft"
+	BlogStatusScheduled = "scheduled"
+	BlogStatusPublished = "published"
+	BlogStatusArchived  = "archived"
+)
+
 type Blog struct {
 	gorm.Model
-	Title string `json:"title" form:"title"`
-	Body  string `json:"body" form:"body"`
-	Slug  string `json:"slug" form:"slug"`
+	Title       string     `json:"title" form:"title" validate:"required,max=200"`
+	Body        string     `json:"body" form:"body" validate:"required"`
+	Slug        string     `json:"slug" form:"slug" gorm:"size:191;uniqueIndex" validate:"omitempty,slug"`
+	Status      string     `json:"status" form:"status" gorm:"size:20;default:draft;index"`
+	PublishedAt *time.Time `json:"publishedAt" form:"publishedAt"`
+	ScheduledAt *time.Time `json:"scheduledAt" form:"scheduledAt" gorm:"index"`
+	UserID      uint       `json:"userId" form:"userId"`
+	Author      *Author    `json:"author,omitempty" gorm:"foreignKey:UserID"`
+	CategoryID  *uint      `json:"categoryId" form:"categoryId" gorm:"index"`
+	Category    *Category  `json:"category,omitempty"`
+	Tags        []Tag      `json:"tags" gorm:"many2many:blog_tags"`
+
+	// ContentFormat says how Body is written, BodyHTML and TOC are rendered
+	// from it whenever the blog is saved.
+	ContentFormat string     `json:"contentFormat" form:"contentFormat" gorm:"size:20;default:markdown" validate:"omitempty,oneof=markdown html plain"`
+	BodyHTML      string     `json:"bodyHtml"`
+	TOC           []TOCEntry `json:"toc" gorm:"type:text;serializer:json"`
+
+	// FeaturedMedia is the image shown with the blog in lists and previews.
+	FeaturedMediaID *uint  `json:"featuredMediaId" form:"featuredMediaId" gorm:"index"`
+	FeaturedMedia   *Media `json:"featuredMedia,omitempty"`
+
+	// AutoApproveComments publishes comments that pass the spam checker
+	// without waiting for a moderator.
+	AutoApproveComments *bool `json:"autoApproveComments" form:"autoApproveComments" gorm:"default:false"`
 }
 
-func (blog *Blog) ValidatorSanitizer() error {
-	if blog.Title == "" {
-		return fmt.Errorf("title is required")
-	}
-	if blog.Body == "" {
-		return fmt.Errorf("body is required")
+// TOCEntry is a heading of a rendered blog, ID is its anchor in BodyHTML.
+type TOCEntry struct {
+	Level int    `json:"level"`
+	ID    string `json:"id"`
+	Text  string `json:"text"`
+}
+
+// BlogSlugRedirect keeps a slug a blog used before, so old links keep
+// working after its title or slug changes.
+type BlogSlugRedirect struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+	Slug      string    `json:"slug" gorm:"size:191;uniqueIndex"`
+	BlogID    uint      `json:"blogId" gorm:"index"`
+}
+
+// BlogSchedule is the request body to publish a blog at a later time.
+type BlogSchedule struct {
+	PublishAt time.Time `json:"publishAt" form:"publishAt" validate:"required"`
+}
+
+// Author is the public part of a User embedded in blog responses.
+type Author struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+func (Author) TableName() string {
+	return "users"
+}
+
+func IsValidBlogStatus(status string) bool {
+	switch status {
+	case BlogStatusDraft, BlogStatusScheduled, BlogStatusPublished, BlogStatusArchived:
+		return true
 	}
-	if blog.Slug == "" {
-		return fmt.Errorf("slug is required")
+	return false
+}
+
+func IsValidContentFormat(format string) bool {
+	switch format {
+	case ContentFormatMarkdown, ContentFormatHTML, ContentFormatPlain:
+		return true
 	}
-	return nil
+	return false
 }
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	CommentFormatTree = "tree"
+	CommentFormatFlat = "flat"
+)
+
+const (
+	CommentStatusPending  = "pending"
+	CommentStatusApproved = "approved"
+	CommentStatusRejected = "rejected"
+	CommentStatusSpam     = "spam"
+)
+
+// Comment is a reader's response to a blog, ParentID points at the comment
+// it replies to. Only approved comments are public. ModeratedBy is set when
+// a moderator decided the status, SpamReason when the spam checker flagged
+// it. Depth and Replies are filled in when comments are listed: Depth
+// counts the parents of the comment, Replies nests the answers in the tree
+// format.
+type Comment struct {
+	gorm.Model
+	BlogID      uint       `json:"blogId" form:"-" gorm:"index"`
+	ParentID    *uint      `json:"parentId" form:"parentId" gorm:"index"`
+	Body        string     `json:"body" form:"body" validate:"required,max=5000"`
+	UserID      uint       `json:"userId" form:"-"`
+	Author      *Author    `json:"author,omitempty" form:"-" gorm:"foreignKey:UserID"`
+	Status      string     `json:"status" form:"-" gorm:"size:20;default:pending;index"`
+	SpamReason  string     `json:"spamReason,omitempty" form:"-" gorm:"size:255"`
+	ModeratedBy *uint      `json:"moderatedBy,omitempty" form:"-"`
+	ModeratedAt *time.Time `json:"moderatedAt,omitempty" form:"-"`
+	Depth       int        `json:"depth" form:"-" gorm:"-"`
+	Replies     []Comment  `json:"replies,omitempty" form:"-" gorm:"-"`
+}
+
+// CommentModeration is the request body to decide the status of several
+// comments at once.
+type CommentModeration struct {
+	IDs    []uint `json:"ids" form:"ids"`
+	Status string `json:"status" form:"status" validate:"oneof=approved rejected spam"`
+}
+
+// IsModerationStatus reports whether status is a decision a moderator can
+// take, pending is not.
+func IsModerationStatus(status string) bool {
+	switch status {
+	case CommentStatusApproved, CommentStatusRejected, CommentStatusSpam:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MediaPath is where uploaded files are served, followed by their key.
+	MediaPath = "/media/"
+	// MediaThumbnail names the square thumbnail variant, the other
+	// variants are named by width like "640w".
+	MediaThumbnail = "thumbnail"
+)
+
+// Media is a file uploaded by a user, kept in the storage under Key. URL is
+// the path the file is served at. Images also have their size in pixels,
+// smaller variants and a srcset listing the original and its variants by
+// width.
+type Media struct {
+	ID          uint           `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UserID      uint           `json:"userId" gorm:"index"`
+	FileName    string         `json:"fileName"`
+	Key         string         `json:"key" gorm:"size:191;uniqueIndex"`
+	ContentType string         `json:"contentType" gorm:"size:100"`
+	Size        int64          `json:"size"`
+	Width       int            `json:"width,omitempty"`
+	Height      int            `json:"height,omitempty"`
+	Variants    []MediaVariant `json:"variants,omitempty"`
+	URL         string         `json:"url" gorm:"-"`
+	Srcset      string         `json:"srcset,omitempty" gorm:"-"`
+}
+
+// MediaVariant is a resized copy of an uploaded image, see lib/imaging.
+type MediaVariant struct {
+	ID          uint   `json:"id" gorm:"primarykey"`
+	MediaID     uint   `json:"-" gorm:"index"`
+	Name        string `json:"name" gorm:"size:20"`
+	Key         string `json:"key" gorm:"size:191;uniqueIndex"`
+	ContentType string `json:"contentType" gorm:"size:100"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url" gorm:"-"`
+}
+
+func (media *Media) AfterFind(tx *gorm.DB) error {
+	media.setURLs()
+	return nil
+}
+
+func (media *Media) AfterCreate(tx *gorm.DB) error {
+	media.setURLs()
+	return nil
+}
+
+func (media *Media) setURLs() {
+	media.URL = MediaPath + media.Key
+	if media.Width == 0 {
+		return
+	}
+
+	var srcset []string
+	for i := range media.Variants {
+		variant := &media.Variants[i]
+		variant.URL = MediaPath + variant.Key
+		if variant.Name != MediaThumbnail {
+			srcset = append(srcset, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+		}
+	}
+	media.Srcset = strings.Join(append(srcset, fmt.Sprintf("%s %dw", media.URL, media.Width)), ", ")
+}
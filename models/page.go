@@ -0,0 +1,98 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageLimit = 10
+	MaxPageLimit     = 100
+)
+
+// PageRequest says which page of a list to return and how the list is
+// sorted. After and Before switch from page numbers to keyset pagination on
+// (created_at, id), which stays fast and stable on big tables.
+type PageRequest struct {
+	Page   int
+	Limit  int
+	Sort   string
+	Desc   bool
+	After  *Cursor
+	Before *Cursor
+}
+
+func (page PageRequest) Keyset() bool {
+	return page.After != nil || page.Before != nil
+}
+
+func (page PageRequest) Offset() int {
+	return (page.Page - 1) * page.Limit
+}
+
+// PageMeta describes the returned page, Next and Prev are ready to follow
+// links and are empty on the last and first page.
+type PageMeta struct {
+	Total      int64  `json:"total"`
+	Page       int    `json:"page,omitempty"`
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+	Next       string `json:"next,omitempty"`
+	Prev       string `json:"prev,omitempty"`
+
+	HasNext bool `json:"-"`
+	HasPrev bool `json:"-"`
+}
+
+// Cursor points at a row in a list sorted by created_at then id.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+func (cursor Cursor) Encode() string {
+	raw := fmt.Sprintf("%d,%d", cursor.CreatedAt.UnixNano(), cursor.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, found := strings.Cut(string(raw), ",")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+	unixNano, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	rowId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &Cursor{CreatedAt: time.Unix(0, unixNano), ID: uint(rowId)}, nil
+}
+
+type BlogFilter struct {
+	Author   string
+	Status   string
+	Tag      string
+	Category string
+	From     *time.Time
+	To       *time.Time
+}
+
+type UserFilter struct {
+	Role string
+	From *time.Time
+	To   *time.Time
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// BlogRevision is an immutable snapshot of a blog's content, written every
+// time the content changes. Number counts the revisions of one blog from 1.
+type BlogRevision struct {
+	ID           uint      `json:"id" gorm:"primarykey"`
+	CreatedAt    time.Time `json:"createdAt"`
+	BlogID       uint      `json:"blogId" gorm:"uniqueIndex:idx_blog_revision_number"`
+	Number       int       `json:"number" gorm:"uniqueIndex:idx_blog_revision_number"`
+	Title        string    `json:"title"`
+	Body         string    `json:"body,omitempty"`
+	Slug         string    `json:"slug"`
+	RestoredFrom *int      `json:"restoredFrom,omitempty"`
+	UserID       uint      `json:"userId"`
+	Author       *Author   `json:"author,omitempty" gorm:"foreignKey:UserID"`
+}
+
+type BlogRevisionDiff struct {
+	BlogID uint       `json:"blogId"`
+	From   int        `json:"from"`
+	To     int        `json:"to"`
+	Lines  []DiffLine `json:"lines"`
+}
+
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Tag labels blogs by topic. Tags are matched by slug, so "Go" and "go" are
+// the same tag. Count is only filled in when listing tags and holds the
+// number of published blogs using the tag.
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Name      string    `json:"name" form:"name" gorm:"size:100" validate:"required,max=100"`
+	Slug      string    `json:"slug" form:"slug" gorm:"size:191;uniqueIndex"`
+	Count     int64     `json:"count,omitempty" form:"-" gorm:"->;-:migration"`
+}
+
+// Category sorts blogs into a tree of sections, a blog belongs to at most
+// one category. Children is only filled in when categories are listed as a
+// tree.
+type Category struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+	Name        string     `json:"name" form:"name" gorm:"size:100" validate:"required,max=100"`
+	Slug        string     `json:"slug" form:"slug" gorm:"size:191;uniqueIndex"`
+	Description string     `json:"description" form:"description" validate:"max=1000"`
+	ParentID    *uint      `json:"parentId" form:"parentId" gorm:"index"`
+	Children    []Category `json:"children,omitempty" form:"-" gorm:"-"`
+}
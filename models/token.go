@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is one link in the rotation chain of a login session. Every
+// token issued from the same login shares a SessionID, and revoking a
+// session revokes all of its tokens at once.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint       `json:"userId"`
+	SessionID string     `json:"sessionId" gorm:"size:32;index"`
+	TokenHash string     `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+}
+
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+}
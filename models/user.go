@@ -1,28 +1,21 @@
 package models
 
-import (
-	"fmt"
+import "gorm.io/gorm"
 
-	"gorm.io/gorm"
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
 )
 
 type User struct {
 	gorm.Model
 	Username string `json:"username" form:"username"`
 	Email    string `json:"email" form:"email"`
-	Password string `json:"password" form:"password"`
-	Token    string `json:"token" form:"token"`
+	Password string `json:"-" form:"-"`
+	Role     string `json:"role" form:"role" gorm:"size:20;default:author"`
 }
 
-func (user *User) ValidatorSanitizer() error {
-	if user.Username == "" {
-		return fmt.Errorf("username is required")
-	}
-	if user.Email == "" {
-		return fmt.Errorf("email is required")
-	}
-	if user.Password == "" {
-		return fmt.Errorf("password is required")
-	}
-	return nil
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleEditor || role == RoleAuthor
 }
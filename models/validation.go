@@ -0,0 +1,19 @@
+package models
+
+// FieldError says why one field of a request was rejected. Code is meant
+// for programs, Message for people.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors lists every rejected field of a request.
+type ValidationErrors []FieldError
+
+func (errs ValidationErrors) Error() string {
+	if len(errs) == 0 {
+		return "invalid request"
+	}
+	return errs[0].Message
+}
@@ -9,6 +9,10 @@ type WebResponse struct {
 	Code   int         `json:"code"`
 	Status string      `json:"status"`
 	Data   interface{} `json:"data,omitempty"`
+	Meta   *PageMeta   `json:"meta,omitempty"`
+	// ErrorCode and Errors are only set on failures, see apperror.
+	ErrorCode string       `json:"errorCode,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
 }
 
 func (resp *WebResponse) WriteToResponseBody(w http.ResponseWriter) error {
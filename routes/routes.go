@@ -1,38 +1,128 @@
 package routes
 
 import (
+	"echo-blog/apperror"
+	"echo-blog/config"
 	"echo-blog/controllers"
+	"echo-blog/lib/database"
+	"echo-blog/lib/search"
 	"echo-blog/middlewares"
+	"echo-blog/models"
+	"echo-blog/service"
 	"net/http"
 
 	"github.com/labstack/echo/v4"
 )
 
+// New builds the app, its handlers work on the database config.DB.
 func New() *echo.Echo {
+	blogService := service.NewBlogService(database.NewGormBlogRepository(config.DB), search.Reindex)
+	blogs := controllers.NewBlogHandler(blogService)
+	revisions := controllers.NewRevisionHandler(blogService)
+	comments := controllers.NewCommentHandler(blogService)
+	sessions := database.NewGormSessionRepository(config.DB)
+	users := controllers.NewUserHandler(service.NewUserService(database.NewGormUserRepository(config.DB), sessions))
+
 	e := echo.New()
+	e.HTTPErrorHandler = middlewares.ErrorHandler
 
 	e.GET("/", defaultHandler)
 	middlewares.LogMiddlewares(e)
 
 	v1 := e.Group("/api/v1")
-	v1Auth := e.Group("/api/v1", middlewares.UserAuthMiddlewares())
+	v1Auth := e.Group("/api/v1", middlewares.UserAuthMiddlewares(sessions))
 
 	//user login
-	v1.POST("/login", controllers.LoginUser)
+	v1.POST("/login", users.LoginUser)
+	v1.POST("/token/refresh", users.RefreshToken)
+	v1Auth.POST("/logout", users.LogoutUser)
 
 	//api Blog
-	v1.GET("/blogs", controllers.GetAllBlogs)
-	v1.GET("/blogs/:id", controllers.GetBlogByID)
-	v1Auth.POST("/blogs", controllers.AddNewBlog)
-	v1Auth.PUT("/blogs/:id", controllers.UpdateBlog)
-	v1Auth.DELETE("/blogs/:id", controllers.DeleteBlog)
+	optionalAuth := middlewares.OptionalUserAuthMiddlewares(sessions)
+	v1.GET("/blogs", blogs.GetAllBlogs, optionalAuth)
+	v1.GET("/blogs/:id", blogs.GetBlogByID, optionalAuth)
+	v1.GET("/blogs/slug/:slug", blogs.GetBlogBySlug, optionalAuth)
+	v1.GET("/blogs/search", controllers.SearchBlogs)
+	v1Auth.POST("/blogs", blogs.AddNewBlog)
+	v1Auth.PUT("/blogs/:id", blogs.UpdateBlog)
+	v1Auth.DELETE("/blogs/:id", blogs.DeleteBlog)
+	v1Auth.POST("/blogs/:id/publish", blogs.PublishBlog)
+	v1Auth.POST("/blogs/:id/unpublish", blogs.UnpublishBlog)
+	v1Auth.POST("/blogs/:id/archive", blogs.ArchiveBlog)
+	v1Auth.POST("/blogs/:id/schedule", blogs.ScheduleBlog)
+	v1Auth.GET("/blogs/scheduled", blogs.GetScheduledBlogs)
+
+	//api Blog revision
+	v1Auth.GET("/blogs/:id/revisions", revisions.GetBlogRevisions)
+	v1Auth.GET("/blogs/:id/revisions/diff", revisions.DiffBlogRevisions)
+	v1Auth.GET("/blogs/:id/revisions/:revision", revisions.GetBlogRevision)
+	v1Auth.POST("/blogs/:id/revisions/:revision/restore", revisions.RestoreBlogRevision)
+
+	//api Comment
+	editorOnly := middlewares.RoleAuthMiddlewares(models.RoleAdmin, models.RoleEditor)
+	v1.GET("/blogs/:id/comments", comments.GetBlogComments, optionalAuth)
+	v1Auth.POST("/blogs/:id/comments", comments.AddNewComment)
+	v1Auth.PUT("/blogs/:id/comments/:comment", comments.UpdateComment)
+	v1Auth.DELETE("/blogs/:id/comments/:comment", comments.DeleteComment)
+	v1Auth.GET("/comments/moderation", comments.GetModerationQueue, editorOnly)
+	v1Auth.POST("/comments/moderation", comments.ModerateComments, editorOnly)
+
+	//api Tag and Category
+	v1.GET("/tags", controllers.GetAllTags)
+	v1.GET("/tags/cloud", controllers.GetTagCloud)
+	v1.GET("/tags/:id", controllers.GetTagByID)
+	v1Auth.POST("/tags", controllers.AddNewTag, editorOnly)
+	v1Auth.PUT("/tags/:id", controllers.UpdateTag, editorOnly)
+	v1Auth.DELETE("/tags/:id", controllers.DeleteTag, editorOnly)
+	v1.GET("/categories", controllers.GetAllCategories)
+	v1.GET("/categories/:id", controllers.GetCategoryByID)
+	v1Auth.POST("/categories", controllers.AddNewCategory, editorOnly)
+	v1Auth.PUT("/categories/:id", controllers.UpdateCategory, editorOnly)
+	v1Auth.DELETE("/categories/:id", controllers.DeleteCategory, editorOnly)
+
+	//api Media
+	v1Auth.GET("/media", controllers.GetAllMedia)
+	v1Auth.POST("/media", controllers.UploadMedia)
+	v1Auth.DELETE("/media/:id", controllers.DeleteMedia)
 
 	//api User
-	v1Auth.GET("/users", controllers.GetAllUser)
-	v1Auth.GET("/users/:id", controllers.GetUserByID)
-	v1.POST("/users", controllers.AddNewUser)
-	v1Auth.PUT("/users/:id", controllers.UpdateUser)
-	v1Auth.DELETE("/users/:id", controllers.DeleteUser)
+	adminOnly := middlewares.RoleAuthMiddlewares(models.RoleAdmin)
+	v1Auth.GET("/users", users.GetAllUser, adminOnly)
+	v1Auth.GET("/users/:id", users.GetUserByID, adminOnly)
+	v1.POST("/users", users.AddNewUser)
+	v1Auth.PUT("/users/:id", users.UpdateUser, adminOnly)
+	v1Auth.DELETE("/users/:id", users.DeleteUser, adminOnly)
+
+	//api Trash
+	v1Auth.GET("/trash/blogs", controllers.GetTrashedBlogs)
+	v1Auth.POST("/trash/blogs/:id/restore", controllers.RestoreBlog)
+	v1Auth.DELETE("/trash/blogs/:id", controllers.PurgeBlog, adminOnly)
+	v1Auth.GET("/trash/users", controllers.GetTrashedUsers, adminOnly)
+	v1Auth.POST("/trash/users/:id/restore", controllers.RestoreUser, adminOnly)
+	v1Auth.DELETE("/trash/users/:id", controllers.PurgeUser, adminOnly)
+
+	//feeds
+	e.GET("/feed.rss", controllers.GetRSSFeed)
+	e.GET("/feed.atom", controllers.GetAtomFeed)
+	e.GET("/feed.json", controllers.GetJSONFeed)
+	e.GET("/authors/:author/feed.rss", controllers.GetRSSFeed)
+	e.GET("/authors/:author/feed.atom", controllers.GetAtomFeed)
+	e.GET("/authors/:author/feed.json", controllers.GetJSONFeed)
+	e.GET("/tags/:tag/feed.rss", controllers.GetRSSFeed)
+	e.GET("/tags/:tag/feed.atom", controllers.GetAtomFeed)
+	e.GET("/tags/:tag/feed.json", controllers.GetJSONFeed)
+
+	//sitemap and robots.txt
+	e.GET("/sitemap.xml", controllers.GetSitemap)
+	e.GET("/sitemap.xml.gz", controllers.GetSitemap)
+	e.GET("/sitemaps/:file", controllers.GetSitemapPage)
+	e.GET("/robots.txt", controllers.GetRobots)
+
+	//uploaded files
+	e.GET("/media/*", controllers.ServeMedia)
+
+	//stylesheet for the highlighted code of bodyHtml
+	e.GET("/highlight.css", controllers.GetHighlightCSS)
 
 	e.Any("*", catchAllHandler)
 
@@ -44,5 +134,5 @@ func defaultHandler(c echo.Context) error {
 }
 
 func catchAllHandler(c echo.Context) error {
-	return c.String(http.StatusNotFound, "Sorry, the route path you're looking for doesn't exist!")
+	return apperror.NotFound("Sorry, the route path you're looking for doesn't exist!")
 }
@@ -0,0 +1,253 @@
+// Package service holds the business rules of blogs and users: who may do
+// what and what a change implies. Storage is left to the repositories the
+// services are built with, see database.GormBlogRepository for the one the
+// app uses.
+package service
+
+import (
+	"echo-blog/dto"
+	"echo-blog/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrForbidden      = errors.New("not allowed")
+	ErrScheduleInPast = errors.New("publishAt must be in the future")
+	// ErrMediaNotOwned is returned when a blog is given a featured image
+	// uploaded by someone other than its author.
+	ErrMediaNotOwned = errors.New("featured media belongs to another user")
+	// ErrUnknownTag is returned when an author sends a tag that does not
+	// exist, only editors and admins create tags.
+	ErrUnknownTag = errors.New("tag does not exist")
+)
+
+// BlogRepository stores blogs. A blog that
+// does not exist, or that the viewer given by userId and role may not
+// read, gives gorm.ErrRecordNotFound.
+type BlogRepository interface {
+	List(filter models.BlogFilter, page models.PageRequest, userId uint, role string) ([]models.Blog, models.PageMeta, error)
+	FindByID(id uint, userId uint, role string) (models.Blog, error)
+	FindBySlug(slug string, userId uint, role string) (models.Blog, error)
+	// FindSlugRedirect returns the current slug of the blog that used to
+	// have slug.
+	FindSlugRedirect(slug string, userId uint, role string) (string, error)
+	AuthorID(id uint) (uint, error)
+	// MediaOwnerID returns the user who uploaded the media, or the error of
+	// a featured image that does not exist.
+	MediaOwnerID(mediaId uint) (uint, error)
+	// MissingTags returns the names of the tags sent by name that do not
+	// exist yet.
+	MissingTags(tags []models.Tag) ([]string, error)
+	// Create saves a new blog with a unique slug and its first revision.
+	Create(blog *models.Blog) error
+	// Update applies the non-zero fields of changes and records a revision
+	// made by userId.
+	Update(id uint, changes models.Blog, userId uint) (models.Blog, error)
+	UpdateStatus(id uint, status string) (models.Blog, error)
+	Schedule(id uint, publishAt time.Time) (models.Blog, error)
+	ListScheduled(userId uint, role string) ([]models.Blog, error)
+	// Delete moves a blog to the trash.
+	Delete(id uint) error
+}
+
+type BlogService struct {
+	blogs   BlogRepository
+	reindex func(blogID uint)
+}
+
+// NewBlogService returns a BlogService storing blogs in blogs. reindex is
+// called with the id of every blog that changed, nil when nothing is
+// indexed.
+func NewBlogService(blogs BlogRepository, reindex func(blogID uint)) *BlogService {
+	if reindex == nil {
+		reindex = func(uint) {}
+	}
+	return &BlogService{blogs: blogs, reindex: reindex}
+}
+
+// CanModifyBlog reports whether viewer may change a blog written by
+// authorId: editors and admins may change any blog, authors only their own.
+func CanModifyBlog(viewer dto.Viewer, authorId uint) bool {
+	if viewer.Role == models.RoleAdmin || viewer.Role == models.RoleEditor {
+		return true
+	}
+	return authorId == viewer.ID
+}
+
+func (s *BlogService) List(filter models.BlogFilter, page models.PageRequest, viewer dto.Viewer) ([]models.Blog, models.PageMeta, error) {
+	return s.blogs.List(filter, page, viewer.ID, viewer.Role)
+}
+
+func (s *BlogService) Get(id uint, viewer dto.Viewer) (models.Blog, error) {
+	return s.blogs.FindByID(id, viewer.ID, viewer.Role)
+}
+
+// GetBySlug loads the blog with slug. When there is none but a blog used to
+// have slug, redirect is its current slug and err is nil.
+func (s *BlogService) GetBySlug(slug string, viewer dto.Viewer) (blog models.Blog, redirect string, err error) {
+	blog, err = s.blogs.FindBySlug(slug, viewer.ID, viewer.Role)
+	if err == nil {
+		return blog, "", nil
+	}
+	if current, e := s.blogs.FindSlugRedirect(slug, viewer.ID, viewer.Role); e == nil {
+		return blog, current, nil
+	}
+	return blog, "", err
+}
+
+// Create saves blog as a new draft of the viewer, see ChangeStatus.
+func (s *BlogService) Create(blog models.Blog, viewer dto.Viewer) (models.Blog, error) {
+	if blog.ContentFormat == "" {
+		blog.ContentFormat = models.ContentFormatMarkdown
+	}
+	blog.UserID = viewer.ID
+	blog.Author = nil
+	blog.Category = nil
+	blog.FeaturedMedia = nil
+	blog.Status = models.BlogStatusDraft
+	blog.PublishedAt = nil
+
+	if err := s.checkFeaturedMedia(blog.FeaturedMediaID, viewer.ID, viewer); err != nil {
+		return blog, err
+	}
+	if err := s.checkTags(blog.Tags, viewer); err != nil {
+		return blog, err
+	}
+	if err := s.blogs.Create(&blog); err != nil {
+		return blog, err
+	}
+	s.reindex(blog.ID)
+	return blog, nil
+}
+
+// Update applies changes to a blog the viewer may modify. Ownership and
+// status are not changed through an update.
+func (s *BlogService) Update(id uint, changes models.Blog, viewer dto.Viewer) (models.Blog, error) {
+	authorId, err := s.authorize(id, viewer)
+	if err != nil {
+		return models.Blog{}, err
+	}
+	if err := s.checkFeaturedMedia(changes.FeaturedMediaID, authorId, viewer); err != nil {
+		return models.Blog{}, err
+	}
+	if err := s.checkTags(changes.Tags, viewer); err != nil {
+		return models.Blog{}, err
+	}
+
+	changes.UserID = 0
+	changes.Author = nil
+	changes.Status = ""
+	changes.PublishedAt = nil
+
+	blog, err := s.blogs.Update(id, changes, viewer.ID)
+	if err != nil {
+		return blog, err
+	}
+	s.reindex(blog.ID)
+	return blog, nil
+}
+
+func (s *BlogService) Delete(id uint, viewer dto.Viewer) error {
+	if _, err := s.authorize(id, viewer); err != nil {
+		return err
+	}
+	if err := s.blogs.Delete(id); err != nil {
+		return err
+	}
+	s.reindex(id)
+	return nil
+}
+
+// ChangeStatus moves a blog the viewer may modify to status.
+func (s *BlogService) ChangeStatus(id uint, status string, viewer dto.Viewer) (models.Blog, error) {
+	if _, err := s.authorize(id, viewer); err != nil {
+		return models.Blog{}, err
+	}
+
+	blog, err := s.blogs.UpdateStatus(id, status)
+	if err != nil {
+		return blog, err
+	}
+	s.reindex(blog.ID)
+	return blog, nil
+}
+
+// Schedule sets a blog the viewer may modify to be published at publishAt,
+// which has to be in the future.
+func (s *BlogService) Schedule(id uint, publishAt time.Time, viewer dto.Viewer) (models.Blog, error) {
+	if _, err := s.authorize(id, viewer); err != nil {
+		return models.Blog{}, err
+	}
+	if !publishAt.After(time.Now()) {
+		return models.Blog{}, ErrScheduleInPast
+	}
+
+	blog, err := s.blogs.Schedule(id, publishAt)
+	if err != nil {
+		return blog, err
+	}
+	s.reindex(blog.ID)
+	return blog, nil
+}
+
+// Scheduled lists the upcoming scheduled blogs, authors only get their own.
+func (s *BlogService) Scheduled(viewer dto.Viewer) ([]models.Blog, error) {
+	return s.blogs.ListScheduled(viewer.ID, viewer.Role)
+}
+
+// Authorize returns ErrForbidden when the viewer may not modify the blog,
+// handlers call it before reading the changes so a stranger learns nothing
+// from the validation of their request.
+func (s *BlogService) Authorize(id uint, viewer dto.Viewer) error {
+	_, err := s.authorize(id, viewer)
+	return err
+}
+
+// authorize returns the author of the blog, or ErrForbidden when the viewer
+// may not modify the blog.
+func (s *BlogService) authorize(id uint, viewer dto.Viewer) (uint, error) {
+	authorId, err := s.blogs.AuthorID(id)
+	if err != nil {
+		return 0, err
+	}
+	if !CanModifyBlog(viewer, authorId) {
+		return 0, ErrForbidden
+	}
+	return authorId, nil
+}
+
+// checkFeaturedMedia returns ErrMediaNotOwned when mediaId is an upload of
+// someone other than the blog's author. Editors and admins may feature any
+// upload. Nil and 0 leave or remove the featured image.
+func (s *BlogService) checkFeaturedMedia(mediaId *uint, authorId uint, viewer dto.Viewer) error {
+	if mediaId == nil || *mediaId == 0 || viewer.Role == models.RoleAdmin || viewer.Role == models.RoleEditor {
+		return nil
+	}
+	ownerId, err := s.blogs.MediaOwnerID(*mediaId)
+	if err != nil {
+		return err
+	}
+	if ownerId != authorId {
+		return ErrMediaNotOwned
+	}
+	return nil
+}
+
+// checkTags returns ErrUnknownTag, naming the tags, when an author sends
+// tags that do not exist. Tags sent by editors and admins are created.
+func (s *BlogService) checkTags(tags []models.Tag, viewer dto.Viewer) error {
+	if len(tags) == 0 || viewer.Role == models.RoleAdmin || viewer.Role == models.RoleEditor {
+		return nil
+	}
+	missing, err := s.blogs.MissingTags(tags)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrUnknownTag, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"echo-blog/models"
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// ErrWrongCredentials is returned by Login for an unknown email as well as
+// a wrong password, so the two cannot be told apart.
+var ErrWrongCredentials = errors.New("wrong email or password")
+
+// ErrEmailTaken is returned when saving a user fails because another user
+// not in the trash has the email. The validation catches it first, unless
+// two requests race for the same email.
+var ErrEmailTaken = errors.New("email is already taken")
+
+// UserRepository stores users, a user that does not exist gives
+// gorm.ErrRecordNotFound.
+type UserRepository interface {
+	List(filter models.UserFilter, page models.PageRequest) ([]models.User, models.PageMeta, error)
+	FindByID(id uint) (models.User, error)
+	FindByEmail(email string) (models.User, error)
+	// Create and Update give gorm.ErrDuplicatedKey for a taken email.
+	Create(user *models.User) error
+	// Update applies the non-zero fields of changes.
+	Update(id uint, changes models.User) error
+	// Delete moves a user to the trash.
+	Delete(id uint) error
+	EmailTaken(email string, exceptId uint) (bool, error)
+	// Transaction runs fn with repositories whose changes are saved
+	// together, or not at all when fn fails.
+	Transaction(fn func(users UserRepository, sessions SessionRepository) error) error
+}
+
+// SessionRepository stores the login sessions of users.
+type SessionRepository interface {
+	// Start starts a new session and returns its first token pair.
+	Start(user *models.User) (*models.TokenPair, error)
+	// Refresh exchanges a refresh token for the next token pair of its
+	// session.
+	Refresh(refreshToken string) (*models.TokenPair, error)
+	// Revoke ends a single session, RevokeUser every session of the user.
+	Revoke(sessionId string) error
+	RevokeUser(userId uint) error
+	// IsRevoked reports whether the session was ended.
+	IsRevoked(sessionId string) (bool, error)
+}
+
+type UserService struct {
+	users    UserRepository
+	sessions SessionRepository
+}
+
+func NewUserService(users UserRepository, sessions SessionRepository) *UserService {
+	return &UserService{users: users, sessions: sessions}
+}
+
+func (s *UserService) List(filter models.UserFilter, page models.PageRequest) ([]models.User, models.PageMeta, error) {
+	return s.users.List(filter, page)
+}
+
+func (s *UserService) Get(id uint) (models.User, error) {
+	return s.users.FindByID(id)
+}
+
+// EmailTaken reports whether a user other than exceptId uses email, it makes
+// the service a validation.EmailChecker.
+func (s *UserService) EmailTaken(email string, exceptId uint) (bool, error) {
+	return s.users.EmailTaken(email, exceptId)
+}
+
+// Register creates a user signing up. Self registration always creates an
+// author, admins promote afterwards.
+func (s *UserService) Register(user models.User) (models.User, error) {
+	user.Role = models.RoleAuthor
+
+	hashedPassword, err := hashPassword(user.Password)
+	if err != nil {
+		return user, err
+	}
+	user.Password = hashedPassword
+
+	if err := s.users.Create(&user); err != nil {
+		return user, emailTaken(err)
+	}
+	return user, nil
+}
+
+// Update applies changes to a user and returns the updated user. A new
+// password is hashed first. A new password or role ends the sessions of the
+// user, their tokens carry the old role and may be in the wrong hands.
+func (s *UserService) Update(id uint, changes models.User) (models.User, error) {
+	if changes.Password != "" {
+		hashedPassword, err := hashPassword(changes.Password)
+		if err != nil {
+			return models.User{}, err
+		}
+		changes.Password = hashedPassword
+	}
+
+	err := s.users.Transaction(func(users UserRepository, sessions SessionRepository) error {
+		user, err := users.FindByID(id)
+		if err != nil {
+			return err
+		}
+		if err := users.Update(id, changes); err != nil {
+			return err
+		}
+		if changes.Password != "" || (changes.Role != "" && changes.Role != user.Role) {
+			return sessions.RevokeUser(id)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.User{}, emailTaken(err)
+	}
+	return s.users.FindByID(id)
+}
+
+// Delete moves a user to the trash and ends their sessions.
+func (s *UserService) Delete(id uint) error {
+	return s.users.Transaction(func(users UserRepository, sessions SessionRepository) error {
+		if err := users.Delete(id); err != nil {
+			return err
+		}
+		return sessions.RevokeUser(id)
+	})
+}
+
+// Login checks the password of the user with email and starts a new session
+// for them.
+func (s *UserService) Login(email string, password string) (models.User, *models.TokenPair, error) {
+	user, err := s.users.FindByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, nil, ErrWrongCredentials
+		}
+		return user, nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return user, nil, ErrWrongCredentials
+		}
+		return user, nil, err
+	}
+
+	pair, err := s.sessions.Start(&user)
+	if err != nil {
+		return user, nil, err
+	}
+	return user, pair, nil
+}
+
+// Refresh rotates the refresh token of a session, see
+// SessionRepository.Refresh.
+func (s *UserService) Refresh(refreshToken string) (*models.TokenPair, error) {
+	return s.sessions.Refresh(refreshToken)
+}
+
+// Logout ends the session the request was authenticated with.
+func (s *UserService) Logout(sessionId string) error {
+	return s.sessions.Revoke(sessionId)
+}
+
+// emailTaken turns the duplicate key error of saving a user into
+// ErrEmailTaken, the email is the only unique column of users.
+func emailTaken(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return ErrEmailTaken
+	}
+	return err
+}
+
+func hashPassword(password string) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
@@ -1,10 +1,14 @@
 package test
 
 import (
+	"echo-blog/apperror"
 	"echo-blog/config"
 	. "echo-blog/controllers"
+	"echo-blog/lib/database"
 	"echo-blog/lib/database/seeder"
+	"echo-blog/lib/search"
 	"echo-blog/models"
+	"echo-blog/service"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,25 +17,38 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/joho/godotenv"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+// blogHandler is the blog handler of routes.New, on the test database.
+func blogHandler() *BlogHandler {
+	return NewBlogHandler(blogService())
+}
+
+func revisionHandler() *RevisionHandler {
+	return NewRevisionHandler(blogService())
+}
+
+func commentHandler() *CommentHandler {
+	return NewCommentHandler(blogService())
+}
+
+func blogService() *service.BlogService {
+	return service.NewBlogService(database.NewGormBlogRepository(config.DB), search.Reindex)
+}
+
 // init function testing
 func setupBlogTest(t *testing.T) {
-	//load env
-	if err := godotenv.Load("../.env"); err != nil {
-		t.Error("Error loading .env file")
-	}
-
 	//setup database
-	config.InitDB()
+	setupDB(t)
 
 	// clear database
 	s := seeder.NewSeeder()
 	fmt.Println(s)
 	s.BlogDelete()
+	s.UserDelete()
+	s.UserSeed()
 	s.BlogSeed()
 }
 
@@ -46,12 +63,20 @@ func TestGetAllBlogsSuccess(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	assert.NoError(t, GetAllBlogs(c))
+	handle(blogHandler().GetAllBlogs, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
 	json.Unmarshal(bodyRes, &responseBody)
 	assert.Equal(t, "success get all blog", responseBody["status"])
+
+	dataBlogs := responseBody["data"].([]interface{})
+	assert.Len(t, dataBlogs, 2)
+	for _, dataBlog := range dataBlogs {
+		author := dataBlog.(map[string]interface{})["author"].(map[string]interface{})
+		assert.NotEmpty(t, author["username"])
+		assert.Nil(t, author["password"])
+	}
 }
 
 func TestGetAllBlogsFailedDBNotConnect(t *testing.T) {
@@ -68,11 +93,9 @@ func TestGetAllBlogsFailedDBNotConnect(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	err = GetAllBlogs(c)
-	assert.Error(t, err)
-	hErr, ok := err.(*echo.HTTPError)
-	assert.True(t, ok)
-	assert.Equal(t, http.StatusInternalServerError, hErr.Code)
+	handle(blogHandler().GetAllBlogs, c)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), apperror.CodeInternal)
 }
 
 func TestAddNewBlogsSuccess(t *testing.T) {
@@ -95,8 +118,11 @@ func TestAddNewBlogsSuccess(t *testing.T) {
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
 
+	//set user id
+	c.Set("userId", 2)
+
 	//test
-	assert.NoError(t, AddNewBlog(c))
+	handle(blogHandler().AddNewBlog, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -104,6 +130,9 @@ func TestAddNewBlogsSuccess(t *testing.T) {
 	json.Unmarshal(bodyRes, &responseBody)
 
 	assert.Equal(t, "new blog added successfully", responseBody["status"])
+	dataBlog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, float64(2), dataBlog["userId"])
+	assert.Equal(t, models.BlogStatusDraft, dataBlog["status"])
 }
 
 func TestAddNewBlogsFailedWhenUserNotInputAuthor(t *testing.T) {
@@ -125,7 +154,7 @@ func TestAddNewBlogsFailedWhenUserNotInputAuthor(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	assert.NoError(t, AddNewBlog(c))
+	handle(blogHandler().AddNewBlog, c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -151,7 +180,7 @@ func TestGetBlogByIdSuccess(t *testing.T) {
 	c.SetParamValues("1")
 
 	//test
-	assert.NoError(t, GetBlogByID(c))
+	handle(blogHandler().GetBlogByID, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -177,8 +206,8 @@ func TestGetBlogByIdNotFound(t *testing.T) {
 	c.SetParamValues("10")
 
 	//test
-	assert.NoError(t, GetBlogByID(c))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	handle(blogHandler().GetBlogByID, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -186,6 +215,25 @@ func TestGetBlogByIdNotFound(t *testing.T) {
 	assert.Equal(t, "blog not found", responseBody["status"])
 }
 
+func TestBlogIdMustBeNumeric(t *testing.T) {
+	setupBlogTest(t)
+
+	//test, ids that are not numbers never reach the database
+	for _, id := range []string{"1 OR 1=1", "1;DROP TABLE blogs", "abc", "-1", "0"} {
+		code, responseBody := jsonRequest(blogHandler().GetBlogByID, http.MethodGet, "/api/v1/blogs", "", 0, []string{"id"}, []string{id})
+		assert.Equal(t, http.StatusNotFound, code, id)
+		assert.Equal(t, "blog not found", responseBody["status"], id)
+	}
+	code, _ := jsonRequest(blogHandler().DeleteBlog, http.MethodDelete, "/api/v1/blogs", "", 1, []string{"id"}, []string{"1 OR 1=1"})
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = jsonRequest(userHandler().GetUserByID, http.MethodGet, "/api/v1/users", "", 1, []string{"id"}, []string{"1 OR 1=1"})
+	assert.Equal(t, http.StatusNotFound, code)
+
+	var blogs int64
+	config.DB.Model(&models.Blog{}).Count(&blogs)
+	assert.Equal(t, int64(3), blogs)
+}
+
 func TestUpdateBlogByIdSuccess(t *testing.T) {
 	setupBlogTest(t)
 
@@ -211,8 +259,11 @@ func TestUpdateBlogByIdSuccess(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues("1")
 
+	//set user id
+	c.Set("userId", 1)
+
 	//test
-	assert.NoError(t, UpdateBlog(c))
+	handle(blogHandler().UpdateBlog, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -247,8 +298,8 @@ func TestUpdateBlogByIdNotFound(t *testing.T) {
 	c.SetParamValues("100")
 
 	//test
-	assert.NoError(t, UpdateBlog(c))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	handle(blogHandler().UpdateBlog, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -272,8 +323,11 @@ func TestDeleteBlogByIdSuccess(t *testing.T) {
 	c.SetParamNames("id")
 	c.SetParamValues("1")
 
+	//set user id
+	c.Set("userId", 1)
+
 	//test
-	assert.NoError(t, DeleteBlog(c))
+	handle(blogHandler().DeleteBlog, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -303,8 +357,8 @@ func TestDeleteBlogByIdNotFound(t *testing.T) {
 	c.Set("userId", 10)
 
 	//test
-	assert.NoError(t, DeleteBlog(c))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	handle(blogHandler().DeleteBlog, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -312,3 +366,250 @@ func TestDeleteBlogByIdNotFound(t *testing.T) {
 
 	assert.Equal(t, "delete failed, blog id not found", responseBody["status"])
 }
+
+func TestUpdateBlogByIdForbiddenWhenNotAuthor(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//create json body
+	body := models.Blog{
+		Title: "Test Blog Z",
+		Body:  "Tester Z",
+		Slug:  "slugz",
+	}
+
+	//setup request
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/blogs", strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	//set user id
+	c.Set("userId", 2)
+
+	//test
+	handle(blogHandler().UpdateBlog, c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "you are not allowed to update this blog", responseBody["status"])
+}
+
+func TestDeleteBlogByIdForbiddenWhenNotAuthor(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//setup request
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/blogs", nil)
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("2")
+
+	//set user id
+	c.Set("userId", 1)
+
+	//test
+	handle(blogHandler().DeleteBlog, c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+
+	assert.Equal(t, "you are not allowed to delete this blog", responseBody["status"])
+}
+
+func TestUpdateBlogByIdSuccessWhenEditor(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//create json body
+	body := models.Blog{
+		Title: "Edited Blog 2",
+	}
+
+	//setup request
+	b, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/blogs", strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("2")
+
+	//set editor
+	c.Set("userId", 3)
+	c.Set("role", models.RoleEditor)
+
+	//test
+	handle(blogHandler().UpdateBlog, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "blog updated successfully", responseBody["status"])
+}
+
+func TestGetAllBlogsIncludesOwnDrafts(t *testing.T) {
+	setupBlogTest(t)
+	//setup echo context
+	e := echo.New()
+
+	//setup request
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/blogs", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set user id
+	c.Set("userId", 1)
+
+	//test
+	handle(blogHandler().GetAllBlogs, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Len(t, responseBody["data"], 3)
+}
+
+func TestGetBlogByIdDraftHiddenFromOthers(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//setup request
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/blogs", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+
+	//set user id
+	c.Set("userId", 2)
+
+	//test
+	handle(blogHandler().GetBlogByID, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "blog not found", responseBody["status"])
+}
+
+func TestPublishBlogSuccess(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//setup request
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/blogs/3/publish", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+
+	//set user id
+	c.Set("userId", 1)
+
+	//test
+	handle(blogHandler().PublishBlog, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "blog published successfully", responseBody["status"])
+
+	dataBlog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, models.BlogStatusPublished, dataBlog["status"])
+	assert.NotNil(t, dataBlog["publishedAt"])
+
+	//the blog is now public
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/blogs", nil)
+	rec = httptest.NewRecorder()
+	c = e.NewContext(req, rec)
+	handle(blogHandler().GetAllBlogs, c)
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	assert.Len(t, responseBody["data"], 3)
+}
+
+func TestUnpublishBlogForbiddenWhenNotAuthor(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//setup request
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/blogs/1/unpublish", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+
+	//set user id
+	c.Set("userId", 2)
+
+	//test
+	handle(blogHandler().UnpublishBlog, c)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestArchiveBlogSuccess(t *testing.T) {
+	setupBlogTest(t)
+
+	//setup echo context
+	e := echo.New()
+
+	//setup request
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/blogs/2/archive", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	//set params
+	c.SetParamNames("id")
+	c.SetParamValues("2")
+
+	//set user id
+	c.Set("userId", 2)
+
+	//test
+	handle(blogHandler().ArchiveBlog, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	dataBlog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, models.BlogStatusArchived, dataBlog["status"])
+}
@@ -0,0 +1,149 @@
+package test
+
+import (
+	"echo-blog/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func commentRequest(handler echo.HandlerFunc, method string, target string, body string, userId int, role string, values ...string) (int, map[string]interface{}) {
+	e := echo.New()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id", "comment")
+	c.SetParamValues(values...)
+	c.Set("userId", userId)
+	c.Set("role", role)
+
+	handle(handler, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func addComment(t *testing.T, blogId string, userId int, parentId float64, body string) float64 {
+	payload := fmt.Sprintf(`{"body":%q,"parentId":%v}`, body, parentId)
+	code, responseBody := commentRequest(commentHandler().AddNewComment, http.MethodPost, "/api/v1/blogs/"+blogId+"/comments", payload, userId, models.RoleAuthor, blogId)
+	assert.Equal(t, http.StatusOK, code)
+	return responseBody["data"].(map[string]interface{})["ID"].(float64)
+}
+
+func commentBodies(comments []interface{}) []string {
+	var bodies []string
+	for _, comment := range comments {
+		comment := comment.(map[string]interface{})
+		bodies = append(bodies, fmt.Sprintf("%v:%v", comment["depth"], comment["body"]))
+	}
+	return bodies
+}
+
+func TestGetBlogCommentsTreeAndFlat(t *testing.T) {
+	setupBlogTest(t)
+
+	first := addComment(t, "1", 2, 0, "first")
+	reply := addComment(t, "1", 1, first, "reply")
+	addComment(t, "1", 2, reply, "reply to reply")
+	addComment(t, "1", 3, 0, "second")
+
+	//test
+	code, responseBody := commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/1/comments", "", 0, "", "1")
+	assert.Equal(t, http.StatusOK, code)
+
+	tree := responseBody["data"].([]interface{})
+	assert.Equal(t, []string{"0:first", "0:second"}, commentBodies(tree))
+	replies := tree[0].(map[string]interface{})["replies"].([]interface{})
+	assert.Equal(t, []string{"1:reply"}, commentBodies(replies))
+	assert.Equal(t, "test1", replies[0].(map[string]interface{})["author"].(map[string]interface{})["username"])
+
+	code, responseBody = commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/1/comments?format=flat", "", 0, "", "1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"0:first", "1:reply", "2:reply to reply", "0:second"}, commentBodies(responseBody["data"].([]interface{})))
+}
+
+func TestAddNewCommentFailedWhenParentOnOtherBlog(t *testing.T) {
+	setupBlogTest(t)
+
+	other := addComment(t, "2", 1, 0, "on blog 2")
+
+	//test
+	payload := fmt.Sprintf(`{"body":"reply","parentId":%v}`, other)
+	code, responseBody := commentRequest(commentHandler().AddNewComment, http.MethodPost, "/api/v1/blogs/1/comments", payload, 1, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "parent comment not found", responseBody["status"])
+}
+
+func TestAddNewCommentFailedOnHiddenDraft(t *testing.T) {
+	setupBlogTest(t)
+
+	//test, blog 3 is a draft of user 1
+	code, responseBody := commentRequest(commentHandler().AddNewComment, http.MethodPost, "/api/v1/blogs/3/comments", `{"body":"hello"}`, 2, models.RoleAuthor, "3")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "blog not found", responseBody["status"])
+}
+
+func TestUpdateCommentOnlyByAuthor(t *testing.T) {
+	setupBlogTest(t)
+
+	id := fmt.Sprint(addComment(t, "1", 2, 0, "typo"))
+
+	//test
+	code, _ := commentRequest(commentHandler().UpdateComment, http.MethodPut, "/api/v1/blogs/1/comments/"+id, `{"body":"edited"}`, 1, models.RoleAuthor, "1", id)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, responseBody := commentRequest(commentHandler().UpdateComment, http.MethodPut, "/api/v1/blogs/1/comments/"+id, `{"body":"edited"}`, 2, models.RoleAuthor, "1", id)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "edited", responseBody["data"].(map[string]interface{})["body"])
+}
+
+func TestDeleteCommentPermissions(t *testing.T) {
+	setupBlogTest(t)
+
+	//blog 1 belongs to user 1, the comment to user 2
+	first := fmt.Sprint(addComment(t, "1", 2, 0, "first"))
+	second := fmt.Sprint(addComment(t, "1", 2, 0, "second"))
+	third := fmt.Sprint(addComment(t, "1", 2, 0, "third"))
+
+	//test
+	code, responseBody := commentRequest(commentHandler().DeleteComment, http.MethodDelete, "/api/v1/blogs/1/comments/"+first, "", 4, models.RoleAuthor, "1", first)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "you are not allowed to delete this comment", responseBody["status"])
+
+	code, _ = commentRequest(commentHandler().DeleteComment, http.MethodDelete, "/api/v1/blogs/1/comments/"+first, "", 2, models.RoleAuthor, "1", first)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = commentRequest(commentHandler().DeleteComment, http.MethodDelete, "/api/v1/blogs/1/comments/"+second, "", 1, models.RoleAuthor, "1", second)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = commentRequest(commentHandler().DeleteComment, http.MethodDelete, "/api/v1/blogs/1/comments/"+third, "", 3, models.RoleEditor, "1", third)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, responseBody = commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/1/comments", "", 0, "", "1")
+	assert.Empty(t, responseBody["data"])
+}
+
+func TestDeletedCommentKeepsReplies(t *testing.T) {
+	setupBlogTest(t)
+
+	parent := addComment(t, "1", 2, 0, "parent")
+	addComment(t, "1", 1, parent, "reply")
+	id := fmt.Sprint(parent)
+
+	//test
+	code, _ := commentRequest(commentHandler().DeleteComment, http.MethodDelete, "/api/v1/blogs/1/comments/"+id, "", 2, models.RoleAuthor, "1", id)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, responseBody := commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/1/comments?format=flat", "", 0, "", "1")
+	comments := responseBody["data"].([]interface{})
+	assert.Equal(t, []string{"0:", "1:reply"}, commentBodies(comments))
+	assert.Nil(t, comments[0].(map[string]interface{})["author"])
+	assert.NotNil(t, comments[0].(map[string]interface{})["DeletedAt"])
+}
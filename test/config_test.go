@@ -0,0 +1,208 @@
+package test
+
+import (
+	"echo-blog/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWTSecret = "test_secret_key_of_at_least_32_chars"
+
+// configFile writes a YAML config file for the test and returns its path.
+func configFile(t *testing.T, content string) string {
+	return namedConfigFile(t, "config.yaml", content)
+}
+
+// namedConfigFile writes a config file called name for the test, the
+// extension picks its format.
+func namedConfigFile(t *testing.T, name string, content string) string {
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// clearConfigEnv unsets the variables of the developer's shell the tests
+// below depend on.
+func clearConfigEnv(t *testing.T) {
+	for _, name := range []string{"CONFIG_FILE", "PORT", "DB_DRIVER", "DB_NAME", "DB_PORT", "SPAM_BANNED_WORDS", "DB_MIGRATE", "MEDIA_IMAGE_WIDTHS", "FEED_ITEMS", "FEED_FULL_CONTENT", "SITE_URL", "ROBOTS_DISALLOW"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("JWT_SECRET", testJWTSecret)
+}
+
+func TestConfigDefaults(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("DB_DRIVER", "sqlite")
+
+	//test
+	cfg, args, err := config.Load([]string{"migrate", "up"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"migrate", "up"}, args)
+	assert.Equal(t, 3000, cfg.Server.Port)
+	assert.Equal(t, 15*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, 25, cfg.Database.MaxOpenConns)
+	assert.Equal(t, "auto", cfg.Database.Migrate)
+	assert.Equal(t, time.Minute, cfg.Scheduler.Interval)
+}
+
+func TestConfigPrecedence(t *testing.T) {
+	clearConfigEnv(t)
+	file := configFile(t, `
+server:
+  port: 4000
+  read_timeout: 5s
+database:
+  driver: sqlite
+  name: file.db
+spam:
+  banned_words: [casino]
+`)
+	t.Setenv("CONFIG_FILE", file)
+
+	//test, the file overrides the defaults
+	cfg, _, err := config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 4000, cfg.Server.Port)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "file.db", cfg.Database.Name)
+	assert.Equal(t, []string{"casino"}, cfg.Spam.BannedWords)
+
+	//the environment overrides the file
+	t.Setenv("PORT", "5000")
+	t.Setenv("DB_NAME", "env.db")
+	t.Setenv("SPAM_BANNED_WORDS", "casino, viagra")
+	cfg, _, err = config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 5000, cfg.Server.Port)
+	assert.Equal(t, "env.db", cfg.Database.Name)
+	assert.Equal(t, []string{"casino", "viagra"}, cfg.Spam.BannedWords)
+
+	//flags override the environment
+	cfg, _, err = config.Load([]string{"-port", "6000", "-db-name=flag.db"})
+	assert.NoError(t, err)
+	assert.Equal(t, 6000, cfg.Server.Port)
+	assert.Equal(t, "flag.db", cfg.Database.Name)
+
+	//the file of -config replaces CONFIG_FILE
+	other := configFile(t, "server:\n  idle_timeout: 1m\n")
+	cfg, _, err = config.Load([]string{"-config", other})
+	assert.NoError(t, err)
+	assert.Equal(t, "mysql", cfg.Database.Driver)
+	assert.Equal(t, time.Minute, cfg.Server.IdleTimeout)
+}
+
+func TestConfigTOMLFile(t *testing.T) {
+	clearConfigEnv(t)
+	file := namedConfigFile(t, "config.toml", `
+[server]
+port = 4000
+read_timeout = "5s"
+
+[database]
+driver = "sqlite"
+name = "file.db"
+
+[media]
+image_widths = [320, 640]
+`)
+
+	//test
+	cfg, _, err := config.Load([]string{"-config", file})
+	assert.NoError(t, err)
+	assert.Equal(t, 4000, cfg.Server.Port)
+	assert.Equal(t, 5*time.Second, cfg.Server.ReadTimeout)
+	assert.Equal(t, "file.db", cfg.Database.Name)
+	assert.Equal(t, []int{320, 640}, cfg.Media.ImageWidths)
+
+	//unknown keys are typos
+	file = namedConfigFile(t, "config.toml", "[server]\nprot = 4000\n")
+	_, _, err = config.Load([]string{"-config", file})
+	assert.ErrorContains(t, err, "unknown keys server.prot")
+
+	file = namedConfigFile(t, "config.ini", "port = 4000\n")
+	_, _, err = config.Load([]string{"-config", file})
+	assert.ErrorContains(t, err, "only YAML and TOML files are supported")
+}
+
+func TestConfigValidation(t *testing.T) {
+	clearConfigEnv(t)
+
+	//test, every problem is reported at once
+	t.Setenv("JWT_SECRET", "short")
+	t.Setenv("DB_MIGRATE", "sometimes")
+	_, _, err := config.Load([]string{"-port", "70000"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "JWT_SECRET must be at least 32 characters")
+	assert.Contains(t, err.Error(), "PORT 70000 is not a port")
+	assert.Contains(t, err.Error(), "DB_NAME is required for mysql")
+	assert.Contains(t, err.Error(), `unknown DB_MIGRATE "sometimes"`)
+
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("DB_MIGRATE", "")
+	_, _, err = config.Load([]string{"-db-driver", "sqlite"})
+	assert.EqualError(t, err, "JWT_SECRET is required")
+
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("PORT", "eighty")
+	_, _, err = config.Load(nil)
+	assert.ErrorContains(t, err, `invalid PORT "eighty"`)
+
+	//unknown keys of the file are typos
+	t.Setenv("PORT", "")
+	_, _, err = config.Load([]string{"-config", configFile(t, "server:\n  prot: 80\n")})
+	assert.ErrorContains(t, err, "field prot not found")
+}
+
+func TestConfigRequestSettings(t *testing.T) {
+	clearConfigEnv(t)
+	t.Setenv("DB_DRIVER", "sqlite")
+
+	//test
+	t.Setenv("MEDIA_IMAGE_WIDTHS", "200, 400")
+	t.Setenv("FEED_FULL_CONTENT", "true")
+	t.Setenv("ROBOTS_DISALLOW", "/api/v1/users,/api/v1/comments")
+	cfg, _, err := config.Load(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{200, 400}, cfg.Media.ImageWidths)
+	assert.Equal(t, int64(10<<20), cfg.Media.MaxSize)
+	assert.True(t, cfg.Feed.FullContent)
+	assert.Equal(t, 20, cfg.Feed.Items)
+	assert.Equal(t, []string{"/api/v1/users", "/api/v1/comments"}, cfg.Robots.Disallow)
+	assert.Equal(t, "/api/v1/blogs/slug/", cfg.Site.BlogPath)
+
+	t.Setenv("MEDIA_IMAGE_WIDTHS", "200, wide")
+	_, _, err = config.Load(nil)
+	assert.ErrorContains(t, err, `invalid MEDIA_IMAGE_WIDTHS "200, wide"`)
+
+	t.Setenv("MEDIA_IMAGE_WIDTHS", "")
+	t.Setenv("FEED_ITEMS", "0")
+	t.Setenv("SITE_URL", "blog.example.com")
+	t.Setenv("ROBOTS_DISALLOW", "api")
+	_, _, err = config.Load(nil)
+	assert.ErrorContains(t, err, "FEED_ITEMS must be between 1 and 100")
+	assert.ErrorContains(t, err, `SITE_URL "blog.example.com" is not an http or https URL`)
+	assert.ErrorContains(t, err, `ROBOTS_DISALLOW: "api" must start with /`)
+}
+
+func TestConfigRedactsSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.JWTSecret = testJWTSecret
+	cfg.Database.Password = "db_password"
+	cfg.Storage.S3.SecretKey = "s3_secret"
+	cfg.Database.Name = "blog"
+
+	//test
+	printed := cfg.String()
+	assert.NotContains(t, printed, testJWTSecret)
+	assert.NotContains(t, printed, "db_password")
+	assert.NotContains(t, printed, "s3_secret")
+	assert.Contains(t, printed, "jwt_secret: '[redacted]'")
+	assert.Contains(t, printed, "name: blog")
+	assert.Equal(t, "db_password", cfg.Database.Password)
+}
@@ -0,0 +1,94 @@
+package test
+
+import (
+	"echo-blog/dto"
+	"echo-blog/models"
+	"echo-blog/routes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestResponsesNeverContainSecrets(t *testing.T) {
+	setupBlogTest(t)
+	e := routes.New()
+	session := login(t, e, "test4@mail.com")
+	token := session["token"].(string)
+	assert.Nil(t, session["password"])
+
+	requests := []struct {
+		method string
+		target string
+		body   string
+	}{
+		{http.MethodPost, "/api/v1/users", `{"username":"budi","email":"budi@mail.com","password":"secret123"}`},
+		{http.MethodGet, "/api/v1/users", ""},
+		{http.MethodGet, "/api/v1/users/1", ""},
+		{http.MethodPut, "/api/v1/users/2", `{"username":"test2b","password":"changed123"}`},
+		{http.MethodDelete, "/api/v1/users/3", ""},
+		{http.MethodGet, "/api/v1/trash/users", ""},
+		{http.MethodPost, "/api/v1/trash/users/3/restore", ""},
+		{http.MethodGet, "/api/v1/blogs", ""},
+		{http.MethodGet, "/api/v1/blogs/1", ""},
+		{http.MethodGet, "/api/v1/blogs/slug/slug1", ""},
+		{http.MethodPost, "/api/v1/blogs/1/publish", ""},
+	}
+
+	//test
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.target, strings.NewReader(r.body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code, r.target)
+
+		body := rec.Body.String()
+		assert.NotContains(t, body, `"password"`, r.target)
+		assert.NotContains(t, body, `"token"`, r.target)
+		assert.NotContains(t, body, "$2a$", r.target)
+		assert.NotContains(t, body, token, r.target)
+	}
+}
+
+func TestUserVisibility(t *testing.T) {
+	user := models.User{Model: gorm.Model{ID: 2}, Username: "test2", Email: "test2@mail.com", Role: models.RoleAuthor}
+
+	//test
+	other := dto.NewUser(user, dto.Viewer{ID: 1, Role: models.RoleEditor})
+	assert.Equal(t, "test2", other.Username)
+	assert.Empty(t, other.Email)
+	assert.Empty(t, other.Role)
+
+	self := dto.NewUser(user, dto.Viewer{ID: 2, Role: models.RoleAuthor})
+	assert.Equal(t, "test2@mail.com", self.Email)
+	assert.Equal(t, models.RoleAuthor, self.Role)
+	assert.Nil(t, self.DeletedAt)
+
+	user.DeletedAt = gorm.DeletedAt{Valid: true}
+	admin := dto.NewUser(user, dto.Viewer{ID: 4, Role: models.RoleAdmin})
+	assert.Equal(t, "test2@mail.com", admin.Email)
+	assert.NotNil(t, admin.DeletedAt)
+}
+
+func TestBlogVisibility(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	var anonymous map[string]interface{}
+	json.Unmarshal(getFeed("/api/v1/blogs/1", nil).Body.Bytes(), &anonymous)
+	blog := anonymous["data"].(map[string]interface{})
+	assert.Equal(t, "Test Blog 1", blog["title"])
+	assert.NotContains(t, blog, "autoApproveComments")
+	assert.NotContains(t, blog, "DeletedAt")
+
+	code, author := jsonRequest(blogHandler().GetBlogByID, http.MethodGet, "/api/v1/blogs/1", "", 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, author["data"].(map[string]interface{})["autoApproveComments"])
+}
@@ -0,0 +1,85 @@
+package test
+
+import (
+	"echo-blog/apperror"
+	"echo-blog/middlewares"
+	"echo-blog/routes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// handle runs handler like the router does, an error it returns is written
+// by the error handler of the app.
+func handle(handler echo.HandlerFunc, c echo.Context) {
+	if err := handler(c); err != nil {
+		middlewares.ErrorHandler(err, c)
+	}
+}
+
+func TestErrorResponses(t *testing.T) {
+	setupBlogTest(t)
+	e := routes.New()
+	author := login(t, e, "test1@mail.com")["token"].(string)
+
+	requests := []struct {
+		target string
+		token  string
+		code   int
+		error  string
+	}{
+		{"/api/v1/blogs/999", "", http.StatusNotFound, apperror.CodeNotFound},
+		{"/no-such-route", "", http.StatusNotFound, apperror.CodeNotFound},
+		{"/api/v1/users", "", http.StatusUnauthorized, apperror.CodeUnauthorized},
+		{"/api/v1/users", author, http.StatusForbidden, apperror.CodeForbidden},
+		{"/api/v1/blogs?sort=body", "", http.StatusBadRequest, apperror.CodeValidation},
+	}
+
+	//test
+	for _, r := range requests {
+		req := httptest.NewRequest(http.MethodGet, r.target, nil)
+		if r.token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+r.token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		assert.Equal(t, r.code, rec.Code, r.target)
+
+		var responseBody map[string]interface{}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &responseBody), r.target)
+		assert.Equal(t, float64(r.code), responseBody["code"], r.target)
+		assert.Equal(t, r.error, responseBody["errorCode"], r.target)
+		assert.NotEmpty(t, responseBody["status"], r.target)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	errs := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{gorm.ErrRecordNotFound, http.StatusNotFound, apperror.CodeNotFound},
+		{bcrypt.ErrMismatchedHashAndPassword, http.StatusUnauthorized, apperror.CodeUnauthorized},
+		{echo.NewHTTPError(http.StatusRequestEntityTooLarge, "too big"), http.StatusRequestEntityTooLarge, apperror.CodeTooLarge},
+		{apperror.Conflict("taken"), http.StatusConflict, apperror.CodeConflict},
+		{errors.New("connection refused"), http.StatusInternalServerError, apperror.CodeInternal},
+	}
+
+	//test
+	for _, e := range errs {
+		appErr := apperror.From(e.err)
+		assert.Equal(t, e.status, appErr.Status, e.err.Error())
+		assert.Equal(t, e.code, appErr.Code, e.err.Error())
+	}
+
+	//internal errors are not shown to the client
+	assert.Equal(t, "internal server error", apperror.From(errors.New("connection refused")).Message)
+}
@@ -0,0 +1,372 @@
+package test
+
+import (
+	. "echo-blog/controllers"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"echo-blog/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// memoryBlogs is a service.BlogRepository kept in a map, for handler tests
+// without a database.
+type memoryBlogs struct {
+	blogs     map[uint]models.Blog
+	redirects map[string]uint
+	nextID    uint
+}
+
+func newMemoryBlogs(blogs ...models.Blog) *memoryBlogs {
+	r := &memoryBlogs{blogs: map[uint]models.Blog{}, redirects: map[string]uint{}}
+	for _, blog := range blogs {
+		r.Create(&blog)
+	}
+	return r
+}
+
+func (r *memoryBlogs) find(id uint) (models.Blog, error) {
+	blog, ok := r.blogs[id]
+	if !ok {
+		return blog, gorm.ErrRecordNotFound
+	}
+	return blog, nil
+}
+
+func visibleTo(blog models.Blog, userId uint, role string) bool {
+	return blog.Status == models.BlogStatusPublished || blog.UserID == userId || role == models.RoleAdmin || role == models.RoleEditor
+}
+
+func (r *memoryBlogs) List(filter models.BlogFilter, page models.PageRequest, userId uint, role string) ([]models.Blog, models.PageMeta, error) {
+	var blogs []models.Blog
+	for id := uint(1); id <= r.nextID; id++ {
+		blog, ok := r.blogs[id]
+		if ok && visibleTo(blog, userId, role) && (filter.Status == "" || blog.Status == filter.Status) {
+			blogs = append(blogs, blog)
+		}
+	}
+	return blogs, models.PageMeta{Total: int64(len(blogs))}, nil
+}
+
+func (r *memoryBlogs) FindByID(id uint, userId uint, role string) (models.Blog, error) {
+	blog, err := r.find(id)
+	if err == nil && !visibleTo(blog, userId, role) {
+		return models.Blog{}, gorm.ErrRecordNotFound
+	}
+	return blog, err
+}
+
+func (r *memoryBlogs) FindBySlug(slug string, userId uint, role string) (models.Blog, error) {
+	for _, blog := range r.blogs {
+		if blog.Slug == slug && visibleTo(blog, userId, role) {
+			return blog, nil
+		}
+	}
+	return models.Blog{}, gorm.ErrRecordNotFound
+}
+
+func (r *memoryBlogs) FindSlugRedirect(slug string, userId uint, role string) (string, error) {
+	blog, ok := r.blogs[r.redirects[slug]]
+	if !ok || !visibleTo(blog, userId, role) {
+		return "", gorm.ErrRecordNotFound
+	}
+	return blog.Slug, nil
+}
+
+func (r *memoryBlogs) AuthorID(id uint) (uint, error) {
+	blog, err := r.find(id)
+	return blog.UserID, err
+}
+
+func (r *memoryBlogs) MediaOwnerID(mediaId uint) (uint, error) {
+	return 0, database.ErrMediaNotFound
+}
+
+// MissingTags reports every tag sent by name, no tags are kept.
+func (r *memoryBlogs) MissingTags(tags []models.Tag) ([]string, error) {
+	var missing []string
+	for _, tag := range tags {
+		if tag.ID == 0 {
+			missing = append(missing, tag.Name)
+		}
+	}
+	return missing, nil
+}
+
+func (r *memoryBlogs) Create(blog *models.Blog) error {
+	r.nextID++
+	blog.ID = r.nextID
+	if blog.Slug = helper.MakeSlug(blog.Slug); blog.Slug == "" {
+		blog.Slug = helper.MakeSlug(blog.Title)
+	}
+	r.blogs[blog.ID] = *blog
+	return nil
+}
+
+func (r *memoryBlogs) Update(id uint, changes models.Blog, userId uint) (models.Blog, error) {
+	blog, err := r.find(id)
+	if err != nil {
+		return blog, err
+	}
+	if changes.Title != "" {
+		blog.Title = changes.Title
+	}
+	if changes.Body != "" {
+		blog.Body = changes.Body
+	}
+	if slug := helper.MakeSlug(changes.Slug); slug != "" && slug != blog.Slug {
+		r.redirects[blog.Slug] = blog.ID
+		blog.Slug = slug
+	}
+	r.blogs[blog.ID] = blog
+	return blog, nil
+}
+
+func (r *memoryBlogs) UpdateStatus(id uint, status string) (models.Blog, error) {
+	blog, err := r.find(id)
+	if err != nil {
+		return blog, err
+	}
+	blog.Status = status
+	r.blogs[blog.ID] = blog
+	return blog, nil
+}
+
+func (r *memoryBlogs) Schedule(id uint, publishAt time.Time) (models.Blog, error) {
+	blog, err := r.find(id)
+	if err != nil {
+		return blog, err
+	}
+	if blog.Status == models.BlogStatusPublished {
+		return blog, database.ErrBlogAlreadyPublished
+	}
+	blog.Status, blog.ScheduledAt = models.BlogStatusScheduled, &publishAt
+	r.blogs[blog.ID] = blog
+	return blog, nil
+}
+
+func (r *memoryBlogs) ListScheduled(userId uint, role string) ([]models.Blog, error) {
+	blogs, _, err := r.List(models.BlogFilter{Status: models.BlogStatusScheduled}, models.PageRequest{}, userId, role)
+	return blogs, err
+}
+
+func (r *memoryBlogs) Delete(id uint) error {
+	blog, err := r.find(id)
+	if err != nil {
+		return err
+	}
+	delete(r.blogs, blog.ID)
+	return nil
+}
+
+// memoryUsers is a service.UserRepository and service.SessionRepository
+// kept in maps, for handler tests without a database.
+type memoryUsers struct {
+	users    map[uint]models.User
+	sessions map[uint]int
+	nextID   uint
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{users: map[uint]models.User{}, sessions: map[uint]int{}}
+}
+
+func (r *memoryUsers) List(filter models.UserFilter, page models.PageRequest) ([]models.User, models.PageMeta, error) {
+	var users []models.User
+	for id := uint(1); id <= r.nextID; id++ {
+		if user, ok := r.users[id]; ok && (filter.Role == "" || user.Role == filter.Role) {
+			users = append(users, user)
+		}
+	}
+	return users, models.PageMeta{Total: int64(len(users))}, nil
+}
+
+func (r *memoryUsers) FindByID(id uint) (models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return user, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+func (r *memoryUsers) FindByEmail(email string) (models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return models.User{}, gorm.ErrRecordNotFound
+}
+
+func (r *memoryUsers) Create(user *models.User) error {
+	if taken, _ := r.EmailTaken(user.Email, 0); taken {
+		return gorm.ErrDuplicatedKey
+	}
+	r.nextID++
+	user.ID = r.nextID
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *memoryUsers) Update(id uint, changes models.User) error {
+	user, err := r.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if taken, _ := r.EmailTaken(changes.Email, user.ID); changes.Email != "" && taken {
+		return gorm.ErrDuplicatedKey
+	}
+	for field, value := range map[*string]string{&user.Username: changes.Username, &user.Email: changes.Email, &user.Password: changes.Password} {
+		if value != "" {
+			*field = value
+		}
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *memoryUsers) Delete(id uint) error {
+	user, err := r.FindByID(id)
+	if err != nil {
+		return err
+	}
+	delete(r.users, user.ID)
+	return nil
+}
+
+func (r *memoryUsers) EmailTaken(email string, exceptId uint) (bool, error) {
+	user, err := r.FindByEmail(email)
+	return err == nil && user.ID != exceptId, nil
+}
+
+// Transaction runs fn on r itself, a failing fn does not roll back.
+func (r *memoryUsers) Transaction(fn func(users service.UserRepository, sessions service.SessionRepository) error) error {
+	return fn(r, r)
+}
+
+func (r *memoryUsers) Start(user *models.User) (*models.TokenPair, error) {
+	r.sessions[user.ID]++
+	return &models.TokenPair{Token: "token-" + user.Username, RefreshToken: "refresh-" + user.Username}, nil
+}
+
+func (r *memoryUsers) Refresh(refreshToken string) (*models.TokenPair, error) {
+	for _, user := range r.users {
+		if r.sessions[user.ID] > 0 && refreshToken == "refresh-"+user.Username {
+			return r.Start(&user)
+		}
+	}
+	return nil, database.ErrRefreshTokenInvalid
+}
+
+func (r *memoryUsers) Revoke(sessionId string) error {
+	return nil
+}
+
+func (r *memoryUsers) RevokeUser(userId uint) error {
+	delete(r.sessions, userId)
+	return nil
+}
+
+func (r *memoryUsers) IsRevoked(sessionId string) (bool, error) {
+	return sessionId == "", nil
+}
+
+// fakeRequest runs handler like jsonRequest, for a viewer with role.
+func fakeRequest(handler echo.HandlerFunc, method string, target string, body string, userId int, role string, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	if id != "" {
+		c.SetParamNames("id")
+		c.SetParamValues(id)
+	}
+	c.Set("userId", userId)
+	c.Set("role", role)
+
+	handle(handler, c)
+	return rec
+}
+
+func TestBlogHandlerWithFakeRepository(t *testing.T) {
+	blogs := newMemoryBlogs(
+		models.Blog{Title: "Published", Body: "Body", UserID: 1, Status: models.BlogStatusPublished},
+		models.Blog{Title: "Draft", Body: "Body", UserID: 1, Status: models.BlogStatusDraft},
+	)
+	handler := NewBlogHandler(service.NewBlogService(blogs, nil))
+
+	//test, new blogs are drafts of their author
+	rec := fakeRequest(handler.AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":"New Blog","body":"New Body"}`, 2, models.RoleAuthor, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.BlogStatusDraft, blogs.blogs[3].Status)
+	assert.Equal(t, uint(2), blogs.blogs[3].UserID)
+	assert.Equal(t, models.ContentFormatMarkdown, blogs.blogs[3].ContentFormat)
+
+	//drafts are hidden from other authors
+	rec = fakeRequest(handler.GetBlogByID, http.MethodGet, "/api/v1/blogs/2", "", 2, models.RoleAuthor, "2")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = fakeRequest(handler.GetAllBlogs, http.MethodGet, "/api/v1/blogs", "", 0, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"total":1`)
+
+	//only the author or an editor changes a blog
+	rec = fakeRequest(handler.UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"title":"Taken Over"}`, 2, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = fakeRequest(handler.UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"slug":"!!!"}`, 2, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = fakeRequest(handler.ScheduleBlog, http.MethodPost, "/api/v1/blogs/1/schedule", `{}`, 2, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = fakeRequest(handler.UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"title":"Edited","slug":"edited"}`, 3, models.RoleEditor, "1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "Edited", blogs.blogs[1].Title)
+	assert.Equal(t, models.BlogStatusPublished, blogs.blogs[1].Status)
+
+	//published blogs cannot be scheduled, drafts only in the future
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	rec = fakeRequest(handler.ScheduleBlog, http.MethodPost, "/api/v1/blogs/1/schedule", `{"publishAt":"`+future+`"}`, 1, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	rec = fakeRequest(handler.ScheduleBlog, http.MethodPost, "/api/v1/blogs/2/schedule", `{"publishAt":"2001-01-01T00:00:00Z"}`, 1, models.RoleAuthor, "2")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = fakeRequest(handler.DeleteBlog, http.MethodDelete, "/api/v1/blogs/2", "", 2, models.RoleAuthor, "2")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = fakeRequest(handler.DeleteBlog, http.MethodDelete, "/api/v1/blogs/2", "", 1, models.RoleAuthor, "2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, blogs.blogs, uint(2))
+}
+
+func TestUserHandlerWithFakeRepository(t *testing.T) {
+	users := newMemoryUsers()
+	handler := NewUserHandler(service.NewUserService(users, users))
+
+	//test, self registration makes an author with a hashed password
+	rec := fakeRequest(handler.AddNewUser, http.MethodPost, "/api/v1/users", `{"username":"budi","email":"budi@mail.com","password":"secret123","role":"admin"}`, 0, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, models.RoleAuthor, users.users[1].Role)
+	assert.NotEqual(t, "secret123", users.users[1].Password)
+
+	rec = fakeRequest(handler.AddNewUser, http.MethodPost, "/api/v1/users", `{"username":"budi2","email":"budi@mail.com","password":"secret123"}`, 0, "", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"taken"`)
+
+	rec = fakeRequest(handler.LoginUser, http.MethodPost, "/api/v1/login", `{"email":"budi@mail.com","password":"wrong123"}`, 0, "", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	rec = fakeRequest(handler.LoginUser, http.MethodPost, "/api/v1/login", `{"email":"budi@mail.com","password":"secret123"}`, 0, "", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, users.sessions[1])
+
+	//deleting a user ends their sessions
+	rec = fakeRequest(handler.DeleteUser, http.MethodDelete, "/api/v1/users/1", "", 4, models.RoleAdmin, "1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, users.sessions)
+	rec = fakeRequest(handler.GetUserByID, http.MethodGet, "/api/v1/users/1", "", 4, models.RoleAdmin, "1")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
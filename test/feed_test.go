@@ -0,0 +1,168 @@
+package test
+
+import (
+	"echo-blog/config"
+	"echo-blog/routes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func getFeed(target string, headers map[string]string) *httptest.ResponseRecorder {
+	e := routes.New()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+type rssFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Items []struct {
+			Title   string `xml:"title"`
+			Link    string `xml:"link"`
+			Content string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+		} `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Title  string `xml:"title"`
+		Author string `xml:"author>name"`
+	} `xml:"entry"`
+}
+
+type jsonFeed struct {
+	Version string `json:"version"`
+	Items   []struct {
+		Title       string   `json:"title"`
+		Summary     string   `json:"summary"`
+		ContentText string   `json:"content_text"`
+		Tags        []string `json:"tags"`
+	} `json:"items"`
+}
+
+func TestRSSFeedListsPublishedBlogs(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := getFeed("/feed.rss", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/rss+xml; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+
+	var feed rssFeed
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	assert.Equal(t, "echo-blog", feed.Channel.Title)
+	assert.Len(t, feed.Channel.Items, 2)
+	for _, item := range feed.Channel.Items {
+		assert.NotEqual(t, "Test Draft 3", item.Title)
+		assert.Empty(t, item.Content)
+	}
+	assert.Contains(t, []string{feed.Channel.Items[0].Link, feed.Channel.Items[1].Link}, "http://example.com/api/v1/blogs/slug/slug1")
+}
+
+func TestAtomFeedPerAuthor(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := getFeed("/authors/test2/feed.atom", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/atom+xml; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+
+	var feed atomFeed
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &feed))
+	assert.Len(t, feed.Entries, 1)
+	assert.Equal(t, "Test Blog 2", feed.Entries[0].Title)
+	assert.Equal(t, "test2", feed.Entries[0].Author)
+}
+
+func TestJSONFeedPerTagWithFullContent(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := getFeed("/tags/go/feed.json", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/feed+json; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+
+	var feed jsonFeed
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &feed))
+	assert.Equal(t, "https://jsonfeed.org/version/1.1", feed.Version)
+	assert.Len(t, feed.Items, 1)
+	assert.Equal(t, "Test Blog 1", feed.Items[0].Title)
+	assert.Equal(t, []string{"Go", "Web"}, feed.Items[0].Tags)
+
+	setupSettings(t, func(cfg *config.Config) { cfg.Feed.FullContent = true })
+	rec = getFeed("/tags/go/feed.rss", nil)
+	var rss rssFeed
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss))
+	assert.Equal(t, "<p>Test Body 1</p>\n", rss.Channel.Items[0].Content)
+
+	rec = getFeed("/tags/go/feed.rss?content=excerpt", nil)
+	rss = rssFeed{}
+	assert.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &rss))
+	assert.Empty(t, rss.Channel.Items[0].Content)
+}
+
+func TestFeedItemLimit(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	setupSettings(t, func(cfg *config.Config) { cfg.Feed.Items = 1 })
+	var feed jsonFeed
+	assert.NoError(t, json.Unmarshal(getFeed("/feed.json", nil).Body.Bytes(), &feed))
+	assert.Len(t, feed.Items, 1)
+
+	feed = jsonFeed{}
+	assert.NoError(t, json.Unmarshal(getFeed("/feed.json?limit=5", nil).Body.Bytes(), &feed))
+	assert.Len(t, feed.Items, 2)
+
+	assert.Equal(t, http.StatusBadRequest, getFeed("/feed.json?limit=0", nil).Code)
+	assert.Equal(t, http.StatusBadRequest, getFeed("/feed.json?content=some", nil).Code)
+}
+
+func TestFeedConditionalRequests(t *testing.T) {
+	setupBlogTest(t)
+
+	rec := getFeed("/feed.atom", nil)
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	assert.NotEmpty(t, etag)
+	assert.NotEmpty(t, lastModified)
+
+	//test
+	rec = getFeed("/feed.atom", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+	assert.Empty(t, rec.Body.String())
+
+	rec = getFeed("/feed.atom", map[string]string{"If-Modified-Since": lastModified})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	//another format has another etag
+	rec = getFeed("/feed.rss", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestFeedCachedPubliclyOnlyWithSiteURL(t *testing.T) {
+	setupBlogTest(t)
+
+	//test, links made from the Host header stay out of shared caches
+	rec := getFeed("/feed.atom", nil)
+	assert.Equal(t, "private, max-age=300", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "Host", rec.Header().Get("Vary"))
+
+	setupSettings(t, func(cfg *config.Config) { cfg.Site.URL = "https://blog.example.com" })
+	rec = getFeed("/sitemap.xml", nil)
+	assert.Equal(t, "public, max-age=300", rec.Header().Get("Cache-Control"))
+	assert.Empty(t, rec.Header().Get("Vary"))
+}
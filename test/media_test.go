@@ -0,0 +1,324 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"echo-blog/config"
+	. "echo-blog/controllers"
+	"echo-blog/lib/storage"
+	"echo-blog/models"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMediaTest(t *testing.T, store storage.Storage) {
+	setupBlogTest(t)
+	storage.Store = store
+	t.Cleanup(func() { storage.Store = nil })
+}
+
+func pngFile(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))))
+	return buf.Bytes()
+}
+
+func uploadFile(t *testing.T, userId int, name string, content []byte) (int, map[string]interface{}) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", name)
+	assert.NoError(t, err)
+	part.Write(content)
+	writer.Close()
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/media", &body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("userId", userId)
+
+	handle(UploadMedia, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func TestUploadAndServeMedia(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+	content := pngFile(t)
+
+	//test, the type comes from the content, not the name
+	code, responseBody := uploadFile(t, 1, "photo.txt", content)
+	assert.Equal(t, http.StatusOK, code)
+	media := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, "image/png", media["contentType"])
+	assert.Equal(t, "photo.txt", media["fileName"])
+	assert.Equal(t, float64(len(content)), media["size"])
+	url := media["url"].(string)
+	assert.True(t, strings.HasPrefix(url, "/media/"))
+	assert.True(t, strings.HasSuffix(url, ".png"))
+
+	rec := getFeed(url, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, content, rec.Body.Bytes())
+	assert.Equal(t, "image/png", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, "public, max-age=31536000, immutable", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, "nosniff", rec.Header().Get("X-Content-Type-Options"))
+
+	rec = getFeed(url, map[string]string{"If-None-Match": rec.Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+
+	assert.Equal(t, http.StatusNotFound, getFeed("/media/2020/01/missing.png", nil).Code)
+}
+
+func TestUploadMediaRejected(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+
+	//test
+	code, responseBody := uploadFile(t, 1, "evil.png", []byte("<svg onload=alert(1)></svg>"))
+	assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	assert.Equal(t, "file type text/plain is not allowed", responseBody["status"])
+
+	setupSettings(t, func(cfg *config.Config) { cfg.Media.MaxSize = 10 })
+	code, responseBody = uploadFile(t, 1, "photo.png", pngFile(t))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Equal(t, "file must not be larger than 10 bytes", responseBody["status"])
+}
+
+// countingReader counts the bytes read from it.
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestUploadMediaStopsReadingLargeBody(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+	setupSettings(t, func(cfg *config.Config) { cfg.Media.MaxSize = 10 })
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", "large.png")
+	assert.NoError(t, err)
+	part.Write(bytes.Repeat([]byte{0}, 8<<20))
+	writer.Close()
+	body := &countingReader{Reader: &form}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/media", body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := echo.New().NewContext(req, rec)
+	c.Set("userId", 1)
+
+	//test, the upload is refused before the whole body is read
+	handle(UploadMedia, c)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Less(t, body.read, 1<<20)
+}
+
+func TestFeaturedMediaAndDelete(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+	_, responseBody := uploadFile(t, 1, "cover.png", pngFile(t))
+	media := responseBody["data"].(map[string]interface{})
+	id := fmt.Sprint(media["id"])
+
+	//test
+	code, responseBody := jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"featuredMediaId":`+id+`}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	featured := responseBody["data"].(map[string]interface{})["featuredMedia"].(map[string]interface{})
+	assert.Equal(t, media["url"], featured["url"])
+
+	code, responseBody = jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":"Cover","body":"x","featuredMediaId":999}`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "media not found", responseBody["status"])
+
+	//authors only feature their own uploads, moderators any
+	code, _ = jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/2", `{"featuredMediaId":`+id+`}`, 2, []string{"id"}, []string{"2"})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":"Cover","body":"x","featuredMediaId":`+id+`}`, 2, nil, nil)
+	assert.Equal(t, http.StatusForbidden, code)
+	rec := fakeRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/2", `{"featuredMediaId":`+id+`}`, 3, models.RoleEditor, "2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	//only the uploader or a moderator deletes it
+	code, _ = jsonRequest(DeleteMedia, http.MethodDelete, "/api/v1/media/"+id, "", 2, []string{"id"}, []string{id})
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _ = jsonRequest(DeleteMedia, http.MethodDelete, "/api/v1/media/"+id, "", 1, []string{"id"}, []string{id})
+	assert.Equal(t, http.StatusOK, code)
+
+	_, responseBody = jsonRequest(blogHandler().GetBlogByID, http.MethodGet, "/api/v1/blogs/1", "", 1, []string{"id"}, []string{"1"})
+	blog := responseBody["data"].(map[string]interface{})
+	assert.Nil(t, blog["featuredMediaId"])
+	assert.Equal(t, http.StatusNotFound, getFeed(media["url"].(string), nil).Code)
+}
+
+// jpegWithOrientation encodes a width x height JPEG carrying an EXIF
+// orientation, as cameras write photos taken upright.
+func jpegWithOrientation(t *testing.T, width int, height int, orientation byte) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil))
+	encoded := buf.Bytes()
+
+	exif := []byte("Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01\x00")
+	exif = append(exif, orientation, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte{0xFF, 0xE1, 0, byte(len(exif) + 2)}, exif...)
+	return append(append(append([]byte{}, encoded[:2]...), segment...), encoded[2:]...)
+}
+
+func TestUploadImageMakesVariants(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+	content := jpegWithOrientation(t, 1400, 700, 6)
+
+	//test, the photo is turned upright and stripped of its EXIF data
+	code, responseBody := uploadFile(t, 1, "photo.jpg", content)
+	assert.Equal(t, http.StatusOK, code)
+	media := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, float64(700), media["width"])
+	assert.Equal(t, float64(1400), media["height"])
+
+	url := media["url"].(string)
+	original := getFeed(url, nil).Body.Bytes()
+	assert.False(t, bytes.Contains(original, []byte("Exif")))
+	config, err := jpeg.DecodeConfig(bytes.NewReader(original))
+	assert.NoError(t, err)
+	assert.Equal(t, 700, config.Width)
+
+	var names []string
+	var srcset []string
+	for _, variant := range media["variants"].([]interface{}) {
+		variant := variant.(map[string]interface{})
+		names = append(names, variant["name"].(string))
+		if variant["name"] != "thumbnail" {
+			srcset = append(srcset, fmt.Sprintf("%s %vw", variant["url"], variant["width"]))
+		}
+
+		rec := getFeed(variant["url"].(string), nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "image/jpeg", rec.Header().Get(echo.HeaderContentType))
+		config, err := jpeg.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+		assert.NoError(t, err)
+		assert.Equal(t, variant["width"], float64(config.Width))
+		assert.Equal(t, variant["height"], float64(config.Height))
+	}
+	assert.Equal(t, []string{"thumbnail", "320w", "640w"}, names)
+	assert.Equal(t, strings.Join(append(srcset, url+" 700w"), ", "), media["srcset"])
+
+	//the featured image of a blog comes with its srcset
+	_, responseBody = jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", fmt.Sprintf(`{"featuredMediaId":%v}`, media["id"]), 1, []string{"id"}, []string{"1"})
+	featured := responseBody["data"].(map[string]interface{})["featuredMedia"].(map[string]interface{})
+	assert.Equal(t, media["srcset"], featured["srcset"])
+	assert.Len(t, featured["variants"], 3)
+
+	//deleting the upload removes its variants
+	id := fmt.Sprint(media["id"])
+	code, _ = jsonRequest(DeleteMedia, http.MethodDelete, "/api/v1/media/"+id, "", 1, []string{"id"}, []string{id})
+	assert.Equal(t, http.StatusOK, code)
+	for _, variant := range media["variants"].([]interface{}) {
+		assert.Equal(t, http.StatusNotFound, getFeed(variant.(map[string]interface{})["url"].(string), nil).Code)
+	}
+}
+
+func TestUploadInvalidImage(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+
+	//test, a PNG signature followed by garbage
+	code, responseBody := uploadFile(t, 1, "broken.png", append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 64)...))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, responseBody["status"], "file is not a valid image")
+}
+
+func TestGetAllMediaOfUser(t *testing.T) {
+	setupMediaTest(t, storage.NewLocalStorage(t.TempDir()))
+	uploadFile(t, 1, "a.png", pngFile(t))
+	uploadFile(t, 2, "b.png", pngFile(t))
+
+	//test
+	code, responseBody := jsonRequest(GetAllMedia, http.MethodGet, "/api/v1/media", "", 2, nil, nil)
+	assert.Equal(t, http.StatusOK, code)
+	media := responseBody["data"].([]interface{})
+	assert.Len(t, media, 1)
+	assert.Equal(t, "b.png", media[0].(map[string]interface{})["fileName"])
+}
+
+// fakeS3 is a stand-in for an S3 server keeping objects in memory.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	auth    []string
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		body, ok := s.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "<Error><Code>NoSuchKey</Code></Error>", http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Storage(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := storage.NewS3Storage(storage.S3Config{Endpoint: server.URL, Bucket: "blog", AccessKey: "key", SecretKey: "secret"})
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	//test
+	assert.NoError(t, store.Put(ctx, "2024/05/a b.txt", strings.NewReader("hello"), 5, "text/plain"))
+	assert.Contains(t, fake.objects, "/blog/2024/05/a b.txt")
+	assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=key/\d{8}/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`, fake.auth[0])
+
+	file, err := store.Open(ctx, "2024/05/a b.txt")
+	assert.NoError(t, err)
+	body, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "hello", string(body))
+
+	assert.NoError(t, store.Delete(ctx, "2024/05/a b.txt"))
+	_, err = store.Open(ctx, "2024/05/a b.txt")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+
+	//uploads go through the API the same way
+	setupMediaTest(t, store)
+	content := pngFile(t)
+	_, responseBody := uploadFile(t, 1, "photo.png", content)
+	rec := getFeed(responseBody["data"].(map[string]interface{})["url"].(string), nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, content, rec.Body.Bytes())
+	assert.NotEmpty(t, rec.Header().Get("ETag"))
+}
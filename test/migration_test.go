@@ -0,0 +1,170 @@
+package test
+
+import (
+	"echo-blog/config"
+	"echo-blog/lib/migration"
+	"echo-blog/models"
+	"errors"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// migrationDB opens an empty in-memory database for the test.
+func migrationDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func createTable(name string) migration.Migration {
+	return migration.Migration{
+		Name: "create_" + name,
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE TABLE " + name + " (id INTEGER PRIMARY KEY)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(name)
+		},
+	}
+}
+
+func appliedVersions(t *testing.T, migrator *migration.Migrator) []int {
+	states, err := migrator.Status()
+	assert.NoError(t, err)
+	var versions []int
+	for _, state := range states {
+		if state.Applied() {
+			versions = append(versions, state.Version)
+		}
+	}
+	return versions
+}
+
+func TestMigrateUpDownAndTo(t *testing.T) {
+	db := migrationDB(t)
+	a, b, c := createTable("a"), createTable("b"), createTable("c")
+	a.Version, b.Version, c.Version = 1, 2, 3
+	migrator := migration.New(db, []migration.Migration{c, a, b})
+
+	//test
+	assert.Empty(t, appliedVersions(t, migrator))
+	assert.NoError(t, migrator.Up())
+	assert.Equal(t, []int{1, 2, 3}, appliedVersions(t, migrator))
+	assert.True(t, db.Migrator().HasTable("c"))
+
+	assert.NoError(t, migrator.Down(1))
+	assert.Equal(t, []int{1, 2}, appliedVersions(t, migrator))
+	assert.False(t, db.Migrator().HasTable("c"))
+
+	assert.NoError(t, migrator.To(1))
+	assert.Equal(t, []int{1}, appliedVersions(t, migrator))
+	assert.False(t, db.Migrator().HasTable("b"))
+
+	assert.NoError(t, migrator.To(3))
+	assert.Equal(t, []int{1, 2, 3}, appliedVersions(t, migrator))
+
+	assert.NoError(t, migrator.To(0))
+	assert.Empty(t, appliedVersions(t, migrator))
+	assert.False(t, db.Migrator().HasTable("a"))
+
+	assert.ErrorIs(t, migrator.To(4), migration.ErrUnknownVersion)
+}
+
+func TestMigrateStopsAtFailingMigration(t *testing.T) {
+	db := migrationDB(t)
+	a := createTable("a")
+	a.Version = 1
+	broken := createTable("b")
+	broken.Version = 2
+	up := broken.Up
+	broken.Up = func(tx *gorm.DB) error {
+		if err := up(tx); err != nil {
+			return err
+		}
+		return errors.New("broken")
+	}
+	migrator := migration.New(db, []migration.Migration{a, broken})
+
+	//test
+	assert.Error(t, migrator.Up())
+	assert.Equal(t, []int{1}, appliedVersions(t, migrator))
+	assert.False(t, db.Migrator().HasTable("b"))
+
+	pending, err := migrator.Pending()
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, "create_b", pending[0].Name)
+}
+
+func TestAppMigrationsRevert(t *testing.T) {
+	db := migrationDB(t)
+	migrator := migration.New(db, config.Migrations)
+
+	//test
+	assert.NoError(t, migrator.Up())
+	assert.True(t, db.Migrator().HasTable(&models.Blog{}))
+	assert.True(t, db.Migrator().HasTable("blog_tags"))
+	assert.True(t, db.Migrator().HasTable("media_variants"))
+
+	//every step down removes what its step up added
+	assert.NoError(t, migrator.To(4))
+	assert.False(t, db.Migrator().HasTable("media_variants"))
+	assert.False(t, db.Migrator().HasColumn("media", "width"))
+	assert.NoError(t, migrator.To(3))
+	assert.False(t, db.Migrator().HasTable("media"))
+	assert.False(t, db.Migrator().HasColumn("blogs", "featured_media_id"))
+	assert.NoError(t, migrator.To(2))
+	assert.False(t, db.Migrator().HasColumn("blogs", "body_html"))
+	assert.True(t, db.Migrator().HasIndex("blogs", "idx_blogs_slug"))
+
+	assert.NoError(t, migrator.To(0))
+	assert.False(t, db.Migrator().HasTable(&models.Blog{}))
+	assert.False(t, db.Migrator().HasTable("blog_tags"))
+
+	assert.NoError(t, migrator.Up())
+	pending, err := migrator.Pending()
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+// legacyUser and legacyBlog are the models of the first release, their
+// tables were created by AutoMigrate.
+type legacyUser struct {
+	gorm.Model
+	Username string
+	Email    string
+	Password string
+	Token    string
+}
+
+type legacyBlog struct {
+	gorm.Model
+	Title string
+	Body  string
+	Slug  string
+}
+
+func (legacyUser) TableName() string { return "users" }
+func (legacyBlog) TableName() string { return "blogs" }
+
+func TestAppMigrationsAdoptLegacyDatabase(t *testing.T) {
+	db := migrationDB(t)
+	assert.NoError(t, db.AutoMigrate(&legacyUser{}, &legacyBlog{}))
+	assert.NoError(t, db.Create(&legacyUser{Username: "old", Email: "old@mail.com"}).Error)
+	assert.NoError(t, db.Create([]legacyBlog{{Title: "Old Blog", Body: "# Hello", Slug: "Old Blog"}, {Title: "Old Blog", Body: "text", Slug: "old-blog"}}).Error)
+
+	//test
+	assert.NoError(t, migration.New(db, config.Migrations).Up())
+	var blogs []models.Blog
+	assert.NoError(t, db.Order("id").Find(&blogs).Error)
+	assert.Len(t, blogs, 2)
+	assert.Equal(t, []string{"old-blog", "old-blog-2"}, []string{blogs[0].Slug, blogs[1].Slug})
+	assert.Equal(t, uint(1), blogs[0].UserID)
+	assert.Equal(t, models.BlogStatusPublished, blogs[0].Status)
+	assert.Contains(t, blogs[0].BodyHTML, "Hello</h1>")
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"echo-blog/lib/spam"
+	"echo-blog/models"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSpamTest(t *testing.T, bannedWords []string, maxLinks int) {
+	setupBlogTest(t)
+	spam.Checker = spam.NewHeuristicChecker(bannedWords, maxLinks)
+	t.Cleanup(func() { spam.Checker = nil })
+}
+
+func postComment(t *testing.T, blogId string, userId int, body string) (float64, string, string) {
+	payload := fmt.Sprintf(`{"body":%q}`, body)
+	code, responseBody := commentRequest(commentHandler().AddNewComment, http.MethodPost, "/api/v1/blogs/"+blogId+"/comments", payload, userId, models.RoleAuthor, blogId)
+	assert.Equal(t, http.StatusOK, code)
+	comment := responseBody["data"].(map[string]interface{})
+	return comment["ID"].(float64), comment["status"].(string), responseBody["status"].(string)
+}
+
+func moderate(t *testing.T, status string, ids ...float64) (int, map[string]interface{}) {
+	payload := fmt.Sprintf(`{"status":%q,"ids":[`, status)
+	for i, id := range ids {
+		if i > 0 {
+			payload += ","
+		}
+		payload += fmt.Sprint(id)
+	}
+	payload += "]}"
+	return commentRequest(commentHandler().ModerateComments, http.MethodPost, "/api/v1/comments/moderation", payload, 3, models.RoleEditor)
+}
+
+func TestAddNewCommentWaitsForModeration(t *testing.T) {
+	setupBlogTest(t)
+
+	//test, blog 2 of user 2 does not auto approve
+	_, status, message := postComment(t, "2", 1, "nice post")
+	assert.Equal(t, models.CommentStatusPending, status)
+	assert.Equal(t, "new comment is waiting for moderation", message)
+
+	_, responseBody := commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/2/comments", "", 0, "", "2")
+	assert.Empty(t, responseBody["data"])
+
+	//the writer and moderators still see it
+	_, responseBody = commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/2/comments", "", 1, models.RoleAuthor, "2")
+	assert.Len(t, responseBody["data"], 1)
+	_, responseBody = commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/2/comments", "", 3, models.RoleEditor, "2")
+	assert.Len(t, responseBody["data"], 1)
+
+	//the blog's author needs no approval
+	_, status, _ = postComment(t, "2", 2, "thanks")
+	assert.Equal(t, models.CommentStatusApproved, status)
+}
+
+func TestAddNewCommentFlaggedAsSpam(t *testing.T) {
+	setupSpamTest(t, []string{"casino", "free money"}, 1)
+
+	//test, blog 1 auto approves comments
+	_, status, _ := postComment(t, "1", 2, "Great read, thanks!")
+	assert.Equal(t, models.CommentStatusApproved, status)
+
+	_, status, message := postComment(t, "1", 2, "Get FREE money at our Casino")
+	assert.Equal(t, models.CommentStatusSpam, status)
+	assert.Equal(t, "new comment is waiting for moderation", message)
+
+	_, status, _ = postComment(t, "1", 2, "see http://a.example and www.b.example")
+	assert.Equal(t, models.CommentStatusSpam, status)
+
+	_, status, _ = postComment(t, "1", 2, "my classmate liked the casinos chapter")
+	assert.Equal(t, models.CommentStatusApproved, status)
+
+	code, responseBody := commentRequest(commentHandler().GetModerationQueue, http.MethodGet, "/api/v1/comments/moderation?status=spam", "", 3, models.RoleEditor)
+	assert.Equal(t, http.StatusOK, code)
+	queue := responseBody["data"].([]interface{})
+	assert.Len(t, queue, 2)
+	assert.Equal(t, `banned word "casino", banned word "free money"`, queue[0].(map[string]interface{})["spamReason"])
+	assert.Equal(t, "2 links", queue[1].(map[string]interface{})["spamReason"])
+}
+
+func TestModerateCommentsInBulk(t *testing.T) {
+	setupBlogTest(t)
+
+	first, _, _ := postComment(t, "2", 1, "first")
+	second, _, _ := postComment(t, "2", 1, "second")
+	third, _, _ := postComment(t, "2", 1, "third")
+
+	//test
+	code, responseBody := commentRequest(commentHandler().GetModerationQueue, http.MethodGet, "/api/v1/comments/moderation", "", 3, models.RoleEditor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{first, second, third}, blogIDs(responseBody))
+
+	code, responseBody = moderate(t, models.CommentStatusApproved, first, third)
+	assert.Equal(t, http.StatusOK, code)
+	for _, comment := range responseBody["data"].([]interface{}) {
+		comment := comment.(map[string]interface{})
+		assert.Equal(t, models.CommentStatusApproved, comment["status"])
+		assert.Equal(t, float64(3), comment["moderatedBy"])
+	}
+
+	_, responseBody = commentRequest(commentHandler().GetBlogComments, http.MethodGet, "/api/v1/blogs/2/comments?format=flat", "", 0, "", "2")
+	assert.Equal(t, []string{"0:first", "0:third"}, commentBodies(responseBody["data"].([]interface{})))
+
+	//an edit needs a new approval
+	id := fmt.Sprint(first)
+	code, responseBody = commentRequest(commentHandler().UpdateComment, http.MethodPut, "/api/v1/blogs/2/comments/"+id, `{"body":"first, edited"}`, 1, models.RoleAuthor, "2", id)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, models.CommentStatusPending, responseBody["data"].(map[string]interface{})["status"])
+}
+
+func TestModerateCommentsInvalid(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := moderate(t, models.CommentStatusPending, 1)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "status must be one of approved, rejected or spam", responseBody["status"])
+
+	code, responseBody = moderate(t, models.CommentStatusApproved)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "ids must list between 1 and 100 comments", responseBody["status"])
+
+	code, responseBody = moderate(t, models.CommentStatusApproved, 999)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "comment not found", responseBody["status"])
+}
+
+func TestSpamCheckerLearnsFromModerators(t *testing.T) {
+	setupSpamTest(t, nil, 5)
+
+	var spamIds, hamIds []float64
+	for i := 0; i < 5; i++ {
+		id, _, _ := postComment(t, "2", 1, fmt.Sprintf("cheap pills discount offer number %d", i))
+		spamIds = append(spamIds, id)
+		id, _, _ = postComment(t, "2", 1, fmt.Sprintf("interesting point about goroutines in part %d", i))
+		hamIds = append(hamIds, id)
+	}
+
+	//test
+	_, status, _ := postComment(t, "1", 2, "cheap pills, great discount offer")
+	assert.Equal(t, models.CommentStatusApproved, status)
+
+	code, _ := moderate(t, models.CommentStatusSpam, spamIds...)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = moderate(t, models.CommentStatusApproved, hamIds...)
+	assert.Equal(t, http.StatusOK, code)
+
+	_, status, _ = postComment(t, "1", 2, "cheap pills, great discount offer")
+	assert.Equal(t, models.CommentStatusSpam, status)
+	_, status, _ = postComment(t, "1", 2, "I have a question about goroutines")
+	assert.Equal(t, models.CommentStatusApproved, status)
+
+	//changing a decision is unlearned again
+	code, _ = moderate(t, models.CommentStatusApproved, spamIds...)
+	assert.Equal(t, http.StatusOK, code)
+	_, status, _ = postComment(t, "1", 2, "cheap pills, great discount offer")
+	assert.Equal(t, models.CommentStatusApproved, status)
+}
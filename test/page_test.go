@@ -0,0 +1,145 @@
+package test
+
+import (
+	"echo-blog/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func listRequest(handler echo.HandlerFunc, target string, userId int, role string) (int, map[string]interface{}) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("userId", userId)
+	c.Set("role", role)
+
+	handle(handler, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func blogIDs(responseBody map[string]interface{}) []float64 {
+	var ids []float64
+	for _, blog := range responseBody["data"].([]interface{}) {
+		ids = append(ids, blog.(map[string]interface{})["ID"].(float64))
+	}
+	return ids
+}
+
+func TestGetAllBlogsPageNumbers(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?limit=2&sort=title", 3, models.RoleEditor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{1, 2}, blogIDs(responseBody))
+
+	meta := responseBody["meta"].(map[string]interface{})
+	assert.Equal(t, float64(3), meta["total"])
+	assert.Equal(t, float64(1), meta["page"])
+	assert.Equal(t, "/api/v1/blogs?limit=2&page=2&sort=title", meta["next"])
+	assert.Nil(t, meta["prev"])
+
+	code, responseBody = listRequest(blogHandler().GetAllBlogs, meta["next"].(string), 3, models.RoleEditor)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{3}, blogIDs(responseBody))
+
+	meta = responseBody["meta"].(map[string]interface{})
+	assert.Nil(t, meta["next"])
+	assert.Equal(t, "/api/v1/blogs?limit=2&page=1&sort=title", meta["prev"])
+}
+
+func TestGetAllBlogsCursor(t *testing.T) {
+	setupBlogTest(t)
+
+	_, responseBody := listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs", 3, models.RoleEditor)
+	expected := blogIDs(responseBody)
+	assert.Len(t, expected, 3)
+
+	//test: the first page hands out a cursor, the next links follow it
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?limit=1", 3, models.RoleEditor)
+	ids := blogIDs(responseBody)
+	meta := responseBody["meta"].(map[string]interface{})
+
+	next := "/api/v1/blogs?limit=1&after=" + meta["nextCursor"].(string)
+	for next != "" {
+		code, responseBody := listRequest(blogHandler().GetAllBlogs, next, 3, models.RoleEditor)
+		assert.Equal(t, http.StatusOK, code)
+		ids = append(ids, blogIDs(responseBody)...)
+
+		meta = responseBody["meta"].(map[string]interface{})
+		next, _ = meta["next"].(string)
+	}
+	assert.Equal(t, expected, ids)
+
+	//and the prev links walk back from the last page
+	ids = nil
+	prev, _ := meta["prev"].(string)
+	for prev != "" {
+		parsed, _ := url.Parse(prev)
+		assert.NotEmpty(t, parsed.Query().Get("before"))
+
+		code, responseBody := listRequest(blogHandler().GetAllBlogs, prev, 3, models.RoleEditor)
+		assert.Equal(t, http.StatusOK, code)
+		ids = append(blogIDs(responseBody), ids...)
+
+		meta = responseBody["meta"].(map[string]interface{})
+		prev, _ = meta["prev"].(string)
+	}
+	assert.Equal(t, expected[:2], ids)
+}
+
+func TestGetAllBlogsFilters(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	_, responseBody := listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?author=test2", 0, "")
+	assert.Equal(t, []float64{2}, blogIDs(responseBody))
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?author=1&sort=title", 1, models.RoleAuthor)
+	assert.Equal(t, []float64{1, 3}, blogIDs(responseBody))
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?status=draft", 1, models.RoleAuthor)
+	assert.Equal(t, []float64{3}, blogIDs(responseBody))
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?from=2000-01-01&to=2000-12-31", 0, "")
+	assert.Empty(t, responseBody["data"])
+}
+
+func TestGetAllBlogsInvalidQuery(t *testing.T) {
+	setupBlogTest(t)
+
+	for target, message := range map[string]string{
+		"/api/v1/blogs?sort=body":             "sort must be one of created_at, published_at, title, updated_at",
+		"/api/v1/blogs?limit=1000":            "limit must be between 1 and 100",
+		"/api/v1/blogs?after=xyz":             "invalid cursor",
+		"/api/v1/blogs?status=deleted":        "status must be one of draft, scheduled, published or archived",
+		"/api/v1/blogs?from=yesterday":        "from must be a date like 2006-01-02 or a RFC 3339 time",
+		"/api/v1/blogs?sort=title&after=MSwx": "after and before can only be used when sorting by created_at",
+	} {
+		//test
+		code, responseBody := listRequest(blogHandler().GetAllBlogs, target, 0, "")
+		assert.Equal(t, http.StatusBadRequest, code, target)
+		assert.Equal(t, message, responseBody["status"], target)
+	}
+}
+
+func TestGetAllUsersFilterByRole(t *testing.T) {
+	setupUserTest(t)
+
+	//test
+	code, responseBody := listRequest(userHandler().GetAllUser, "/api/v1/users?role=editor", 4, models.RoleAdmin)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, responseBody["data"], 1)
+	assert.Equal(t, float64(1), responseBody["meta"].(map[string]interface{})["total"])
+}
@@ -0,0 +1,77 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddNewBlogRendersMarkdown(t *testing.T) {
+	setupBlogTest(t)
+
+	body := "# Intro\n\nHello <script>alert(1)</script>**world**\n\n## Čaj i Kafić\n\n## Intro\n\n```go\nfunc main() {}\n```"
+	payload := fmt.Sprintf(`{"title":"Markdown","body":%q}`, body)
+
+	//test
+	code, responseBody := jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", payload, 1, nil, nil)
+	assert.Equal(t, http.StatusOK, code)
+	blog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, body, blog["body"])
+	assert.Equal(t, "markdown", blog["contentFormat"])
+
+	bodyHTML := blog["bodyHtml"].(string)
+	assert.Contains(t, bodyHTML, `<h1 id="intro">Intro</h1>`)
+	assert.Contains(t, bodyHTML, `<h2 id="intro-2">Intro</h2>`)
+	assert.Contains(t, bodyHTML, "<strong>world</strong>")
+	assert.NotContains(t, bodyHTML, "<script>")
+	assert.Contains(t, bodyHTML, `<pre class="chroma"><code>`)
+	assert.Contains(t, bodyHTML, `<span class="kd">func</span>`)
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"level": float64(1), "id": "intro", "text": "Intro"},
+		map[string]interface{}{"level": float64(2), "id": "caj-i-kafic", "text": "Čaj i Kafić"},
+		map[string]interface{}{"level": float64(2), "id": "intro-2", "text": "Intro"},
+	}, blog["toc"])
+
+	//the rendered body is stored, not rendered per request
+	_, responseBody = jsonRequest(blogHandler().GetBlogByID, http.MethodGet, "/api/v1/blogs/", "", 1, []string{"id"}, []string{fmt.Sprint(blog["ID"])})
+	assert.Equal(t, bodyHTML, responseBody["data"].(map[string]interface{})["bodyHtml"])
+}
+
+func TestAddNewBlogSanitizesHTML(t *testing.T) {
+	setupBlogTest(t)
+
+	payload := `{"title":"HTML","contentFormat":"html","body":"<h2 id=\"Setup\">Setup</h2><img src=\"a.png\" onerror=\"alert(1)\"><a href=\"javascript:alert(1)\">x</a><iframe src=\"https://evil.example\"></iframe>"}`
+
+	//test
+	code, responseBody := jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", payload, 1, nil, nil)
+	assert.Equal(t, http.StatusOK, code)
+	blog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, `<h2 id="setup">Setup</h2><img src="a.png"/>x`, blog["bodyHtml"])
+
+	code, responseBody = jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":"Rich","contentFormat":"rich","body":"x"}`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "contentFormat must be one of markdown, html or plain", responseBody["status"])
+}
+
+func TestUpdateBlogRendersAgain(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"contentFormat":"plain","body":"# not a heading\n<b>\n\nsecond"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	blog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, "<p># not a heading<br/>\n&lt;b&gt;</p>\n<p>second</p>\n", blog["bodyHtml"])
+	assert.Empty(t, blog["toc"])
+
+	code, responseBody = jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"contentFormat":"markdown"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	blog = responseBody["data"].(map[string]interface{})
+	assert.Contains(t, blog["bodyHtml"], `<h1 id="not-a-heading">not a heading</h1>`)
+
+	code, responseBody = jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"contentFormat":"docx"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "contentFormat must be one of markdown, html or plain", responseBody["status"])
+}
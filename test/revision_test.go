@@ -0,0 +1,155 @@
+package test
+
+import (
+	"echo-blog/helper"
+	"echo-blog/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func updateBlogBody(t *testing.T, id string, userId int, body string) {
+	e := echo.New()
+
+	b, _ := json.Marshal(models.Blog{Body: body})
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/blogs/"+id, strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	c.Set("userId", userId)
+
+	handle(blogHandler().UpdateBlog, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func revisionRequest(handler echo.HandlerFunc, method string, target string, userId int, names []string, values []string) (int, map[string]interface{}) {
+	e := echo.New()
+
+	req := httptest.NewRequest(method, target, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	c.Set("userId", userId)
+
+	handle(handler, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func TestUpdateBlogRecordsRevisions(t *testing.T) {
+	setupBlogTest(t)
+	updateBlogBody(t, "1", 1, "Test Body 1\nmore")
+
+	//test
+	code, responseBody := revisionRequest(revisionHandler().GetBlogRevisions, http.MethodGet, "/api/v1/blogs/1/revisions", 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "success get blog revisions", responseBody["status"])
+
+	//the content from before the first update is kept as a baseline
+	revisions := responseBody["data"].([]interface{})
+	assert.Len(t, revisions, 2)
+	assert.Equal(t, float64(1), revisions[0].(map[string]interface{})["number"])
+	assert.Equal(t, float64(2), revisions[1].(map[string]interface{})["number"])
+
+	code, responseBody = revisionRequest(revisionHandler().GetBlogRevision, http.MethodGet, "/api/v1/blogs/1/revisions/1", 1, []string{"id", "revision"}, []string{"1", "1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Test Body 1", responseBody["data"].(map[string]interface{})["body"])
+}
+
+func TestGetBlogRevisionsForbiddenWhenNotAuthor(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := revisionRequest(revisionHandler().GetBlogRevisions, http.MethodGet, "/api/v1/blogs/1/revisions", 2, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, "you are not allowed to see the revisions of this blog", responseBody["status"])
+}
+
+func TestDiffBlogRevisions(t *testing.T) {
+	setupBlogTest(t)
+	updateBlogBody(t, "1", 1, "first\nsecond\nthird")
+	updateBlogBody(t, "1", 1, "first\nthird\nfourth")
+
+	//test
+	code, responseBody := revisionRequest(revisionHandler().DiffBlogRevisions, http.MethodGet, "/api/v1/blogs/1/revisions/diff?from=2&to=3", 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "success diff blog revisions", responseBody["status"])
+
+	var ops []string
+	for _, line := range responseBody["data"].(map[string]interface{})["lines"].([]interface{}) {
+		l := line.(map[string]interface{})
+		ops = append(ops, l["op"].(string)+" "+l["text"].(string))
+	}
+	assert.Equal(t, []string{"equal first", "delete second", "equal third", "insert fourth"}, ops)
+}
+
+func diffOps(lines []models.DiffLine) map[string]int {
+	ops := map[string]int{}
+	for _, line := range lines {
+		ops[line.Op]++
+	}
+	return ops
+}
+
+func TestDiffLinesOfLargeBodies(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 50000; i++ {
+		a = append(a, fmt.Sprintf("line %d", i))
+		b = append(b, fmt.Sprintf("other %d", i))
+	}
+
+	//test, a single change in a long body is found exactly
+	changed := append([]string(nil), a...)
+	changed[25000] = "changed"
+	lines := helper.DiffLines(strings.Join(a, "\n"), strings.Join(changed, "\n"))
+	assert.Equal(t, map[string]int{models.DiffEqual: 49999, models.DiffDelete: 1, models.DiffInsert: 1}, diffOps(lines))
+	assert.Equal(t, models.DiffLine{Op: models.DiffDelete, Text: "line 25000"}, lines[25000])
+	changed[100], changed[49000] = "changed", "changed"
+	lines = helper.DiffLines(strings.Join(a, "\n"), strings.Join(changed, "\n"))
+	assert.Equal(t, map[string]int{models.DiffEqual: 49997, models.DiffDelete: 3, models.DiffInsert: 3}, diffOps(lines))
+
+	//bodies too far apart are replaced as a whole instead of searched
+	lines = helper.DiffLines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+	assert.Equal(t, map[string]int{models.DiffDelete: 50000, models.DiffInsert: 50000}, diffOps(lines))
+
+	assert.Empty(t, helper.DiffLines("", ""))
+	assert.Equal(t, []models.DiffLine{{Op: models.DiffInsert, Text: "new"}}, helper.DiffLines("", "new"))
+}
+
+func TestRestoreBlogRevision(t *testing.T) {
+	setupBlogTest(t)
+	updateBlogBody(t, "1", 1, "overwritten by accident")
+
+	//test
+	code, responseBody := revisionRequest(revisionHandler().RestoreBlogRevision, http.MethodPost, "/api/v1/blogs/1/revisions/1/restore", 1, []string{"id", "revision"}, []string{"1", "1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "blog revision restored successfully", responseBody["status"])
+	assert.Equal(t, "Test Body 1", responseBody["data"].(map[string]interface{})["body"])
+
+	//restoring adds a revision instead of rewriting history
+	code, responseBody = revisionRequest(revisionHandler().GetBlogRevisions, http.MethodGet, "/api/v1/blogs/1/revisions", 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	revisions := responseBody["data"].([]interface{})
+	assert.Len(t, revisions, 3)
+	assert.Equal(t, float64(1), revisions[2].(map[string]interface{})["restoredFrom"])
+}
+
+func TestRestoreBlogRevisionNotFound(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := revisionRequest(revisionHandler().RestoreBlogRevision, http.MethodPost, "/api/v1/blogs/1/revisions/9/restore", 1, []string{"id", "revision"}, []string{"1", "9"})
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, "revision not found", responseBody["status"])
+}
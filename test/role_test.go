@@ -0,0 +1,81 @@
+package test
+
+import (
+	"echo-blog/models"
+	"echo-blog/routes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAllUsersForbiddenForAuthor(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	token := loginAs(t, e, "test2@mail.com")
+
+	//setup request
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	//test
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "You are not allowed to access this resource!", responseBody["status"])
+}
+
+func TestGetAllUsersAllowedForAdmin(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	token := loginAs(t, e, "test4@mail.com")
+
+	//setup request
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	//test
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "success get all user", responseBody["status"])
+}
+
+func TestLoginUserTokenCarriesRole(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	//login as editor and use the token on an admin route
+	body := `{"email":"test3@mail.com","password":"1234"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var loginBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &loginBody)
+	dataUser := loginBody["data"].(map[string]interface{})
+	assert.Equal(t, models.RoleEditor, dataUser["role"])
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/v1/users/1", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+dataUser["token"].(string))
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
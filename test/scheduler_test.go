@@ -0,0 +1,117 @@
+package test
+
+import (
+	"echo-blog/config"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func scheduleBlog(id string, userId int, publishAt time.Time) *httptest.ResponseRecorder {
+	e := echo.New()
+
+	b, _ := json.Marshal(models.BlogSchedule{PublishAt: publishAt})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/blogs/"+id+"/schedule", strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	c.Set("userId", userId)
+
+	handle(blogHandler().ScheduleBlog, c)
+	return rec
+}
+
+func TestScheduleBlogSuccess(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := scheduleBlog("3", 1, time.Now().Add(time.Hour))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "blog scheduled successfully", responseBody["status"])
+
+	dataBlog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, models.BlogStatusScheduled, dataBlog["status"])
+	assert.NotNil(t, dataBlog["scheduledAt"])
+}
+
+func TestScheduleBlogFailedWhenInPast(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := scheduleBlog("3", 1, time.Now().Add(-time.Hour))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "publishAt must be in the future", responseBody["status"])
+}
+
+func TestScheduleBlogFailedWhenPublished(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := scheduleBlog("1", 1, time.Now().Add(time.Hour))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestGetScheduledBlogsOnlyOwn(t *testing.T) {
+	setupBlogTest(t)
+	scheduleBlog("3", 1, time.Now().Add(time.Hour))
+
+	for userId, expected := range map[int]int{1: 1, 2: 0} {
+		//setup echo context
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/blogs/scheduled", nil)
+		rec := httptest.NewRecorder()
+		c := e.NewContext(req, rec)
+		c.Set("userId", userId)
+
+		//test
+		handle(blogHandler().GetScheduledBlogs, c)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var responseBody map[string]interface{}
+		json.Unmarshal(rec.Body.Bytes(), &responseBody)
+		assert.Equal(t, "success get scheduled blogs", responseBody["status"])
+		assert.Len(t, responseBody["data"], expected)
+	}
+}
+
+func TestPublishDueBlogs(t *testing.T) {
+	setupBlogTest(t)
+	publishAt := time.Now().Add(-time.Minute)
+	_, err := database.NewGormBlogRepository(config.DB).Schedule(3, publishAt)
+	assert.NoError(t, err)
+
+	//test
+	published, err := database.PublishDueBlogs(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, published, 1)
+
+	blog, err := database.NewGormBlogRepository(config.DB).FindByID(3, 0, "")
+	assert.NoError(t, err)
+	assert.Equal(t, models.BlogStatusPublished, blog.Status)
+	assert.Nil(t, blog.ScheduledAt)
+
+	//already published blogs are not picked up again
+	published, err = database.PublishDueBlogs(time.Now())
+	assert.NoError(t, err)
+	assert.Len(t, published, 0)
+}
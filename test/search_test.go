@@ -0,0 +1,117 @@
+package test
+
+import (
+	. "echo-blog/controllers"
+	"echo-blog/lib/search"
+	"echo-blog/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupSearchTest(t *testing.T) {
+	setupBlogTest(t)
+	assert.NoError(t, search.Setup("memory"))
+}
+
+func addPublishedBlog(t *testing.T, title string, body string) float64 {
+	id := addBlog(t, models.Blog{Title: title, Body: body})["ID"].(float64)
+
+	code, _ := revisionRequest(blogHandler().PublishBlog, http.MethodPost, "/", 1, []string{"id"}, []string{fmt.Sprint(id)})
+	assert.Equal(t, http.StatusOK, code)
+	return id
+}
+
+func searchBlogs(target string) (int, map[string]interface{}) {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handle(SearchBlogs, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func searchResultIDs(responseBody map[string]interface{}) []float64 {
+	var ids []float64
+	for _, result := range responseBody["data"].([]interface{}) {
+		ids = append(ids, result.(map[string]interface{})["blog"].(map[string]interface{})["ID"].(float64))
+	}
+	return ids
+}
+
+func TestSearchBlogsRanksByRelevance(t *testing.T) {
+	setupSearchTest(t)
+	mentioned := addPublishedBlog(t, "Weekend notes", "We tried Golang for a small tool and it went fine.")
+	about := addPublishedBlog(t, "Golang tips", "Golang channels and golang interfaces explained.")
+	addPublishedBlog(t, "Cooking", "Nothing to see here.")
+
+	//test
+	code, responseBody := searchBlogs("/api/v1/blogs/search?q=golang")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "success search blogs", responseBody["status"])
+	assert.Equal(t, []float64{about, mentioned}, searchResultIDs(responseBody))
+	assert.Equal(t, float64(2), responseBody["meta"].(map[string]interface{})["total"])
+
+	first := responseBody["data"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "<mark>Golang</mark> channels and <mark>golang</mark> interfaces explained.", first["snippet"])
+}
+
+func TestSearchBlogsSkipsDraftsAndDeletedBlogs(t *testing.T) {
+	setupSearchTest(t)
+	addBlog(t, models.Blog{Title: "Secret draft", Body: "unicorns"})
+	published := addPublishedBlog(t, "Public", "unicorns everywhere")
+
+	code, responseBody := searchBlogs("/api/v1/blogs/search?q=unicorns")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{published}, searchResultIDs(responseBody))
+
+	//test
+	code, _ = revisionRequest(blogHandler().DeleteBlog, http.MethodDelete, "/", 1, []string{"id"}, []string{fmt.Sprint(published)})
+	assert.Equal(t, http.StatusOK, code)
+
+	code, responseBody = searchBlogs("/api/v1/blogs/search?q=unicorns")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, responseBody["data"])
+}
+
+func TestSearchBlogsFindsUpdatedContent(t *testing.T) {
+	setupSearchTest(t)
+	updateBlogBody(t, "1", 1, "now about zeppelins")
+
+	//test
+	_, responseBody := searchBlogs("/api/v1/blogs/search?q=Zeppelins")
+	assert.Equal(t, []float64{1}, searchResultIDs(responseBody))
+}
+
+func TestSearchBlogsFailedWithoutQuery(t *testing.T) {
+	setupSearchTest(t)
+
+	//test
+	code, responseBody := searchBlogs("/api/v1/blogs/search?q=%20")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "q is required", responseBody["status"])
+}
+
+func TestHighlightEscapesAndTrims(t *testing.T) {
+	body := "<b>Café</b> " + strings.Repeat("filler ", 40) + "the cafe again"
+
+	//test
+	snippet := search.Highlight(body, []string{"cafe"})
+	assert.True(t, strings.HasPrefix(snippet, "&lt;b&gt;<mark>Café</mark>&lt;/b&gt; filler filler"))
+	assert.True(t, strings.HasSuffix(snippet, "filler…"))
+
+	snippet = search.Highlight(body, []string{"again"})
+	assert.True(t, strings.HasPrefix(snippet, "…filler"))
+	assert.True(t, strings.HasSuffix(snippet, "the cafe <mark>again</mark>"))
+}
@@ -0,0 +1,106 @@
+package test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"echo-blog/config"
+	"echo-blog/lib/sitemap"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type urlSet struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+	} `xml:"url"`
+}
+
+type sitemapIndex struct {
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+func sitemapLocs(t *testing.T, body []byte) []string {
+	var set urlSet
+	assert.NoError(t, xml.Unmarshal(body, &set))
+	locs := []string{}
+	for _, url := range set.URLs {
+		assert.NotEmpty(t, url.LastMod)
+		locs = append(locs, url.Loc)
+	}
+	return locs
+}
+
+func TestSitemapListsPublishedBlogs(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := getFeed("/sitemap.xml", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, []string{"http://example.com/api/v1/blogs/slug/slug1", "http://example.com/api/v1/blogs/slug/slug2"}, sitemapLocs(t, rec.Body.Bytes()))
+
+	rec = getFeed("/sitemap.xml.gz", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/gzip", rec.Header().Get(echo.HeaderContentType))
+	reader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Len(t, sitemapLocs(t, body), 2)
+
+	rec = getFeed("/sitemap.xml", map[string]string{"If-None-Match": getFeed("/sitemap.xml", nil).Header().Get("ETag")})
+	assert.Equal(t, http.StatusNotModified, rec.Code)
+}
+
+func TestSitemapIndex(t *testing.T) {
+	setupBlogTest(t)
+	maxURLs := sitemap.MaxURLs
+	sitemap.MaxURLs = 1
+	t.Cleanup(func() { sitemap.MaxURLs = maxURLs })
+	setupSettings(t, func(cfg *config.Config) { cfg.Site.URL = "https://blog.example.com/" })
+
+	//test
+	var index sitemapIndex
+	rec := getFeed("/sitemap.xml.gz", nil)
+	reader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+	assert.NoError(t, err)
+	body, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.NoError(t, xml.Unmarshal(body, &index))
+	assert.Len(t, index.Sitemaps, 2)
+	assert.Equal(t, "https://blog.example.com/sitemaps/2.xml.gz", index.Sitemaps[1].Loc)
+
+	rec = getFeed("/sitemaps/2.xml", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"https://blog.example.com/api/v1/blogs/slug/slug2"}, sitemapLocs(t, rec.Body.Bytes()))
+
+	assert.Equal(t, http.StatusNotFound, getFeed("/sitemaps/3.xml", nil).Code)
+	assert.Equal(t, http.StatusNotFound, getFeed("/sitemaps/one.xml", nil).Code)
+}
+
+func TestRobots(t *testing.T) {
+	//test
+	rec := getFeed("/robots.txt", nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "User-agent: *\nDisallow:\n\nSitemap: http://example.com/sitemap.xml\n", rec.Body.String())
+
+	setupSettings(t, func(cfg *config.Config) { cfg.Robots.Disallow = []string{"/api/v1/users", "/api/v1/comments"} })
+	rec = getFeed("/robots.txt", nil)
+	assert.Equal(t, "User-agent: *\nDisallow: /api/v1/users\nDisallow: /api/v1/comments\n\nSitemap: http://example.com/sitemap.xml\n", rec.Body.String())
+
+	file := filepath.Join(t.TempDir(), "robots.txt")
+	assert.NoError(t, os.WriteFile(file, []byte("User-agent: *\nDisallow: /\n"), 0o644))
+	setupSettings(t, func(cfg *config.Config) { cfg.Robots.File = file })
+	rec = getFeed("/robots.txt", nil)
+	assert.Equal(t, "User-agent: *\nDisallow: /\n", rec.Body.String())
+}
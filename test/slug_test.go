@@ -0,0 +1,156 @@
+package test
+
+import (
+	"echo-blog/config"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func addBlog(t *testing.T, blog models.Blog) map[string]interface{} {
+	e := echo.New()
+
+	b, _ := json.Marshal(blog)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/blogs", strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.Set("userId", 1)
+
+	handle(blogHandler().AddNewBlog, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return responseBody["data"].(map[string]interface{})
+}
+
+func getBlogBySlug(t *testing.T, slug string) *httptest.ResponseRecorder {
+	e := echo.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/blogs/slug/"+slug, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("slug")
+	c.SetParamValues(slug)
+
+	handle(blogHandler().GetBlogBySlug, c)
+	return rec
+}
+
+func TestAddNewBlogGeneratesSlugFromTitle(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	blog := addBlog(t, models.Blog{Title: "Čaj i Kafić: Šta Piti?", Body: "Test Body"})
+	assert.Equal(t, "caj-i-kafic-sta-piti", blog["slug"])
+}
+
+func TestAddNewBlogSlugCollisionGetsSuffix(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	first := addBlog(t, models.Blog{Title: "Same Title", Body: "Test Body"})
+	second := addBlog(t, models.Blog{Title: "Same Title", Body: "Test Body"})
+	third := addBlog(t, models.Blog{Title: "Other", Slug: "Same Title", Body: "Test Body"})
+	assert.Equal(t, "same-title", first["slug"])
+	assert.Equal(t, "same-title-2", second["slug"])
+	assert.Equal(t, "same-title-3", third["slug"])
+}
+
+func TestGetBlogBySlugSuccess(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := getBlogBySlug(t, "slug1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	assert.Equal(t, "success get blog by slug", responseBody["status"])
+	assert.Equal(t, "Test Blog 1", responseBody["data"].(map[string]interface{})["title"])
+}
+
+func TestGetBlogBySlugNotFound(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := getBlogBySlug(t, "no-such-slug")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	assert.Equal(t, "blog not found", responseBody["status"])
+}
+
+func TestGetBlogBySlugRedirectsOldSlug(t *testing.T) {
+	setupBlogTest(t)
+
+	//rename blog 1, its slug follows the title
+	e := echo.New()
+	b, _ := json.Marshal(models.Blog{Title: "Renamed Blog"})
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/blogs/1", strings.NewReader(string(b)))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("1")
+	c.Set("userId", 1)
+	handle(blogHandler().UpdateBlog, c)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	assert.Equal(t, "renamed-blog", responseBody["data"].(map[string]interface{})["slug"])
+
+	//test
+	rec = getBlogBySlug(t, "slug1")
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "/api/v1/blogs/slug/renamed-blog", rec.Header().Get(echo.HeaderLocation))
+
+	assert.Equal(t, http.StatusOK, getBlogBySlug(t, "renamed-blog").Code)
+}
+
+func TestOldSlugOfHiddenBlogDoesNotRedirect(t *testing.T) {
+	setupBlogTest(t)
+	_, err := database.NewGormBlogRepository(config.DB).Update(3, models.Blog{Title: "Secret Plans"}, 1)
+	assert.NoError(t, err)
+
+	//test, the new slug of a draft is not given away
+	rec := getBlogBySlug(t, "slug3")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Empty(t, rec.Header().Get(echo.HeaderLocation))
+
+	code, _ := jsonRequest(blogHandler().GetBlogBySlug, http.MethodGet, "/api/v1/blogs/slug/slug3", "", 1, []string{"slug"}, []string{"slug3"})
+	assert.Equal(t, http.StatusMovedPermanently, code)
+}
+
+func TestAddNewBlogRetriesSlugTakenConcurrently(t *testing.T) {
+	setupBlogTest(t)
+	addBlog(t, models.Blog{Title: "Same Title", Body: "Test Body"})
+
+	// a concurrent create saves "same-title-2" after this one picked it
+	raced := false
+	config.DB.Callback().Create().Before("gorm:create").Register("test:slug_race", func(db *gorm.DB) {
+		if db.Statement.Table != "blogs" || raced {
+			return
+		}
+		raced = true
+		other := models.Blog{Title: "Same Title", Body: "Other Body", Slug: "same-title-2", UserID: 2, Status: models.BlogStatusDraft}
+		db.AddError(db.Session(&gorm.Session{NewDB: true}).Create(&other).Error)
+	})
+	t.Cleanup(func() { config.DB.Callback().Create().Remove("test:slug_race") })
+
+	//test
+	blog := addBlog(t, models.Blog{Title: "Same Title", Body: "Test Body"})
+	assert.True(t, raced)
+	assert.Equal(t, "same-title-2", blog["slug"])
+}
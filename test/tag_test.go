@@ -0,0 +1,219 @@
+package test
+
+import (
+	"echo-blog/config"
+	. "echo-blog/controllers"
+	"echo-blog/models"
+	"echo-blog/routes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func jsonRequest(handler echo.HandlerFunc, method string, target string, body string, userId int, names []string, values []string) (int, map[string]interface{}) {
+	e := echo.New()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames(names...)
+	c.SetParamValues(values...)
+	c.Set("userId", userId)
+
+	handle(handler, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func tagSlugs(blog map[string]interface{}) []string {
+	var slugs []string
+	for _, tag := range blog["tags"].([]interface{}) {
+		slugs = append(slugs, tag.(map[string]interface{})["slug"].(string))
+	}
+	return slugs
+}
+
+func TestAddNewBlogWithTagsAndCategory(t *testing.T) {
+	setupBlogTest(t)
+
+	//test, "web" exists already, "Echo Framework" is created
+	rec := fakeRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs",
+		`{"title":"Tagged","body":"Test Body","categoryId":3,"tags":[{"name":"Web"},{"name":"Echo Framework"},{"name":"web"}]}`, 3, models.RoleEditor, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	blog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, float64(3), blog["categoryId"])
+	assert.Equal(t, []string{"web", "echo-framework"}, tagSlugs(blog))
+	assert.Equal(t, float64(2), blog["tags"].([]interface{})[0].(map[string]interface{})["id"])
+}
+
+func TestAuthorsOnlyUseExistingTags(t *testing.T) {
+	setupBlogTest(t)
+
+	//test, tags are created by editors and admins
+	code, responseBody := jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs",
+		`{"title":"Tagged","body":"Test Body","tags":[{"name":"Go"},{"name":"Rust"},{"name":"Zig"}]}`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "tag does not exist: Rust, Zig", responseBody["status"])
+	code, _ = jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1",
+		`{"tags":[{"name":"Rust"}]}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	var tags int64
+	config.DB.Model(&models.Tag{}).Where("slug IN ?", []string{"rust", "zig"}).Count(&tags)
+	assert.Zero(t, tags)
+
+	code, responseBody = jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs",
+		`{"title":"Tagged","body":"Test Body","tags":[{"name":"Go"},{"slug":"web"}]}`, 1, nil, nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"go", "web"}, tagSlugs(responseBody["data"].(map[string]interface{})))
+}
+
+func TestAddNewBlogFailedWhenCategoryNotFound(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs",
+		`{"title":"Tagged","body":"Test Body","categoryId":99}`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "category not found", responseBody["status"])
+}
+
+func TestUpdateBlogReplacesTags(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	rec := fakeRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1",
+		`{"tags":[{"name":"Travel Notes"}],"categoryId":0}`, 3, models.RoleEditor, "1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	blog := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, []string{"travel-notes"}, tagSlugs(blog))
+	assert.Nil(t, blog["categoryId"])
+	assert.Equal(t, "Test Blog 1", blog["title"])
+
+	//a blog update without tags keeps them
+	updateBlogBody(t, "1", 1, "New Body")
+	code, responseBody := jsonRequest(blogHandler().GetBlogByID, http.MethodGet, "/api/v1/blogs/1", "", 0, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"travel-notes"}, tagSlugs(responseBody["data"].(map[string]interface{})))
+}
+
+func TestGetAllBlogsFilterByTagAndCategory(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	_, responseBody := listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?tag=web&sort=created_at", 0, "")
+	assert.Equal(t, []float64{1, 2}, blogIDs(responseBody))
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?tag=Go", 3, models.RoleEditor)
+	assert.ElementsMatch(t, []float64{1, 3}, blogIDs(responseBody))
+
+	//a category includes its subcategories
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?category=programming&sort=created_at", 0, "")
+	assert.Equal(t, []float64{1, 2}, blogIDs(responseBody))
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?category=go", 0, "")
+	assert.Equal(t, []float64{1}, blogIDs(responseBody))
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?category=no-such-category", 0, "")
+	assert.Empty(t, responseBody["data"])
+}
+
+func TestGetTagCloudCountsPublishedBlogs(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := listRequest(GetTagCloud, "/api/v1/tags/cloud", 0, "")
+	assert.Equal(t, http.StatusOK, code)
+
+	var counts []interface{}
+	for _, tag := range responseBody["data"].([]interface{}) {
+		tag := tag.(map[string]interface{})
+		counts = append(counts, tag["slug"], tag["count"])
+	}
+	assert.Equal(t, []interface{}{"web", float64(2), "go", float64(1)}, counts)
+}
+
+func TestTagCRUD(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := jsonRequest(AddNewTag, http.MethodPost, "/api/v1/tags", `{"name":"Databases"}`, 3, nil, nil)
+	assert.Equal(t, http.StatusOK, code)
+	tag := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, "databases", tag["slug"])
+
+	code, responseBody = jsonRequest(AddNewTag, http.MethodPost, "/api/v1/tags", `{"name":"WEB"}`, 3, nil, nil)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.Equal(t, "tag already exists", responseBody["status"])
+
+	code, responseBody = jsonRequest(UpdateTag, http.MethodPut, "/api/v1/tags/2", `{"name":"Web Development"}`, 3, []string{"id"}, []string{"2"})
+	assert.Equal(t, http.StatusOK, code)
+	tag = responseBody["data"].(map[string]interface{})
+	assert.Equal(t, "web-development", tag["slug"])
+	assert.Equal(t, float64(2), tag["count"])
+
+	code, _ = jsonRequest(DeleteTag, http.MethodDelete, "/api/v1/tags/2", "", 3, []string{"id"}, []string{"2"})
+	assert.Equal(t, http.StatusOK, code)
+
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?tag=web-development", 0, "")
+	assert.Empty(t, responseBody["data"])
+}
+
+func TestCategoryTree(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := listRequest(GetAllCategories, "/api/v1/categories", 0, "")
+	assert.Equal(t, http.StatusOK, code)
+
+	roots := responseBody["data"].([]interface{})
+	assert.Len(t, roots, 2)
+	programming := roots[0].(map[string]interface{})
+	assert.Equal(t, "programming", programming["slug"])
+	assert.Equal(t, "go", programming["children"].([]interface{})[0].(map[string]interface{})["slug"])
+
+	//a category cannot move below its own subcategory
+	code, responseBody = jsonRequest(UpdateCategory, http.MethodPut, "/api/v1/categories/1", `{"parentId":2}`, 3, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "a category cannot be moved below itself", responseBody["status"])
+
+	//deleting a category moves its subcategories and blogs up
+	code, _ = jsonRequest(DeleteCategory, http.MethodDelete, "/api/v1/categories/1", "", 3, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+
+	_, responseBody = listRequest(GetAllCategories, "/api/v1/categories", 0, "")
+	assert.Len(t, responseBody["data"], 2)
+	_, responseBody = listRequest(blogHandler().GetAllBlogs, "/api/v1/blogs?category=go", 0, "")
+	assert.Equal(t, []float64{1}, blogIDs(responseBody))
+}
+
+func TestAddNewTagForbiddenForAuthor(t *testing.T) {
+	setupBlogTest(t)
+	e := routes.New()
+
+	token := loginAs(t, e, "test1@mail.com")
+
+	//setup request
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/tags", strings.NewReader(`{"name":"Databases"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+
+	//test
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
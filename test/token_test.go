@@ -0,0 +1,191 @@
+package test
+
+import (
+	"echo-blog/config"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"echo-blog/routes"
+	"echo-blog/service"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// login logs in through the router and returns the login response data
+func login(t *testing.T, e *echo.Echo, email string) map[string]interface{} {
+	body := `{"email":"` + email + `","password":"1234"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/login", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return responseBody["data"].(map[string]interface{})
+}
+
+// loginAs returns an access token for the seeded user with the given email
+func loginAs(t *testing.T, e *echo.Echo, email string) string {
+	return login(t, e, email)["token"].(string)
+}
+
+func refresh(e *echo.Echo, refreshToken string) *httptest.ResponseRecorder {
+	body := `{"refreshToken":"` + refreshToken + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/token/refresh", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func logout(e *echo.Echo, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/logout", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRefreshTokenSuccess(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	session := login(t, e, "test1@mail.com")
+	assert.NotEmpty(t, session["refreshToken"])
+
+	//test
+	rec := refresh(e, session["refreshToken"].(string))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "token refreshed successfully", responseBody["status"])
+
+	pair := responseBody["data"].(map[string]interface{})
+	assert.NotEmpty(t, pair["token"])
+	assert.NotEqual(t, session["refreshToken"], pair["refreshToken"])
+
+	//the rotated access token is still accepted
+	assert.Equal(t, http.StatusOK, logout(e, pair["token"].(string)).Code)
+}
+
+func TestRefreshTokenInvalid(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	//test
+	rec := refresh(e, "not-a-refresh-token")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "refresh token is invalid or expired", responseBody["status"])
+}
+
+func TestRefreshTokenReuseRevokesSession(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	session := login(t, e, "test1@mail.com")
+	rec := refresh(e, session["refreshToken"].(string))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	pair := responseBody["data"].(map[string]interface{})
+
+	//replaying the rotated token kills the whole session
+	assert.Equal(t, http.StatusUnauthorized, refresh(e, session["refreshToken"].(string)).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(e, pair["refreshToken"].(string)).Code)
+	assert.Equal(t, http.StatusUnauthorized, logout(e, pair["token"].(string)).Code)
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+
+	session := login(t, e, "test1@mail.com")
+	token := session["token"].(string)
+
+	//test
+	rec := logout(e, token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	bodyRes, _ := io.ReadAll(rec.Body)
+	var responseBody map[string]interface{}
+	json.Unmarshal(bodyRes, &responseBody)
+	assert.Equal(t, "logout successfully", responseBody["status"])
+
+	//neither the access nor the refresh token can be used anymore
+	assert.Equal(t, http.StatusUnauthorized, logout(e, token).Code)
+	assert.Equal(t, http.StatusUnauthorized, refresh(e, session["refreshToken"].(string)).Code)
+}
+
+func TestSessionCheckFailureIsNotUnauthorized(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+	token := loginAs(t, e, "test1@mail.com")
+
+	//test, a database without sessions stands in for an outage
+	db := config.DB
+	config.DB = migrationDB(t)
+	t.Cleanup(func() { config.DB = db })
+	e = routes.New()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer not-a-token")
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestPasswordAndRoleChangesRevokeSessions(t *testing.T) {
+	setupUserTest(t)
+	e := routes.New()
+	users := service.NewUserService(database.NewGormUserRepository(config.DB), database.NewGormSessionRepository(config.DB))
+
+	//test, other changes keep the sessions
+	token := loginAs(t, e, "test1@mail.com")
+	_, err := users.Update(1, models.User{Username: "renamed", Role: models.RoleAuthor})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, logout(e, token).Code)
+
+	//a new role or password ends them
+	token = loginAs(t, e, "test1@mail.com")
+	_, err = users.Update(1, models.User{Role: models.RoleEditor})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, logout(e, token).Code)
+
+	token = loginAs(t, e, "test1@mail.com")
+	_, err = users.Update(1, models.User{Password: "secret12"})
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, logout(e, token).Code)
+
+	//the change is rolled back when the sessions cannot be ended
+	before, err := users.Get(2)
+	assert.NoError(t, err)
+	assert.NoError(t, config.DB.Migrator().RenameTable("refresh_tokens", "refresh_tokens_away"))
+	t.Cleanup(func() { config.DB.Migrator().RenameTable("refresh_tokens_away", "refresh_tokens") })
+
+	_, err = users.Update(2, models.User{Password: "secret12"})
+	assert.Error(t, err)
+	assert.Error(t, users.Delete(2))
+	after, err := users.Get(2)
+	assert.NoError(t, err)
+	assert.Equal(t, before.Password, after.Password)
+}
@@ -0,0 +1,136 @@
+package test
+
+import (
+	"echo-blog/config"
+	. "echo-blog/controllers"
+	"echo-blog/lib/database"
+	"echo-blog/models"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func trashRequest(handler echo.HandlerFunc, method string, target string, userId int, role string, id string) (int, map[string]interface{}) {
+	e := echo.New()
+
+	req := httptest.NewRequest(method, target, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues(id)
+	c.Set("userId", userId)
+	c.Set("role", role)
+
+	handle(handler, c)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	return rec.Code, responseBody
+}
+
+func TestTrashAndRestoreBlog(t *testing.T) {
+	setupBlogTest(t)
+	err := database.NewGormBlogRepository(config.DB).Delete(1)
+	assert.NoError(t, err)
+
+	//test
+	code, responseBody := trashRequest(GetTrashedBlogs, http.MethodGet, "/api/v1/trash/blogs", 1, models.RoleAuthor, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{1}, blogIDs(responseBody))
+	_, responseBody = trashRequest(GetTrashedBlogs, http.MethodGet, "/api/v1/trash/blogs", 2, models.RoleAuthor, "")
+	assert.Empty(t, blogIDs(responseBody))
+
+	//the slug of a trashed blog is free again
+	blog := models.Blog{Title: "Another Blog", Body: "Another Body", Slug: "slug1", UserID: 2}
+	assert.NoError(t, database.NewGormBlogRepository(config.DB).Create(&blog))
+	assert.Equal(t, "slug1", blog.Slug)
+
+	code, _ = trashRequest(RestoreBlog, http.MethodPost, "/api/v1/trash/blogs/1/restore", 2, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusForbidden, code)
+	code, responseBody = trashRequest(RestoreBlog, http.MethodPost, "/api/v1/trash/blogs/1/restore", 1, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "slug1-2", responseBody["data"].(map[string]interface{})["slug"])
+
+	code, _ = trashRequest(RestoreBlog, http.MethodPost, "/api/v1/trash/blogs/1/restore", 1, models.RoleAuthor, "1")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestPurgeBlog(t *testing.T) {
+	setupBlogTest(t)
+	addComment(t, "1", 2, 0, "Nice post")
+	err := database.NewGormBlogRepository(config.DB).Delete(1)
+	assert.NoError(t, err)
+
+	//test
+	code, _ := trashRequest(PurgeBlog, http.MethodDelete, "/api/v1/trash/blogs/2", 4, models.RoleAdmin, "2")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, responseBody := trashRequest(PurgeBlog, http.MethodDelete, "/api/v1/trash/blogs/1", 4, models.RoleAdmin, "1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "blog purged successfully", responseBody["status"])
+
+	var blogs, comments int64
+	config.DB.Unscoped().Model(&models.Blog{}).Where("id = ?", 1).Count(&blogs)
+	config.DB.Unscoped().Model(&models.Comment{}).Where("blog_id = ?", 1).Count(&comments)
+	assert.Zero(t, blogs)
+	assert.Zero(t, comments)
+}
+
+func TestTrashRestoreAndPurgeUser(t *testing.T) {
+	setupBlogTest(t)
+	addComment(t, "1", 3, 0, "Editor comment")
+	err := database.NewGormUserRepository(config.DB).Delete(3)
+	assert.NoError(t, err)
+
+	//test
+	code, responseBody := trashRequest(GetTrashedUsers, http.MethodGet, "/api/v1/trash/users", 4, models.RoleAdmin, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, responseBody["data"], 1)
+
+	//someone registered with the email of the trashed user
+	assert.NoError(t, config.DB.Create(&models.User{Username: "newcomer", Email: "test3@mail.com", Password: "1234"}).Error)
+	code, _ = trashRequest(RestoreUser, http.MethodPost, "/api/v1/trash/users/3/restore", 4, models.RoleAdmin, "3")
+	assert.Equal(t, http.StatusConflict, code)
+
+	code, _ = trashRequest(PurgeUser, http.MethodDelete, "/api/v1/trash/users/3", 4, models.RoleAdmin, "3")
+	assert.Equal(t, http.StatusOK, code)
+	var comments int64
+	config.DB.Unscoped().Model(&models.Comment{}).Where("user_id = ?", 3).Count(&comments)
+	assert.Zero(t, comments)
+
+	//users who still own blogs are kept
+	err = database.NewGormUserRepository(config.DB).Delete(2)
+	assert.NoError(t, err)
+	code, _ = trashRequest(PurgeUser, http.MethodDelete, "/api/v1/trash/users/2", 4, models.RoleAdmin, "2")
+	assert.Equal(t, http.StatusConflict, code)
+	code, responseBody = trashRequest(RestoreUser, http.MethodPost, "/api/v1/trash/users/2/restore", 4, models.RoleAdmin, "2")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "test2", responseBody["data"].(map[string]interface{})["username"])
+}
+
+func TestPurgeTrashAfterRetention(t *testing.T) {
+	setupBlogTest(t)
+	for _, id := range []uint{2, 3} {
+		err := database.NewGormBlogRepository(config.DB).Delete(id)
+		assert.NoError(t, err)
+	}
+	err := database.NewGormUserRepository(config.DB).Delete(2)
+	assert.NoError(t, err)
+	longAgo := time.Now().AddDate(0, 0, -40)
+	config.DB.Unscoped().Model(&models.Blog{}).Where("id = ?", 2).UpdateColumn("deleted_at", longAgo)
+	config.DB.Unscoped().Model(&models.User{}).Where("id = ?", 2).UpdateColumn("deleted_at", longAgo)
+
+	//test
+	blogs, users, err := database.PurgeTrash(time.Now().AddDate(0, 0, -30))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, blogs)
+	assert.Equal(t, 1, users)
+
+	var remaining int64
+	config.DB.Unscoped().Model(&models.Blog{}).Where("id = ?", 3).Count(&remaining)
+	assert.Equal(t, int64(1), remaining)
+}
@@ -1,15 +1,22 @@
 package test
 
 import (
+	"echo-blog/apperror"
 	"echo-blog/config"
 	. "echo-blog/controllers"
+	"echo-blog/dto"
+	"echo-blog/helper"
+	"echo-blog/lib/database"
 	"echo-blog/lib/database/seeder"
+	"echo-blog/middlewares"
 	"echo-blog/models"
+	"echo-blog/service"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -18,19 +25,60 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-// init function testing
-func setupUserTest(t *testing.T) {
-	//load env
-	if err := godotenv.Load("../.env"); err != nil {
-		t.Error("Error loading .env file")
+// setupDB connects to the database set in ../.env. Without a DB_DRIVER the
+// tests run on in-memory SQLite and need no database server.
+func setupDB(t *testing.T) {
+	//load env, the file is optional
+	godotenv.Load("../.env")
+	if os.Getenv("DB_DRIVER") == "" {
+		t.Setenv("DB_DRIVER", "sqlite")
+		t.Setenv("DB_NAME", ":memory:")
+	}
+	if os.Getenv("JWT_SECRET") == "" {
+		t.Setenv("JWT_SECRET", "test_secret_key_of_at_least_32_chars")
+	}
+
+	cfg, _, err := config.Load(nil)
+	if err != nil {
+		t.Fatal(err)
 	}
+	middlewares.SetJWTSecret(cfg.JWTSecret)
+	applySettings(cfg)
+	config.InitDB(cfg)
+}
+
+// applySettings hands the request time settings of cfg to the handlers,
+// like main does.
+func applySettings(cfg *config.Config) {
+	helper.SetSite(cfg.Site.URL, cfg.Site.Title, cfg.Site.BlogPath)
+	SetupMedia(cfg.Media)
+	SetupFeed(cfg.Feed)
+	SetupRobots(cfg.Robots)
+}
 
+// setupSettings applies the default settings changed by change for the
+// test, the defaults are back once it is done.
+func setupSettings(t *testing.T, change func(cfg *config.Config)) {
+	cfg := config.Default()
+	change(cfg)
+	applySettings(cfg)
+	t.Cleanup(func() { applySettings(config.Default()) })
+}
+
+// userHandler is the user handler of routes.New, on the test database.
+func userHandler() *UserHandler {
+	return NewUserHandler(service.NewUserService(database.NewGormUserRepository(config.DB), database.NewGormSessionRepository(config.DB)))
+}
+
+// init function testing
+func setupUserTest(t *testing.T) {
 	//setup database
-	config.InitDB()
+	setupDB(t)
 
 	// clear database
 	s := seeder.NewSeeder()
 	fmt.Println(s)
+	s.BlogDelete()
 	s.UserDelete()
 	s.UserSeed()
 }
@@ -42,7 +90,7 @@ func TestLoginUserSuccess(t *testing.T) {
 	e := echo.New()
 
 	//create json body
-	body := models.User{
+	body := dto.LoginRequest{
 		Email:    "test1@mail.com",
 		Password: "1234",
 	}
@@ -55,7 +103,7 @@ func TestLoginUserSuccess(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	assert.NoError(t, LoginUser(c))
+	handle(userHandler().LoginUser, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -65,9 +113,10 @@ func TestLoginUserSuccess(t *testing.T) {
 	dataUsers := responseBody["data"].(map[string]interface{})
 
 	assert.Equal(t, body.Email, dataUsers["email"])
-	assert.Equal(t, "", dataUsers["password"])
+	assert.Nil(t, dataUsers["password"])
 	assert.NotNil(t, dataUsers["token"])
 	assert.NotEmpty(t, dataUsers["token"])
+	assert.NotEmpty(t, dataUsers["refreshToken"])
 }
 
 func TestLoginUserWrongEmailOrPassword(t *testing.T) {
@@ -77,7 +126,7 @@ func TestLoginUserWrongEmailOrPassword(t *testing.T) {
 	e := echo.New()
 
 	//create json body
-	body := models.User{
+	body := dto.LoginRequest{
 		Email:    "test1@mail.com",
 		Password: "1235",
 	}
@@ -90,11 +139,13 @@ func TestLoginUserWrongEmailOrPassword(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	err := LoginUser(c)
-	hErr, ok := err.(*echo.HTTPError)
-	assert.True(t, ok)
-	assert.Equal(t, http.StatusBadRequest, hErr.Code)
-	assert.Equal(t, "wrong email or password", hErr.Message)
+	handle(userHandler().LoginUser, c)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	var responseBody map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &responseBody)
+	assert.Equal(t, "wrong email or password", responseBody["status"])
+	assert.Equal(t, apperror.CodeUnauthorized, responseBody["errorCode"])
 }
 
 func TestGetAllUsersSuccess(t *testing.T) {
@@ -108,7 +159,7 @@ func TestGetAllUsersSuccess(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	assert.NoError(t, GetAllUser(c))
+	handle(userHandler().GetAllUser, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -131,11 +182,9 @@ func TestGetAllUsersFailedDBNotConnect(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	err = GetAllUser(c)
-	assert.Error(t, err)
-	hErr, ok := err.(*echo.HTTPError)
-	assert.True(t, ok)
-	assert.Equal(t, http.StatusInternalServerError, hErr.Code)
+	handle(userHandler().GetAllUser, c)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Contains(t, rec.Body.String(), apperror.CodeInternal)
 }
 
 func TestAddNewUserSuccess(t *testing.T) {
@@ -145,10 +194,11 @@ func TestAddNewUserSuccess(t *testing.T) {
 	e := echo.New()
 
 	//create json body
-	body := models.User{
+	body := dto.UserRequest{
 		Username: "Budi",
 		Email:    "budi@mail.com",
 		Password: "12345abc",
+		Role:     models.RoleAdmin,
 	}
 
 	//setup request
@@ -159,13 +209,15 @@ func TestAddNewUserSuccess(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	assert.NoError(t, AddNewUser(c))
+	handle(userHandler().AddNewUser, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
 	json.Unmarshal(bodyRes, &responseBody)
 	assert.Equal(t, "new user added successfully", responseBody["status"])
+	dataUser := responseBody["data"].(map[string]interface{})
+	assert.Equal(t, models.RoleAuthor, dataUser["role"])
 }
 
 func TestAddNewUserFailedWhenUserNotInputEmail(t *testing.T) {
@@ -175,7 +227,7 @@ func TestAddNewUserFailedWhenUserNotInputEmail(t *testing.T) {
 	e := echo.New()
 
 	//create json body
-	body := models.User{
+	body := dto.UserRequest{
 		Username: "Dodi",
 		Email:    "",
 		Password: "12345",
@@ -187,7 +239,7 @@ func TestAddNewUserFailedWhenUserNotInputEmail(t *testing.T) {
 	c := e.NewContext(req, rec)
 
 	//test
-	assert.NoError(t, AddNewUser(c))
+	handle(userHandler().AddNewUser, c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -213,7 +265,7 @@ func TestGetUserByIdSuccess(t *testing.T) {
 	c.SetParamValues("1")
 
 	//test
-	assert.NoError(t, GetUserByID(c))
+	handle(userHandler().GetUserByID, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -239,8 +291,8 @@ func TestGetUserByIdNotFound(t *testing.T) {
 	c.SetParamValues("10")
 
 	//test
-	assert.NoError(t, GetUserByID(c))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	handle(userHandler().GetUserByID, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -255,10 +307,10 @@ func TestUpdateUserByIdSuccess(t *testing.T) {
 	e := echo.New()
 
 	//create json body
-	body := models.User{
+	body := dto.UserRequest{
 		Username: "Budiman",
 		Email:    "budiman@mail.com",
-		Password: "12345",
+		Password: "12345abc",
 	}
 
 	//setup request
@@ -277,7 +329,7 @@ func TestUpdateUserByIdSuccess(t *testing.T) {
 	c.Set("userId", 1)
 
 	//test
-	assert.NoError(t, UpdateUser(c))
+	handle(userHandler().UpdateUser, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -293,10 +345,10 @@ func TestUpdateUserByIdNotFound(t *testing.T) {
 	e := echo.New()
 
 	//create json body
-	body := models.User{
+	body := dto.UserRequest{
 		Username: "Budi",
 		Email:    "budi@mail.com",
-		Password: "12345",
+		Password: "12345abc",
 	}
 
 	//setup request
@@ -312,8 +364,8 @@ func TestUpdateUserByIdNotFound(t *testing.T) {
 	c.SetParamValues("100")
 
 	//test
-	assert.NoError(t, UpdateUser(c))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	handle(userHandler().UpdateUser, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -342,7 +394,7 @@ func TestDeleteUserByIdSuccess(t *testing.T) {
 	c.Set("userId", 1)
 
 	//test
-	assert.NoError(t, DeleteUser(c))
+	handle(userHandler().DeleteUser, c)
 	assert.Equal(t, http.StatusOK, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
@@ -372,8 +424,8 @@ func TestDeleteUserByIdNotFound(t *testing.T) {
 	c.Set("userId", 10)
 
 	//test
-	assert.NoError(t, DeleteUser(c))
-	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	handle(userHandler().DeleteUser, c)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
 
 	bodyRes, _ := io.ReadAll(rec.Body)
 	var responseBody map[string]interface{}
@@ -381,3 +433,29 @@ func TestDeleteUserByIdNotFound(t *testing.T) {
 
 	assert.Equal(t, "failed to delete user, id not found", responseBody["status"])
 }
+
+func TestUserEmailUniqueOutsideTrash(t *testing.T) {
+	setupUserTest(t)
+	users := service.NewUserService(database.NewGormUserRepository(config.DB), database.NewGormSessionRepository(config.DB))
+
+	// a request that passed the email check before another one saved it
+	_, err := users.Register(models.User{Username: "twin", Email: "test1@mail.com", Password: "secret12"})
+	assert.ErrorIs(t, err, service.ErrEmailTaken)
+	_, err = users.Update(2, models.User{Email: "test1@mail.com"})
+	assert.ErrorIs(t, err, service.ErrEmailTaken)
+
+	// a trashed user gives their email free until restored
+	assert.NoError(t, users.Delete(2))
+	_, err = users.Register(models.User{Username: "new2", Email: "test2@mail.com", Password: "secret12"})
+	assert.NoError(t, err)
+	_, err = database.RestoreUser(2)
+	assert.ErrorIs(t, err, database.ErrUserTaken)
+
+	// usernames are not unique, taking one does not block a restore
+	assert.NoError(t, users.Delete(3))
+	_, err = users.Register(models.User{Username: "test3", Email: "other3@mail.com", Password: "secret12"})
+	assert.NoError(t, err)
+	user, err := database.RestoreUser(3)
+	assert.NoError(t, err)
+	assert.Equal(t, "test3@mail.com", user.Email)
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"echo-blog/dto"
+	"echo-blog/lib/validation"
+	"echo-blog/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fieldErrors(responseBody map[string]interface{}) map[string]string {
+	codes := map[string]string{}
+	errs, _ := responseBody["errors"].([]interface{})
+	for _, err := range errs {
+		err := err.(map[string]interface{})
+		codes[err["field"].(string)] = err["code"].(string)
+	}
+	return codes
+}
+
+func TestValidationReportsEveryField(t *testing.T) {
+	setupUserTest(t)
+
+	//test
+	code, responseBody := jsonRequest(userHandler().AddNewUser, http.MethodPost, "/api/v1/users", `{"username":"ab","email":"not-an-email","password":"short","role":"owner"}`, 0, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "username must be at least 3 characters", responseBody["status"])
+	assert.Equal(t, map[string]string{"username": "min", "email": "email", "password": "password", "role": "oneof"}, fieldErrors(responseBody))
+
+	code, responseBody = jsonRequest(userHandler().AddNewUser, http.MethodPost, "/api/v1/users", `{"username":"again","email":"test1@mail.com","password":"secret123"}`, 0, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "email is already taken", responseBody["status"])
+	assert.Equal(t, map[string]string{"email": "taken"}, fieldErrors(responseBody))
+}
+
+func TestValidationRejectsMalformedBodies(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, responseBody := jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":"Title","body":"Body","colour":"red"}`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, map[string]string{"colour": "unknown_field"}, fieldErrors(responseBody))
+
+	code, responseBody = jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":1,"body":"Body"}`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "title must be a string", responseBody["status"])
+	assert.Equal(t, map[string]string{"title": "invalid_type"}, fieldErrors(responseBody))
+
+	code, responseBody = jsonRequest(blogHandler().AddNewBlog, http.MethodPost, "/api/v1/blogs", `{"title":`, 1, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, map[string]string{"": "invalid_body"}, fieldErrors(responseBody))
+}
+
+func TestValidationOnUpdateChecksSentFieldsOnly(t *testing.T) {
+	setupBlogTest(t)
+
+	//test
+	code, _ := jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"title":"New Title"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+
+	code, responseBody := jsonRequest(blogHandler().UpdateBlog, http.MethodPut, "/api/v1/blogs/1", `{"slug":"!!!","contentFormat":"rtf"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, map[string]string{"slug": "slug", "contentFormat": "oneof"}, fieldErrors(responseBody))
+
+	//a user keeps their own email, but cannot take another one
+	code, _ = jsonRequest(userHandler().UpdateUser, http.MethodPut, "/api/v1/users/1", `{"email":"test1@mail.com"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusOK, code)
+	code, responseBody = jsonRequest(userHandler().UpdateUser, http.MethodPut, "/api/v1/users/1", `{"email":"test2@mail.com","password":"1234"}`, 1, []string{"id"}, []string{"1"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, map[string]string{"email": "taken", "password": "password"}, fieldErrors(responseBody))
+}
+
+func TestValidationUniqueEmailFailsClosedWithoutChecker(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{"username":"budi","email":"budi@mail.com","password":"secret123"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	//test
+	err := validation.Bind(c, &dto.UserRequest{})
+	assert.Equal(t, models.ValidationErrors{{Field: "email", Code: "taken", Message: "email is already taken"}}, err)
+}